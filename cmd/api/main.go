@@ -2,63 +2,268 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/paulgrammer/childprocess/internal/config"
 	"github.com/paulgrammer/childprocess/internal/executor"
+	"github.com/paulgrammer/childprocess/internal/grpcapi"
 	"github.com/paulgrammer/childprocess/internal/httpapi"
 	"github.com/paulgrammer/childprocess/internal/jobs"
+	"github.com/paulgrammer/childprocess/internal/upload"
 	"github.com/paulgrammer/childprocess/internal/webhook"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func main() {
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	// Logger
-	level := parseLogLevel(getenv("LOG_LEVEL", "INFO"))
-	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})))
+	slog.SetDefault(slog.New(newLogHandler(os.Stdout, cfg.LogFormat, parseLogLevel(cfg.LogLevel))))
 
-	// Config via env with sensible defaults
-	addr := getenv("API_ADDR", ":8080")
-	poolSize := getEnvInt("POOL_SIZE", runtime.NumCPU())
-	maxWebhookRetries := getEnvInt("WEBHOOK_MAX_RETRIES", 5)
-	webhookTimeoutSec := getEnvInt("WEBHOOK_TIMEOUT_SEC", 10)
+	// Tracing; spans are only recorded when an OTLP endpoint is configured
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		tp, err := newTracerProvider(context.Background())
+		if err != nil {
+			slog.Error("failed to initialize tracing", "error", err)
+			os.Exit(1)
+		}
+		otel.SetTracerProvider(tp)
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := tp.Shutdown(ctx); err != nil {
+				slog.Warn("failed to flush traces", "error", err)
+			}
+		}()
+	}
 
 	// Core components
-	store := jobs.NewInMemoryStore()
-	sender := webhook.NewHTTPSender(time.Duration(webhookTimeoutSec)*time.Second, maxWebhookRetries)
-	streamer := jobs.NewLogStreamer()
-	runner := executor.NewExecRunner()
-	manager, err := jobs.NewManager(poolSize, store, sender, runner, streamer)
+	store, err := jobs.NewStore(cfg.Store.JobsConfig())
+	if err != nil {
+		slog.Error("failed to initialize store", "error", err)
+		os.Exit(1)
+	}
+	// Deferred before the manager's Stop, so it runs after it
+	if c, ok := store.(io.Closer); ok {
+		defer c.Close()
+	}
+	// Validated with the rest of the config
+	encoder, _ := webhook.EncoderFor(cfg.Webhook.Format)
+	senderOpts := []webhook.SenderOption{
+		webhook.WithEncoder(encoder),
+		webhook.WithGzip(cfg.Webhook.GzipMinBytes),
+		webhook.WithFollowRedirects(cfg.Webhook.MaxRedirects),
+		webhook.WithTransport(cfg.Webhook.TransportConfig()),
+	}
+	if !cfg.Webhook.AllowPrivate {
+		senderOpts = append(senderOpts, webhook.WithPrivateNetworkGuard())
+	}
+	sender := webhook.NewHTTPSender(time.Duration(cfg.Webhook.TimeoutSec)*time.Second, cfg.Webhook.MaxRetries, senderOpts...)
+	streamerOpts := []jobs.LogStreamerOption{
+		jobs.WithMaxSubscribersPerJob(cfg.MaxSubscribersPerJob),
+		jobs.WithBacklog(cfg.LogBacklogLines),
+		jobs.WithWriteTimeout(time.Duration(cfg.LogWriteTimeoutSec) * time.Second),
+	}
+	if cfg.LogBatchIntervalMs > 0 {
+		streamerOpts = append(streamerOpts, jobs.WithBatching(time.Duration(cfg.LogBatchIntervalMs)*time.Millisecond, cfg.LogBatchBytes))
+	}
+	if cfg.LogDir != "" {
+		sink, err := jobs.NewFileLogSink(cfg.LogDir)
+		if err != nil {
+			slog.Error("failed to initialize log sink", "error", err)
+			os.Exit(1)
+		}
+		streamerOpts = append(streamerOpts, jobs.WithLogSink(sink))
+	}
+	streamer := jobs.NewLogStreamer(streamerOpts...)
+	runnerOpts := []executor.RunnerOption{executor.WithExecutorConfig(&executor.ExecutorConfig{
+		DefaultCommand:         cfg.Executor.DefaultCommand,
+		CaptureOutput:          cfg.Executor.CaptureOutput,
+		MaxOutputSize:          cfg.Executor.MaxOutputSize,
+		MaxOutputLines:         cfg.Executor.MaxOutputLines,
+		StopStreamingAtLimit:   cfg.Executor.StopStreamingAtLimit,
+		LogOutput:              cfg.Executor.LogOutput,
+		LogOutputDropRate:      cfg.Executor.LogOutputDropRate,
+		StreamOutput:           cfg.Executor.StreamOutput,
+		StreamBufferSize:       cfg.Executor.StreamBufferSize,
+		VerboseLogging:         cfg.Executor.VerboseLogging,
+		AllowedWorkingDirRoots: cfg.Executor.AllowedWorkingDirRoots,
+		StartRetries:           cfg.Executor.StartRetries,
+		MaxExecutionTime:       time.Duration(cfg.Executor.MaxExecutionSec) * time.Second,
+		OutputCharset:          cfg.Executor.OutputCharset,
+		SanitizeUTF8:           cfg.Executor.SanitizeUTF8,
+		LinePrefix:             cfg.Executor.LinePrefix,
+		WorkingDirPerm:         cfg.Executor.WorkingDirMode(),
+		ScratchDir:             cfg.Executor.ScratchDir,
+		BaseWorkingDir:         cfg.Executor.BaseWorkingDir,
+		CommandPrefix:          cfg.Executor.CommandPrefix,
+		PathDirs:               cfg.Executor.PathDirs,
+		RedactPatterns:         cfg.Executor.RedactPatterns,
+	})}
+	runner := executor.NewExecRunner(runnerOpts...)
+	if cfg.Executor.Runner == "docker" {
+		if runner, err = executor.NewDockerRunner(cfg.Executor.Docker.RunnerConfig(), runnerOpts...); err != nil {
+			slog.Error("failed to initialize docker runner", "error", err)
+			os.Exit(1)
+		}
+	}
+	managerOpts := []jobs.ManagerOption{
+		jobs.WithQueueSize(cfg.QueueSize),
+		jobs.WithMaxJobs(cfg.MaxJobs),
+		jobs.WithArgLimits(jobs.ArgLimits{Count: cfg.MaxArgs, TotalBytes: cfg.MaxArgsBytes}),
+		jobs.WithDefaultTimeout(time.Duration(cfg.Executor.DefaultJobTimeoutSec) * time.Second),
+		jobs.WithMaxTimeout(time.Duration(cfg.Executor.MaxExecutionSec) * time.Second),
+		jobs.WithWebhookConcurrency(cfg.Webhook.Concurrency),
+		jobs.WithWebhookOutputLimit(cfg.Webhook.MaxOutputBytes),
+		jobs.WithWebhookMaxPayload(cfg.Webhook.MaxPayloadBytes),
+		jobs.WithStuckDetection(cfg.StuckConfig()),
+	}
+	if cfg.Webhook.ExcludeOutput {
+		managerOpts = append(managerOpts, jobs.WithoutWebhookOutput())
+	}
+	if cfg.Executor.DefaultCommand != "" {
+		managerOpts = append(managerOpts, jobs.WithDefaultCommandConfigured())
+	}
+	if cfg.RejectControlChars {
+		managerOpts = append(managerOpts, jobs.WithRejectControlChars())
+	}
+	if cfg.MetadataTemplating {
+		managerOpts = append(managerOpts, jobs.WithMetadataTemplating())
+	}
+	if cfg.TemplatesFile != "" {
+		templates, err := jobs.LoadTemplates(cfg.TemplatesFile)
+		if err != nil {
+			slog.Error("failed to load command templates", "error", err)
+			os.Exit(1)
+		}
+		managerOpts = append(managerOpts, jobs.WithTemplates(templates))
+	}
+	if cfg.Upload.S3Bucket != "" {
+		uploader, err := upload.NewS3Uploader(context.Background(), cfg.Upload.S3Config())
+		if err != nil {
+			slog.Error("failed to initialize output uploader", "error", err)
+			os.Exit(1)
+		}
+		managerOpts = append(managerOpts, jobs.WithOutputUploader(uploader))
+	}
+	if len(cfg.Metrics.MetadataLabels) > 0 {
+		jobsTotal := jobs.NewJobsTotal(cfg.Metrics.JobsTotalLabels())
+		prometheus.MustRegister(jobsTotal)
+		managerOpts = append(managerOpts, jobs.WithJobsTotal(jobsTotal))
+	}
+	manager, err := jobs.NewManager(cfg.PoolSize, store, sender, runner, streamer, managerOpts...)
 	if err != nil {
 		slog.Error("failed to initialize manager", "error", err)
 		os.Exit(1)
 	}
 	defer manager.Stop()
 
-	mux := httpapi.NewRouter(manager, streamer)
+	maxWait := time.Duration(cfg.MaxWaitSec) * time.Second
+	routerOpts := []httpapi.RouterOption{
+		httpapi.WithFrontendDir(cfg.FrontendDir),
+		httpapi.WithMaxWait(maxWait),
+		httpapi.WithMaxBodyBytes(int64(cfg.MaxBodyBytes)),
+	}
+	if cfg.TLS.ClientCA != "" {
+		routerOpts = append(routerOpts, httpapi.WithClientCertForMutations())
+	}
+	if !cfg.StrictJSON {
+		routerOpts = append(routerOpts, httpapi.WithLenientJSON())
+	}
+	if cfg.Auth.AdminAPIKey != "" {
+		routerOpts = append(routerOpts, httpapi.WithAdminAPIKey(cfg.Auth.AdminAPIKey))
+	}
+	mux := httpapi.NewRouter(manager, streamer, routerOpts...)
 
+	// Leave room for POST /jobs?wait=true to write its response after waiting
+	writeTimeout := max(60*time.Second, maxWait+10*time.Second)
 	srv := &http.Server{
-		Addr:              addr,
+		Addr:              cfg.Addr,
 		Handler:           mux,
 		ReadTimeout:       15 * time.Second,
 		ReadHeaderTimeout: 10 * time.Second,
-		WriteTimeout:      60 * time.Second,
+		WriteTimeout:      writeTimeout,
 		IdleTimeout:       120 * time.Second,
 	}
 
+	var tlsConfig *tls.Config
+	if cfg.TLS.Enabled() {
+		tlsConfig, err = newTLSConfig(cfg.TLS.MinVersion, cfg.TLS.ClientCA)
+		if err != nil {
+			slog.Error("invalid tls configuration", "error", err)
+			os.Exit(1)
+		}
+		srv.TLSConfig = tlsConfig
+	}
+
 	go func() {
-		slog.Info("server listening", "addr", addr)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		slog.Info("server listening", "addr", cfg.Addr, "tls", cfg.TLS.Enabled())
+		var err error
+		if cfg.TLS.Enabled() {
+			err = srv.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			slog.Error("server error", "error", err)
 			os.Exit(1)
 		}
 	}()
 
+	var grpcSrv *grpc.Server
+	if cfg.GRPCAddr != "" {
+		lis, err := net.Listen("tcp", cfg.GRPCAddr)
+		if err != nil {
+			slog.Error("failed to listen for grpc", "addr", cfg.GRPCAddr, "error", err)
+			os.Exit(1)
+		}
+		// gRPC serves with the same certificate, and the same client CA guards SubmitJob
+		var grpcOpts []grpc.ServerOption
+		if tlsConfig != nil {
+			cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+			if err != nil {
+				slog.Error("failed to load tls certificate", "error", err)
+				os.Exit(1)
+			}
+			grpcTLS := tlsConfig.Clone()
+			grpcTLS.Certificates = []tls.Certificate{cert}
+			grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(grpcTLS)))
+		}
+		if cfg.TLS.ClientCA != "" {
+			grpcOpts = append(grpcOpts, grpcapi.WithClientCertForMutations())
+		}
+		grpcSrv = grpcapi.NewServer(manager, streamer, grpcOpts...)
+		go func() {
+			slog.Info("grpc server listening", "addr", cfg.GRPCAddr, "tls", tlsConfig != nil)
+			if err := grpcSrv.Serve(lis); err != nil {
+				slog.Error("grpc server error", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
 	// Graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -70,24 +275,66 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		slog.Error("server shutdown error", "error", err)
 	}
+	if grpcSrv != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcSrv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			slog.Error("grpc server shutdown timed out")
+			grpcSrv.Stop()
+		}
+	}
 }
 
-func getenv(key, def string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// newTracerProvider exports spans over OTLP/HTTP, configured by the standard
+// OTEL_EXPORTER_OTLP_* and OTEL_SERVICE_NAME environment variables.
+func newTracerProvider(ctx context.Context) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
 	}
-	return def
+	return sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter)), nil
 }
 
-func getEnvInt(key string, def int) int {
-	if v := os.Getenv(key); v != "" {
-		var out int
-		_, err := fmt.Sscanf(v, "%d", &out)
-		if err == nil {
-			return out
+// newTLSConfig builds the server TLS config. When clientCA is set, client
+// certificates are verified if presented; the router decides which routes require one.
+func newTLSConfig(minVersion, clientCA string) (*tls.Config, error) {
+	cfg := &tls.Config{}
+	switch minVersion {
+	case "1.2":
+		cfg.MinVersion = tls.VersionTLS12
+	case "1.3":
+		cfg.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported TLS_MIN_VERSION %q (want 1.2 or 1.3)", minVersion)
+	}
+
+	if clientCA != "" {
+		pem, err := os.ReadFile(clientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA %s", clientCA)
 		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return cfg, nil
+}
+
+// newLogHandler returns a text handler for format "text" and a JSON handler otherwise.
+func newLogHandler(w io.Writer, format string, level slog.Level) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if strings.EqualFold(format, "text") {
+		return slog.NewTextHandler(w, opts)
 	}
-	return def
+	return slog.NewJSONHandler(w, opts)
 }
 
 func parseLogLevel(s string) slog.Level {
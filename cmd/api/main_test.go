@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewLogHandler_Format(t *testing.T) {
+	var buf bytes.Buffer
+	slog.New(newLogHandler(&buf, "json", slog.LevelInfo)).Info("job started", "job_id", "abc")
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("json format did not write JSON: %q: %v", buf.String(), err)
+	}
+	if entry["msg"] != "job started" || entry["job_id"] != "abc" {
+		t.Fatalf("unexpected JSON entry: %v", entry)
+	}
+
+	buf.Reset()
+	slog.New(newLogHandler(&buf, "TEXT", slog.LevelInfo)).Info("job started", "job_id", "abc")
+	line := buf.String()
+	if !strings.Contains(line, `msg="job started"`) || !strings.Contains(line, "job_id=abc") {
+		t.Fatalf("text format did not write key=value pairs: %q", line)
+	}
+
+	buf.Reset()
+	slog.New(newLogHandler(&buf, "text", slog.LevelWarn)).Info("dropped")
+	if buf.Len() != 0 {
+		t.Fatalf("expected info to be filtered at warn level, got %q", buf.String())
+	}
+}
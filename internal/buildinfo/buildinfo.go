@@ -0,0 +1,59 @@
+// Package buildinfo describes the running build. Version, Commit and BuildTime
+// are meant to be set at link time:
+//
+//	go build -ldflags "-X github.com/paulgrammer/childprocess/internal/buildinfo.Version=v1.2.3 \
+//	  -X github.com/paulgrammer/childprocess/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/paulgrammer/childprocess/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Left unset, the commit and build time fall back to the VCS details the Go
+// toolchain stamps into the binary, if any.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info is the build of the running binary, as served on GET /version.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the running build's details.
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = s.Value
+			}
+		}
+	}
+	return info
+}
+
+// buildInfo is always 1; the build is in its labels.
+var buildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "childprocess_build_info",
+	Help: "Build of the running server, as labels; the value is always 1",
+}, []string{"version", "commit", "build_time", "go_version"})
+
+func init() {
+	info := Get()
+	buildInfo.WithLabelValues(info.Version, info.Commit, info.BuildTime, info.GoVersion).Set(1)
+	prometheus.MustRegister(buildInfo)
+}
@@ -0,0 +1,30 @@
+// Package charset resolves the character set names accepted in configuration
+// and job requests. It depends on nothing else in this module, so config and
+// request validation can check names without importing the executor.
+package charset
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+// Lookup returns the encoding registered under an IANA charset name or alias,
+// such as "ISO-8859-1", "latin1" or "windows-1252".
+func Lookup(name string) (encoding.Encoding, error) {
+	enc, err := ianaindex.IANA.Encoding(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown charset %q", name)
+	}
+	if enc == nil {
+		return nil, fmt.Errorf("charset %q is not supported", name)
+	}
+	return enc, nil
+}
+
+// IsUTF8 reports whether name is UTF-8, which needs no decoding.
+func IsUTF8(name string) bool {
+	return strings.EqualFold(name, "utf-8") || strings.EqualFold(name, "utf8")
+}
@@ -0,0 +1,20 @@
+package charset
+
+import "testing"
+
+func TestLookup(t *testing.T) {
+	if _, err := Lookup("latin1"); err != nil {
+		t.Fatalf("expected latin1 alias to resolve, got %v", err)
+	}
+	if _, err := Lookup("no-such-charset"); err == nil {
+		t.Fatal("expected an unknown charset to fail")
+	}
+}
+
+func TestIsUTF8(t *testing.T) {
+	for name, want := range map[string]bool{"UTF-8": true, "utf8": true, "latin1": false} {
+		if got := IsUTF8(name); got != want {
+			t.Errorf("IsUTF8(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
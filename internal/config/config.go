@@ -0,0 +1,670 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paulgrammer/childprocess/internal/charset"
+	"github.com/paulgrammer/childprocess/internal/executor"
+	"github.com/paulgrammer/childprocess/internal/jobs"
+	"github.com/paulgrammer/childprocess/internal/upload"
+	"github.com/paulgrammer/childprocess/internal/webhook"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete server configuration. It is loaded from an optional
+// YAML or JSON file and then overridden by environment variables.
+type Config struct {
+	Addr        string `yaml:"addr"`
+	GRPCAddr    string `yaml:"grpc_addr"`
+	LogLevel    string `yaml:"log_level"`
+	LogFormat   string `yaml:"log_format"` // json or text
+	PoolSize    int    `yaml:"pool_size"`
+	QueueSize   int    `yaml:"queue_size"`
+	FrontendDir string `yaml:"frontend_dir"`
+	LogDir      string `yaml:"log_dir"`
+	// MaxWaitSec caps how long POST /jobs?wait=true holds the request
+	MaxWaitSec int `yaml:"max_wait_sec"`
+	// MaxBodyBytes caps the size of a job submission
+	MaxBodyBytes int `yaml:"max_body_bytes"`
+	// MaxArgs and MaxArgsBytes cap how many args a job may have and their combined size
+	MaxArgs      int `yaml:"max_args"`
+	MaxArgsBytes int `yaml:"max_args_bytes"`
+	// MaxJobs caps queued plus running jobs; 0 means no limit
+	MaxJobs int `yaml:"max_jobs"`
+	// MaxSubscribersPerJob caps log stream connections per job; 0 means no limit
+	MaxSubscribersPerJob int `yaml:"max_subscribers_per_job"`
+	// LogBatchIntervalMs merges a job's streamed output into one message per
+	// interval, or per LogBatchBytes if that fills first; 0 sends every write
+	LogBatchIntervalMs int `yaml:"log_batch_interval_ms"`
+	LogBatchBytes      int `yaml:"log_batch_bytes"`
+	// LogBacklogLines is how much of a running job's output GET /jobs/{id}/logs?tail= can replay
+	LogBacklogLines int `yaml:"log_backlog_lines"`
+	// LogWriteTimeoutSec bounds each write to a log stream connection; 0 means no limit
+	LogWriteTimeoutSec int `yaml:"log_write_timeout_sec"`
+	// TemplatesFile is a YAML or JSON file of named command templates
+	TemplatesFile string `yaml:"templates_file"`
+	// RejectControlChars refuses jobs whose command or args contain control characters
+	RejectControlChars bool `yaml:"reject_control_chars"`
+	// MetadataTemplating expands {{.Metadata.key}} in job commands and args
+	MetadataTemplating bool `yaml:"metadata_templating"`
+	// StrictJSON rejects job submissions with unknown fields instead of ignoring them
+	StrictJSON bool `yaml:"strict_json"`
+	// A job in progress for StuckTimeoutMultiple times its timeout, or for
+	// StuckThresholdSec whatever its timeout, is reported stuck and, with
+	// FailStuckJobs, failed; 0 disables either limit
+	StuckTimeoutMultiple  float64 `yaml:"stuck_timeout_multiple"`
+	StuckThresholdSec     int     `yaml:"stuck_threshold_sec"`
+	StuckCheckIntervalSec int     `yaml:"stuck_check_interval_sec"`
+	FailStuckJobs         bool    `yaml:"fail_stuck_jobs"`
+
+	Webhook  WebhookConfig  `yaml:"webhook"`
+	Executor ExecutorConfig `yaml:"executor"`
+	TLS      TLSConfig      `yaml:"tls"`
+	Store    StoreConfig    `yaml:"store"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Upload   UploadConfig   `yaml:"upload"`
+	Metrics  MetricsConfig  `yaml:"metrics"`
+}
+
+// StuckConfig converts the stuck job settings to the jobs.WithStuckDetection form.
+func (c Config) StuckConfig() jobs.StuckConfig {
+	return jobs.StuckConfig{
+		TimeoutMultiple: c.StuckTimeoutMultiple,
+		Threshold:       time.Duration(c.StuckThresholdSec) * time.Second,
+		Interval:        time.Duration(c.StuckCheckIntervalSec) * time.Second,
+		Fail:            c.FailStuckJobs,
+	}
+}
+
+// MetricsConfig promotes job metadata keys to labels of the jobs_total counter,
+// which is only exported when MetadataLabels is set.
+type MetricsConfig struct {
+	MetadataLabels []string `yaml:"metadata_labels"`
+	// LabelValues allowlists the values kept per key; others count as "other".
+	// Keys without an allowlist have their values hashed into HashBuckets buckets.
+	LabelValues map[string][]string `yaml:"label_values"`
+	HashBuckets int                 `yaml:"hash_buckets"`
+}
+
+// JobsTotalLabels converts the settings to the jobs.NewJobsTotal form.
+func (m MetricsConfig) JobsTotalLabels() jobs.MetadataLabels {
+	return jobs.MetadataLabels{Keys: m.MetadataLabels, Values: m.LabelValues, HashBuckets: m.HashBuckets}
+}
+
+// UploadConfig archives job output in S3 when S3Bucket is set.
+type UploadConfig struct {
+	S3Bucket string `yaml:"s3_bucket"`
+	S3Prefix string `yaml:"s3_prefix"`
+	S3Region string `yaml:"s3_region"`
+	// S3Endpoint points at an S3-compatible service instead of AWS
+	S3Endpoint string `yaml:"s3_endpoint"`
+}
+
+// S3Config converts the settings to the upload.NewS3Uploader form.
+func (u UploadConfig) S3Config() upload.S3Config {
+	return upload.S3Config{Bucket: u.S3Bucket, Prefix: u.S3Prefix, Region: u.S3Region, Endpoint: u.S3Endpoint}
+}
+
+type WebhookConfig struct {
+	TimeoutSec  int `yaml:"timeout_sec"`
+	MaxRetries  int `yaml:"max_retries"`
+	Concurrency int `yaml:"concurrency"`
+	// GzipMinBytes compresses payloads of at least this size; 0 disables compression
+	GzipMinBytes int `yaml:"gzip_min_bytes"`
+	// AllowPrivate permits deliveries to loopback, private and link-local addresses
+	AllowPrivate bool `yaml:"allow_private"`
+	// MaxRedirects is how many 307/308 redirects to follow; 0 fails deliveries that redirect
+	MaxRedirects int `yaml:"max_redirects"`
+	// ExcludeOutput keeps stdout and stderr out of every webhook payload
+	ExcludeOutput bool `yaml:"exclude_output"`
+	// MaxOutputBytes truncates the stdout and stderr embedded in webhooks; 0 means no extra cap
+	MaxOutputBytes int `yaml:"max_output_bytes"`
+	// MaxPayloadBytes truncates embedded output to keep payloads under this size; 0 means no limit
+	MaxPayloadBytes int `yaml:"max_payload_bytes"`
+	// Connection pooling of deliveries; MaxConnsPerHost 0 means no limit
+	MaxIdleConnsPerHost int  `yaml:"max_idle_conns_per_host"`
+	MaxConnsPerHost     int  `yaml:"max_conns_per_host"`
+	IdleConnTimeoutSec  int  `yaml:"idle_conn_timeout_sec"`
+	DisableHTTP2        bool `yaml:"disable_http2"`
+	// Format is how payloads are encoded: "json" or "form" (application/x-www-form-urlencoded)
+	Format string `yaml:"format"`
+}
+
+// TransportConfig converts the pooling settings to the webhook.WithTransport form.
+func (w WebhookConfig) TransportConfig() webhook.TransportConfig {
+	return webhook.TransportConfig{
+		MaxIdleConnsPerHost: w.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     w.MaxConnsPerHost,
+		IdleConnTimeout:     time.Duration(w.IdleConnTimeoutSec) * time.Second,
+		DisableHTTP2:        w.DisableHTTP2,
+	}
+}
+
+type ExecutorConfig struct {
+	DefaultCommand         string   `yaml:"default_command"`
+	CaptureOutput          bool     `yaml:"capture_output"`
+	MaxOutputSize          int      `yaml:"max_output_size"`
+	MaxOutputLines         int      `yaml:"max_output_lines"`
+	StopStreamingAtLimit   bool     `yaml:"stop_streaming_at_limit"`
+	LogOutput              bool     `yaml:"log_output"`
+	LogOutputDropRate      float64  `yaml:"log_output_drop_rate"` // fraction of output logs dropped, 0-1
+	StreamOutput           bool     `yaml:"stream_output"`
+	VerboseLogging         bool     `yaml:"verbose_logging"`
+	AllowedWorkingDirRoots []string `yaml:"allowed_working_dir_roots"`
+	StartRetries           int      `yaml:"start_retries"`
+	// StreamBufferSize is the output per stream queued for a slow log consumer before it is dropped
+	StreamBufferSize int `yaml:"stream_buffer_size"`
+	// MaxExecutionSec kills commands that run longer than this; 0 means unlimited
+	MaxExecutionSec int `yaml:"max_execution_sec"`
+	// DefaultJobTimeoutSec is the timeout of jobs that set no timeout_seconds; 0 means none
+	DefaultJobTimeoutSec int `yaml:"default_job_timeout_sec"`
+	// OutputCharset is the IANA charset commands write in, converted to UTF-8 when stored
+	OutputCharset string `yaml:"output_charset"`
+	// SanitizeUTF8 replaces invalid UTF-8 in stored output with U+FFFD
+	SanitizeUTF8 bool `yaml:"sanitize_utf8"`
+	// LinePrefix is prepended to each output line; supports {jobID} and {ts}
+	LinePrefix string `yaml:"line_prefix"`
+	// WorkingDirPerm is the octal mode for working directories jobs ask to create
+	WorkingDirPerm string `yaml:"working_dir_perm"`
+	// ScratchDir holds scratch directories for jobs that ask for one; empty uses the OS temp dir
+	ScratchDir string `yaml:"scratch_dir"`
+	// BaseWorkingDir confines job working directories; jobs without one get {base}/{job id}
+	BaseWorkingDir string `yaml:"base_working_dir"`
+	// CommandPrefix wraps every command, e.g. [/usr/bin/timeout, "60"]
+	CommandPrefix []string `yaml:"command_prefix"`
+	// PathDirs, if set, replaces the server's PATH when resolving commands
+	PathDirs []string `yaml:"path_dirs"`
+	// RedactPatterns are regexps masked with *** in captured and streamed output
+	RedactPatterns []string `yaml:"redact_patterns"`
+	// Runner is "exec" to run commands on the host or "docker" to run each in a container
+	Runner string       `yaml:"runner"`
+	Docker DockerConfig `yaml:"docker"`
+}
+
+// DockerConfig configures the containers of the docker runner.
+type DockerConfig struct {
+	Host  string `yaml:"host"`
+	Image string `yaml:"image"`
+	// Mounts are bind mounts in "host:container[:ro]" form
+	Mounts    []string `yaml:"mounts"`
+	MemoryMB  int      `yaml:"memory_mb"`
+	CPUs      float64  `yaml:"cpus"`
+	PidsLimit int      `yaml:"pids_limit"`
+	Network   string   `yaml:"network"`
+}
+
+// RunnerConfig converts the settings to the executor.NewDockerRunner form.
+func (d DockerConfig) RunnerConfig() executor.DockerConfig {
+	return executor.DockerConfig{
+		Host:        d.Host,
+		Image:       d.Image,
+		Mounts:      d.Mounts,
+		MemoryBytes: int64(d.MemoryMB) << 20,
+		NanoCPUs:    int64(d.CPUs * 1e9),
+		PidsLimit:   int64(d.PidsLimit),
+		Network:     d.Network,
+	}
+}
+
+// WorkingDirMode returns WorkingDirPerm as a file mode. Validate rejects values it cannot parse.
+func (e ExecutorConfig) WorkingDirMode() os.FileMode {
+	mode, _ := strconv.ParseUint(e.WorkingDirPerm, 8, 32)
+	return os.FileMode(mode)
+}
+
+type TLSConfig struct {
+	CertFile   string `yaml:"cert_file"`
+	KeyFile    string `yaml:"key_file"`
+	MinVersion string `yaml:"min_version"`
+	ClientCA   string `yaml:"client_ca"`
+}
+
+// Enabled reports whether the server should listen with TLS.
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+type AuthConfig struct {
+	// AdminAPIKey enables the /admin routes; empty disables them
+	AdminAPIKey string `yaml:"admin_api_key"`
+}
+
+type StoreConfig struct {
+	Backend string `yaml:"backend"`
+	// Path is the database file of the sqlite backend
+	Path string `yaml:"path"`
+}
+
+// JobsConfig converts the settings to the jobs.NewStore form.
+func (s StoreConfig) JobsConfig() jobs.StoreConfig {
+	return jobs.StoreConfig{Backend: s.Backend, Path: s.Path}
+}
+
+// Default returns the configuration used when neither a file nor env vars set a value.
+func Default() Config {
+	return Config{
+		Addr:        ":8080",
+		LogLevel:    "INFO",
+		LogFormat:   "json",
+		PoolSize:    runtime.NumCPU(),
+		QueueSize:   1024,
+		FrontendDir: "./frontend",
+
+		MaxWaitSec:   50,
+		MaxBodyBytes: 1 << 20,
+		MaxArgs:      jobs.MaxArgs,
+		MaxArgsBytes: jobs.MaxArgsBytes,
+
+		MaxSubscribersPerJob: 100,
+		LogBacklogLines:      1000,
+		LogWriteTimeoutSec:   5,
+		StrictJSON:           true,
+
+		StuckTimeoutMultiple:  2,
+		StuckCheckIntervalSec: 60,
+		Webhook: WebhookConfig{
+			TimeoutSec:  10,
+			MaxRetries:  5,
+			Concurrency: 8,
+
+			MaxIdleConnsPerHost: 8,
+			IdleConnTimeoutSec:  90,
+
+			Format: "json",
+		},
+		Executor: ExecutorConfig{
+			CaptureOutput: true,
+			MaxOutputSize: 1024 * 1024,
+			StartRetries:  3,
+			SanitizeUTF8:  true,
+
+			WorkingDirPerm: "0750",
+			Runner:         "exec",
+		},
+		TLS: TLSConfig{
+			MinVersion: "1.2",
+		},
+		Store: StoreConfig{
+			Backend: "memory",
+		},
+	}
+}
+
+// Load builds the configuration from defaults, the file at path (if non-empty)
+// and environment variables, in increasing order of precedence. The result is
+// validated and every problem found is reported in the returned error.
+func Load(path string) (Config, error) {
+	cfg := Default()
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read config file: %w", err)
+		}
+		// YAML is a superset of JSON, so one decoder handles both formats
+		if err := yaml.Unmarshal(raw, &cfg); err != nil {
+			return cfg, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	problems := cfg.applyEnv(os.LookupEnv)
+	if err := cfg.Validate(); err != nil {
+		problems = append(problems, err)
+	}
+	if len(problems) > 0 {
+		return cfg, fmt.Errorf("invalid configuration:\n%w", errors.Join(problems...))
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides fields from environment variables and returns any values that failed to parse.
+func (c *Config) applyEnv(lookup func(string) (string, bool)) []error {
+	var problems []error
+	str := func(key string, dst *string) {
+		if v, ok := lookup(key); ok && v != "" {
+			*dst = v
+		}
+	}
+	num := func(key string, dst *int) {
+		if v, ok := lookup(key); ok && v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("%s: %q is not an integer", key, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	flag := func(key string, dst *bool) {
+		if v, ok := lookup(key); ok && v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("%s: %q is not a boolean", key, v))
+				return
+			}
+			*dst = b
+		}
+	}
+
+	str("API_ADDR", &c.Addr)
+	str("GRPC_ADDR", &c.GRPCAddr)
+	str("LOG_LEVEL", &c.LogLevel)
+	str("LOG_FORMAT", &c.LogFormat)
+	num("POOL_SIZE", &c.PoolSize)
+	num("QUEUE_SIZE", &c.QueueSize)
+	num("MAX_JOBS", &c.MaxJobs)
+	num("MAX_WAIT_SEC", &c.MaxWaitSec)
+	num("MAX_BODY_BYTES", &c.MaxBodyBytes)
+	num("MAX_ARGS", &c.MaxArgs)
+	num("MAX_ARGS_BYTES", &c.MaxArgsBytes)
+	str("FRONTEND_DIR", &c.FrontendDir)
+	str("LOG_DIR", &c.LogDir)
+	num("MAX_SUBSCRIBERS_PER_JOB", &c.MaxSubscribersPerJob)
+	num("LOG_BATCH_INTERVAL_MS", &c.LogBatchIntervalMs)
+	num("LOG_BATCH_BYTES", &c.LogBatchBytes)
+	num("LOG_BACKLOG_LINES", &c.LogBacklogLines)
+	num("LOG_WRITE_TIMEOUT_SEC", &c.LogWriteTimeoutSec)
+	str("TEMPLATES_FILE", &c.TemplatesFile)
+	flag("REJECT_CONTROL_CHARS", &c.RejectControlChars)
+	flag("METADATA_TEMPLATING", &c.MetadataTemplating)
+	flag("STRICT_JSON", &c.StrictJSON)
+	if v, ok := lookup("STUCK_TIMEOUT_MULTIPLE"); ok && v != "" {
+		multiple, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("STUCK_TIMEOUT_MULTIPLE: %q is not a number", v))
+		} else {
+			c.StuckTimeoutMultiple = multiple
+		}
+	}
+	num("STUCK_THRESHOLD_SEC", &c.StuckThresholdSec)
+	num("STUCK_CHECK_INTERVAL_SEC", &c.StuckCheckIntervalSec)
+	flag("FAIL_STUCK_JOBS", &c.FailStuckJobs)
+
+	num("WEBHOOK_TIMEOUT_SEC", &c.Webhook.TimeoutSec)
+	num("WEBHOOK_MAX_RETRIES", &c.Webhook.MaxRetries)
+	num("WEBHOOK_CONCURRENCY", &c.Webhook.Concurrency)
+	num("WEBHOOK_GZIP_MIN_BYTES", &c.Webhook.GzipMinBytes)
+	flag("WEBHOOK_ALLOW_PRIVATE", &c.Webhook.AllowPrivate)
+	num("WEBHOOK_MAX_REDIRECTS", &c.Webhook.MaxRedirects)
+	flag("WEBHOOK_EXCLUDE_OUTPUT", &c.Webhook.ExcludeOutput)
+	num("WEBHOOK_MAX_OUTPUT_BYTES", &c.Webhook.MaxOutputBytes)
+	num("WEBHOOK_MAX_PAYLOAD_BYTES", &c.Webhook.MaxPayloadBytes)
+	num("WEBHOOK_MAX_IDLE_CONNS_PER_HOST", &c.Webhook.MaxIdleConnsPerHost)
+	num("WEBHOOK_MAX_CONNS_PER_HOST", &c.Webhook.MaxConnsPerHost)
+	num("WEBHOOK_IDLE_CONN_TIMEOUT_SEC", &c.Webhook.IdleConnTimeoutSec)
+	flag("WEBHOOK_DISABLE_HTTP2", &c.Webhook.DisableHTTP2)
+	str("WEBHOOK_FORMAT", &c.Webhook.Format)
+
+	str("DEFAULT_COMMAND", &c.Executor.DefaultCommand)
+	flag("CAPTURE_OUTPUT", &c.Executor.CaptureOutput)
+	num("MAX_OUTPUT_SIZE", &c.Executor.MaxOutputSize)
+	num("MAX_OUTPUT_LINES", &c.Executor.MaxOutputLines)
+	flag("STOP_STREAMING_AT_LIMIT", &c.Executor.StopStreamingAtLimit)
+	flag("LOG_OUTPUT", &c.Executor.LogOutput)
+	if v, ok := lookup("LOG_OUTPUT_DROP_RATE"); ok && v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("LOG_OUTPUT_DROP_RATE: %q is not a number", v))
+		} else {
+			c.Executor.LogOutputDropRate = rate
+		}
+	}
+	flag("STREAM_OUTPUT", &c.Executor.StreamOutput)
+	num("STREAM_BUFFER_SIZE", &c.Executor.StreamBufferSize)
+	flag("VERBOSE_LOGGING", &c.Executor.VerboseLogging)
+	num("START_RETRIES", &c.Executor.StartRetries)
+	num("MAX_EXECUTION_SEC", &c.Executor.MaxExecutionSec)
+	num("DEFAULT_JOB_TIMEOUT_SEC", &c.Executor.DefaultJobTimeoutSec)
+	str("OUTPUT_CHARSET", &c.Executor.OutputCharset)
+	flag("SANITIZE_UTF8", &c.Executor.SanitizeUTF8)
+	str("LINE_PREFIX", &c.Executor.LinePrefix)
+	str("WORKING_DIR_PERM", &c.Executor.WorkingDirPerm)
+	str("SCRATCH_DIR", &c.Executor.ScratchDir)
+	str("BASE_WORKING_DIR", &c.Executor.BaseWorkingDir)
+	if v, ok := lookup("COMMAND_PREFIX"); ok && v != "" {
+		c.Executor.CommandPrefix = strings.Fields(v)
+	}
+	if v, ok := lookup("ALLOWED_WORKING_DIR_ROOTS"); ok && v != "" {
+		c.Executor.AllowedWorkingDirRoots = filepath.SplitList(v)
+	}
+	if v, ok := lookup("PATH_DIRS"); ok && v != "" {
+		c.Executor.PathDirs = filepath.SplitList(v)
+	}
+	if v, ok := lookup("REDACT_PATTERNS"); ok && v != "" {
+		// One per line, as regexps may contain any separator
+		c.Executor.RedactPatterns = strings.Split(strings.TrimSpace(v), "\n")
+	}
+	str("RUNNER", &c.Executor.Runner)
+	str("DOCKER_HOST", &c.Executor.Docker.Host)
+	str("DOCKER_IMAGE", &c.Executor.Docker.Image)
+	if v, ok := lookup("DOCKER_MOUNTS"); ok && v != "" {
+		c.Executor.Docker.Mounts = strings.Split(v, ",")
+	}
+	num("DOCKER_MEMORY_MB", &c.Executor.Docker.MemoryMB)
+	if v, ok := lookup("DOCKER_CPUS"); ok && v != "" {
+		cpus, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("DOCKER_CPUS: %q is not a number", v))
+		} else {
+			c.Executor.Docker.CPUs = cpus
+		}
+	}
+	num("DOCKER_PIDS_LIMIT", &c.Executor.Docker.PidsLimit)
+	str("DOCKER_NETWORK", &c.Executor.Docker.Network)
+
+	str("TLS_CERT_FILE", &c.TLS.CertFile)
+	str("TLS_KEY_FILE", &c.TLS.KeyFile)
+	str("TLS_MIN_VERSION", &c.TLS.MinVersion)
+	str("TLS_CLIENT_CA", &c.TLS.ClientCA)
+
+	str("STORE_BACKEND", &c.Store.Backend)
+	str("STORE_PATH", &c.Store.Path)
+
+	str("ADMIN_API_KEY", &c.Auth.AdminAPIKey)
+
+	str("UPLOAD_S3_BUCKET", &c.Upload.S3Bucket)
+	str("UPLOAD_S3_PREFIX", &c.Upload.S3Prefix)
+	str("UPLOAD_S3_REGION", &c.Upload.S3Region)
+	str("UPLOAD_S3_ENDPOINT", &c.Upload.S3Endpoint)
+
+	if v, ok := lookup("METRICS_METADATA_LABELS"); ok && v != "" {
+		c.Metrics.MetadataLabels = strings.Split(v, ",")
+	}
+	if v, ok := lookup("METRICS_LABEL_VALUES"); ok && v != "" {
+		// key=value,value;key=value
+		c.Metrics.LabelValues = make(map[string][]string)
+		for _, entry := range strings.Split(v, ";") {
+			key, values, found := strings.Cut(entry, "=")
+			if !found || key == "" {
+				problems = append(problems, fmt.Errorf("METRICS_LABEL_VALUES: %q is not key=value,value", entry))
+				continue
+			}
+			c.Metrics.LabelValues[key] = strings.Split(values, ",")
+		}
+	}
+	num("METRICS_HASH_BUCKETS", &c.Metrics.HashBuckets)
+	return problems
+}
+
+// Validate reports every problem with the configuration at once.
+func (c Config) Validate() error {
+	var problems []error
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if c.Addr == "" {
+		add("addr must not be empty")
+	}
+	switch strings.ToUpper(c.LogLevel) {
+	case "DEBUG", "INFO", "WARN", "ERROR":
+	default:
+		add("log_level %q must be one of DEBUG, INFO, WARN, ERROR", c.LogLevel)
+	}
+	switch strings.ToLower(c.LogFormat) {
+	case "json", "text":
+	default:
+		add("log_format %q must be json or text", c.LogFormat)
+	}
+	if c.PoolSize <= 0 {
+		add("pool_size must be > 0, got %d", c.PoolSize)
+	}
+	if c.QueueSize <= 0 {
+		add("queue_size must be > 0, got %d", c.QueueSize)
+	}
+	if c.MaxJobs < 0 {
+		add("max_jobs must be >= 0, got %d", c.MaxJobs)
+	}
+	if c.MaxWaitSec <= 0 {
+		add("max_wait_sec must be > 0, got %d", c.MaxWaitSec)
+	}
+	if c.MaxBodyBytes <= 0 {
+		add("max_body_bytes must be > 0, got %d", c.MaxBodyBytes)
+	}
+	if c.MaxArgs <= 0 {
+		add("max_args must be > 0, got %d", c.MaxArgs)
+	}
+	if c.MaxArgsBytes <= 0 {
+		add("max_args_bytes must be > 0, got %d", c.MaxArgsBytes)
+	}
+	if c.MaxSubscribersPerJob < 0 {
+		add("max_subscribers_per_job must be >= 0, got %d", c.MaxSubscribersPerJob)
+	}
+	if c.LogBatchIntervalMs < 0 {
+		add("log_batch_interval_ms must be >= 0, got %d", c.LogBatchIntervalMs)
+	}
+	if c.LogBatchBytes < 0 {
+		add("log_batch_bytes must be >= 0, got %d", c.LogBatchBytes)
+	}
+	if c.LogBacklogLines < 0 {
+		add("log_backlog_lines must be >= 0, got %d", c.LogBacklogLines)
+	}
+	if c.LogWriteTimeoutSec < 0 {
+		add("log_write_timeout_sec must be >= 0, got %d", c.LogWriteTimeoutSec)
+	}
+	// Below 1 would flag jobs that are still within their timeout
+	if c.StuckTimeoutMultiple != 0 && c.StuckTimeoutMultiple < 1 {
+		add("stuck_timeout_multiple must be 0 or >= 1, got %g", c.StuckTimeoutMultiple)
+	}
+	if c.StuckThresholdSec < 0 {
+		add("stuck_threshold_sec must be >= 0, got %d", c.StuckThresholdSec)
+	}
+	if c.StuckCheckIntervalSec < 1 {
+		add("stuck_check_interval_sec must be >= 1, got %d", c.StuckCheckIntervalSec)
+	}
+
+	if c.Webhook.TimeoutSec <= 0 {
+		add("webhook.timeout_sec must be > 0, got %d", c.Webhook.TimeoutSec)
+	}
+	if c.Webhook.MaxRetries < 0 {
+		add("webhook.max_retries must be >= 0, got %d", c.Webhook.MaxRetries)
+	}
+	if c.Webhook.Concurrency <= 0 {
+		add("webhook.concurrency must be > 0, got %d", c.Webhook.Concurrency)
+	}
+	if c.Webhook.GzipMinBytes < 0 {
+		add("webhook.gzip_min_bytes must be >= 0, got %d", c.Webhook.GzipMinBytes)
+	}
+	if c.Webhook.MaxRedirects < 0 {
+		add("webhook.max_redirects must be >= 0, got %d", c.Webhook.MaxRedirects)
+	}
+	if c.Webhook.MaxOutputBytes < 0 {
+		add("webhook.max_output_bytes must be >= 0, got %d", c.Webhook.MaxOutputBytes)
+	}
+	if c.Webhook.MaxPayloadBytes < 0 {
+		add("webhook.max_payload_bytes must be >= 0, got %d", c.Webhook.MaxPayloadBytes)
+	}
+	if c.Webhook.MaxIdleConnsPerHost < 1 {
+		add("webhook.max_idle_conns_per_host must be >= 1, got %d", c.Webhook.MaxIdleConnsPerHost)
+	}
+	if c.Webhook.MaxConnsPerHost < 0 {
+		add("webhook.max_conns_per_host must be >= 0, got %d", c.Webhook.MaxConnsPerHost)
+	}
+	if c.Webhook.IdleConnTimeoutSec < 1 {
+		add("webhook.idle_conn_timeout_sec must be >= 1, got %d", c.Webhook.IdleConnTimeoutSec)
+	}
+	if _, err := webhook.EncoderFor(c.Webhook.Format); err != nil {
+		add("webhook.format %q must be json or form", c.Webhook.Format)
+	}
+
+	if c.Executor.MaxOutputSize < 0 {
+		add("executor.max_output_size must be >= 0, got %d", c.Executor.MaxOutputSize)
+	}
+	if c.Executor.MaxOutputLines < 0 {
+		add("executor.max_output_lines must be >= 0, got %d", c.Executor.MaxOutputLines)
+	}
+	if r := c.Executor.LogOutputDropRate; r < 0 || r > 1 {
+		add("executor.log_output_drop_rate must be between 0 and 1, got %g", r)
+	}
+	if c.Executor.StreamBufferSize < 0 {
+		add("executor.stream_buffer_size must be >= 0, got %d", c.Executor.StreamBufferSize)
+	}
+	if c.Executor.StartRetries < 0 {
+		add("executor.start_retries must be >= 0, got %d", c.Executor.StartRetries)
+	}
+	if c.Executor.MaxExecutionSec < 0 {
+		add("executor.max_execution_sec must be >= 0, got %d", c.Executor.MaxExecutionSec)
+	}
+	if d := c.Executor.DefaultJobTimeoutSec; d < 0 {
+		add("executor.default_job_timeout_sec must be >= 0, got %d", d)
+	} else if max := c.Executor.MaxExecutionSec; max > 0 && d > max {
+		add("executor.default_job_timeout_sec (%d) must not exceed executor.max_execution_sec (%d)", d, max)
+	}
+	if mode, err := strconv.ParseUint(c.Executor.WorkingDirPerm, 8, 32); err != nil || mode == 0 || mode > 0o777 {
+		add("executor.working_dir_perm %q must be an octal mode such as 0750", c.Executor.WorkingDirPerm)
+	}
+	if c.Executor.OutputCharset != "" {
+		if _, err := charset.Lookup(c.Executor.OutputCharset); err != nil {
+			add("executor.output_charset: %v", err)
+		}
+	}
+	if _, err := executor.CompileRedactPatterns(c.Executor.RedactPatterns); err != nil {
+		add("executor.redact_patterns: %v", err)
+	}
+	if dir := c.Executor.BaseWorkingDir; dir != "" && !filepath.IsAbs(dir) {
+		add("executor.base_working_dir %q must be an absolute path", dir)
+	}
+	for _, dir := range c.Executor.PathDirs {
+		if !filepath.IsAbs(dir) {
+			add("executor.path_dirs entry %q must be an absolute path", dir)
+		}
+	}
+	switch c.Executor.Runner {
+	case "exec":
+	case "docker":
+		if c.Executor.Docker.Image == "" {
+			add("executor.docker.image is required with the docker runner")
+		}
+		if c.Executor.Docker.MemoryMB < 0 || c.Executor.Docker.CPUs < 0 || c.Executor.Docker.PidsLimit < 0 {
+			add("executor.docker memory_mb, cpus and pids_limit must be >= 0")
+		}
+	default:
+		add("executor.runner %q must be exec or docker", c.Executor.Runner)
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		add("tls.cert_file and tls.key_file must be set together")
+	}
+	if c.TLS.MinVersion != "1.2" && c.TLS.MinVersion != "1.3" {
+		add("tls.min_version %q must be 1.2 or 1.3", c.TLS.MinVersion)
+	}
+	if c.TLS.ClientCA != "" && !c.TLS.Enabled() {
+		add("tls.client_ca requires tls.cert_file and tls.key_file")
+	}
+
+	if err := c.Store.JobsConfig().Validate(); err != nil {
+		add("store.backend: %v", err)
+	}
+
+	if err := c.Metrics.JobsTotalLabels().Validate(); err != nil {
+		add("metrics.metadata_labels: %v", err)
+	}
+
+	return errors.Join(problems...)
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad_FileThenEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(`
+addr: ":9000"
+pool_size: 3
+webhook:
+  max_retries: 1
+executor:
+  allowed_working_dir_roots: ["/srv/jobs"]
+`), 0o644)
+	if err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("POOL_SIZE", "7")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Addr != ":9000" {
+		t.Errorf("addr = %q, want file value", cfg.Addr)
+	}
+	if cfg.PoolSize != 7 {
+		t.Errorf("pool_size = %d, want env override 7", cfg.PoolSize)
+	}
+	if cfg.Webhook.MaxRetries != 1 || cfg.Webhook.TimeoutSec != 10 {
+		t.Errorf("webhook = %+v, want file value merged with defaults", cfg.Webhook)
+	}
+	if len(cfg.Executor.AllowedWorkingDirRoots) != 1 || !cfg.Executor.CaptureOutput {
+		t.Errorf("executor = %+v, want file value merged with defaults", cfg.Executor)
+	}
+}
+
+func TestLoad_JSONFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"addr": ":9001", "webhook": {"concurrency": 2}}`), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Addr != ":9001" || cfg.Webhook.Concurrency != 2 {
+		t.Fatalf("json config not applied: %+v", cfg)
+	}
+}
+
+func TestLoad_ReportsAllProblems(t *testing.T) {
+	t.Setenv("POOL_SIZE", "lots")
+	t.Setenv("WEBHOOK_CONCURRENCY", "0")
+	t.Setenv("TLS_CERT_FILE", "cert.pem")
+	t.Setenv("STORE_BACKEND", "redis")
+	t.Setenv("OUTPUT_CHARSET", "klingon")
+	t.Setenv("RUNNER", "docker")
+	t.Setenv("LOG_FORMAT", "xml")
+	t.Setenv("LOG_OUTPUT_DROP_RATE", "2")
+	t.Setenv("WEBHOOK_FORMAT", "xml")
+	t.Setenv("METRICS_METADATA_LABELS", "team-name")
+
+	_, err := Load("")
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	for _, want := range []string{"POOL_SIZE", "webhook.concurrency", "tls.cert_file", "store.backend", "executor.output_charset", "executor.docker.image", "log_format", "executor.log_output_drop_rate", "webhook.format", "metrics.metadata_labels"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s:\n%v", want, err)
+		}
+	}
+}
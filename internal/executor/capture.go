@@ -0,0 +1,170 @@
+package executor
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// truncationMarker is appended to captured output once a cap is reached.
+const truncationMarker = "\n... (output truncated)\n"
+
+// outputCapture records a stream's output up to a byte cap and a line cap,
+// whichever is reached first, and optionally forwards it to a live writer.
+type outputCapture struct {
+	builder       strings.Builder
+	maxBytes      int // 0 for unlimited
+	maxLines      int // 0 for unlimited
+	lines         int
+	total         int // bytes written, including any beyond the caps
+	truncated     bool
+	stream        io.Writer
+	stopStreaming bool // stop forwarding to stream once truncated
+	convert       func(string) string
+	prefix        func() string // nil when lines are not prefixed
+	atLineStart   bool
+	redact        []*regexp.Regexp
+	pending       []byte // partial line held back for redaction
+}
+
+func (er *execRunner) newCapture(stream io.Writer, jobID string, opts RunOptions) *outputCapture {
+	return &outputCapture{
+		maxBytes:      er.config.MaxOutputSize,
+		maxLines:      er.config.MaxOutputLines,
+		stream:        stream,
+		stopStreaming: er.config.StopStreamingAtLimit,
+		convert:       er.outputConverter(opts.OutputEncoding),
+		prefix:        er.linePrefix(jobID),
+		atLineStart:   true,
+		redact:        er.redact,
+	}
+}
+
+// linePrefix expands ExecutorConfig.LinePrefix for one job. {jobID} is fixed for
+// the run; {ts} is the time each line starts. It returns nil when unset.
+func (er *execRunner) linePrefix(jobID string) func() string {
+	tmpl := er.config.LinePrefix
+	if tmpl == "" {
+		return nil
+	}
+	tmpl = strings.ReplaceAll(tmpl, "{jobID}", jobID)
+	if !strings.Contains(tmpl, "{ts}") {
+		return func() string { return tmpl }
+	}
+	return func() string {
+		return strings.ReplaceAll(tmpl, "{ts}", time.Now().UTC().Format("15:04:05.000"))
+	}
+}
+
+// Write captures p and returns len(p). The caps apply to the output as stored,
+// prefixes included, while total counts only what the command wrote. With
+// redaction, output is passed on a line at a time, after the patterns apply.
+func (c *outputCapture) Write(p []byte) (int, error) {
+	n := len(p)
+	c.total += n
+	if len(c.redact) > 0 {
+		if p = c.redactLines(p); len(p) == 0 {
+			return n, nil
+		}
+	}
+	c.write(p)
+	return n, nil
+}
+
+// write prefixes, stores and streams p within the caps.
+func (c *outputCapture) write(p []byte) {
+	if c.prefix != nil {
+		p = c.addPrefixes(p)
+	}
+	if c.truncated {
+		if c.stream != nil && !c.stopStreaming {
+			c.stream.Write(p)
+		}
+		return
+	}
+
+	keep := c.allowance(p)
+	c.builder.Write(p[:keep])
+	c.lines += bytes.Count(p[:keep], []byte{'\n'})
+	if keep < len(p) {
+		c.truncated = true
+		c.builder.WriteString(truncationMarker)
+	}
+
+	if c.stream != nil {
+		if c.truncated && c.stopStreaming {
+			c.stream.Write(p[:keep])
+			c.stream.Write([]byte(truncationMarker))
+		} else {
+			c.stream.Write(p)
+		}
+	}
+}
+
+// addPrefixes inserts the line prefix at the start of every line in p. A line
+// split across writes is prefixed once.
+func (c *outputCapture) addPrefixes(p []byte) []byte {
+	out := make([]byte, 0, len(p)+16)
+	for len(p) > 0 {
+		if c.atLineStart {
+			out = append(out, c.prefix()...)
+		}
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			out = append(out, p...)
+			c.atLineStart = false
+			break
+		}
+		out = append(out, p[:i+1]...)
+		p = p[i+1:]
+		c.atLineStart = true
+	}
+	return out
+}
+
+// allowance returns how many leading bytes of p fit within both caps.
+func (c *outputCapture) allowance(p []byte) int {
+	keep := len(p)
+	if c.maxBytes > 0 {
+		keep = min(keep, max(c.maxBytes-c.builder.Len(), 0))
+	}
+	if c.maxLines > 0 {
+		remaining := c.maxLines - c.lines
+		if remaining <= 0 {
+			return 0
+		}
+		for i, b := range p[:keep] {
+			if b != '\n' {
+				continue
+			}
+			remaining--
+			if remaining == 0 {
+				return i + 1
+			}
+		}
+	}
+	return keep
+}
+
+func (c *outputCapture) String() string {
+	if c.convert != nil {
+		return c.convert(c.builder.String())
+	}
+	return c.builder.String()
+}
+
+// recordOutput copies the captured output and its sizes into the result, once
+// the command has exited and any held-back output is flushed.
+func (r *ExecutionResult) recordOutput(stdout, stderr *outputCapture) {
+	stdout.flush()
+	stderr.flush()
+	r.Stdout = stdout.String()
+	r.Stderr = stderr.String()
+	r.StdoutBytes = stdout.total
+	r.StderrBytes = stderr.total
+	r.StdoutCapturedBytes = len(r.Stdout)
+	r.StderrCapturedBytes = len(r.Stderr)
+	r.Truncated = stdout.truncated || stderr.truncated
+}
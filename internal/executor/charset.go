@@ -0,0 +1,88 @@
+package executor
+
+import (
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/paulgrammer/childprocess/internal/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+)
+
+// OutputEncodingRaw declares output that is not text: it is stored base64
+// encoded instead of being converted to UTF-8.
+const OutputEncodingRaw = "raw"
+
+// CheckOutputEncoding reports whether name is accepted by WithOutputEncoding:
+// "raw" or an IANA charset name or alias.
+func CheckOutputEncoding(name string) error {
+	if name == OutputEncodingRaw {
+		return nil
+	}
+	_, err := charset.Lookup(name)
+	return err
+}
+
+// DecodingWriter returns a writer that converts output written in the job
+// encoding name to UTF-8 before passing it on to w. A character split across
+// writes is held back until the next write or Close, which does not close w.
+// It returns nil for "", UTF-8, OutputEncodingRaw and unknown charsets, whose
+// output is passed on as written.
+func DecodingWriter(w io.Writer, name string) io.WriteCloser {
+	if name == "" || name == OutputEncodingRaw || charset.IsUTF8(name) {
+		return nil
+	}
+	enc, err := charset.Lookup(name)
+	if err != nil {
+		return nil
+	}
+	return transform.NewWriter(w, enc.NewDecoder())
+}
+
+// outputConverter returns the conversion applied to captured output before it
+// is stored, or nil when output is kept as raw bytes. A job's own encoding
+// overrides the configured charset: "raw" stores base64, a charset is decoded
+// to UTF-8, and UTF-8 has invalid bytes replaced. Streamed output and the log
+// archive are decoded only for a job charset, by DecodingWriter.
+func (er *execRunner) outputConverter(jobEncoding string) func(string) string {
+	switch {
+	case jobEncoding == OutputEncodingRaw:
+		return func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	case jobEncoding != "" && !charset.IsUTF8(jobEncoding):
+		if enc, err := charset.Lookup(jobEncoding); err == nil {
+			return decodeToUTF8(enc.NewDecoder())
+		}
+		slog.Warn("ignoring output encoding", "encoding", jobEncoding)
+		return decodeToUTF8(nil)
+	case jobEncoding != "":
+		return decodeToUTF8(nil)
+	}
+	var dec *encoding.Decoder
+	if er.config.OutputCharset != "" {
+		enc, err := charset.Lookup(er.config.OutputCharset)
+		if err != nil {
+			slog.Warn("ignoring output charset", "error", err)
+		} else {
+			dec = enc.NewDecoder()
+		}
+	}
+	if dec == nil && !er.config.SanitizeUTF8 {
+		return nil
+	}
+	return decodeToUTF8(dec)
+}
+
+// decodeToUTF8 returns a conversion that decodes with dec, if not nil, and
+// replaces whatever is still invalid UTF-8 with U+FFFD.
+func decodeToUTF8(dec *encoding.Decoder) func(string) string {
+	return func(s string) string {
+		if dec != nil {
+			if decoded, err := dec.String(s); err == nil {
+				s = decoded
+			}
+		}
+		return strings.ToValidUTF8(s, "�")
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package executor
+
+import (
+	"log/slog"
+	"os/exec"
+)
+
+// ValidateRunAs always succeeds on platforms without Unix credentials.
+func ValidateRunAs(userName, groupName string) error {
+	return nil
+}
+
+// applyCredential is a no-op on platforms without Unix credentials.
+func applyCredential(cmd *exec.Cmd, userName, groupName string) error {
+	if userName != "" || groupName != "" {
+		slog.Warn("run-as user/group is not supported on this platform, ignoring", "user", userName, "group", groupName)
+	}
+	return nil
+}
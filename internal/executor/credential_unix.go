@@ -0,0 +1,110 @@
+//go:build unix
+
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// ValidateRunAs reports whether the given user and group can be resolved on this host.
+func ValidateRunAs(userName, groupName string) error {
+	if userName == "" && groupName == "" {
+		return nil
+	}
+	_, err := resolveCredential(userName, groupName)
+	return err
+}
+
+// applyCredential makes cmd run as the given user and group.
+func applyCredential(cmd *exec.Cmd, userName, groupName string) error {
+	if userName == "" && groupName == "" {
+		return nil
+	}
+	cred, err := resolveCredential(userName, groupName)
+	if err != nil {
+		return err
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = cred
+	return nil
+}
+
+// resolveCredential maps a user and group, each a name or numeric ID, to a credential.
+// Without a group, the user's primary group is used.
+func resolveCredential(userName, groupName string) (*syscall.Credential, error) {
+	cred := &syscall.Credential{Uid: uint32(os.Getuid()), Gid: uint32(os.Getgid())}
+
+	if userName != "" {
+		u, err := lookupUser(userName)
+		if err != nil {
+			return nil, err
+		}
+		uid, err := strconv.ParseUint(u.Uid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid uid %q for user %q", u.Uid, userName)
+		}
+		cred.Uid = uint32(uid)
+		if u.Gid == "" && groupName == "" {
+			return nil, fmt.Errorf("user %q has no primary group; set a run-as group", userName)
+		}
+		if u.Gid != "" {
+			gid, err := strconv.ParseUint(u.Gid, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid gid %q for user %q", u.Gid, userName)
+			}
+			cred.Gid = uint32(gid)
+		}
+	}
+
+	if groupName != "" {
+		g, err := lookupGroup(groupName)
+		if err != nil {
+			return nil, err
+		}
+		gid, err := strconv.ParseUint(g.Gid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gid %q for group %q", g.Gid, groupName)
+		}
+		cred.Gid = uint32(gid)
+	}
+
+	return cred, nil
+}
+
+// lookupUser resolves a username or numeric uid. A numeric uid without a passwd
+// entry is accepted, but carries no primary group.
+func lookupUser(name string) (*user.User, error) {
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		u, err := user.LookupId(name)
+		var unknown user.UnknownUserIdError
+		if errors.As(err, &unknown) {
+			return &user.User{Uid: name}, nil
+		}
+		return u, err
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve user %q: %w", name, err)
+	}
+	return u, nil
+}
+
+// lookupGroup resolves a group name or numeric gid.
+func lookupGroup(name string) (*user.Group, error) {
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return &user.Group{Gid: name}, nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve group %q: %w", name, err)
+	}
+	return g, nil
+}
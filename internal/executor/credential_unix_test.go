@@ -0,0 +1,52 @@
+//go:build unix
+
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"testing"
+)
+
+func TestApplyCredential_SetsCredential(t *testing.T) {
+	current, err := user.Current()
+	if err != nil {
+		t.Skipf("cannot determine current user: %v", err)
+	}
+
+	cmd := exec.Command("true")
+	if err := applyCredential(cmd, current.Username, ""); err != nil {
+		t.Fatalf("apply by name: %v", err)
+	}
+	cred := cmd.SysProcAttr.Credential
+	if strconv.Itoa(int(cred.Uid)) != current.Uid || strconv.Itoa(int(cred.Gid)) != current.Gid {
+		t.Fatalf("credential = %+v, want uid %s gid %s", cred, current.Uid, current.Gid)
+	}
+
+	cmd = exec.Command("true")
+	if err := applyCredential(cmd, "65534", "65533"); err != nil {
+		t.Fatalf("apply numeric: %v", err)
+	}
+	if cred := cmd.SysProcAttr.Credential; cred.Uid != 65534 || cred.Gid != 65533 {
+		t.Fatalf("credential = %+v, want 65534:65533", cred)
+	}
+
+	cmd = exec.Command("true")
+	if err := applyCredential(cmd, "", ""); err != nil || cmd.SysProcAttr != nil {
+		t.Fatalf("expected no credential without user or group, got %+v, %v", cmd.SysProcAttr, err)
+	}
+}
+
+func TestValidateRunAs_UnknownUser(t *testing.T) {
+	if err := ValidateRunAs("no-such-user-childprocess", ""); err == nil {
+		t.Fatalf("expected unknown user to be rejected")
+	}
+	if err := ValidateRunAs("", "no-such-group-childprocess"); err == nil {
+		t.Fatalf("expected unknown group to be rejected")
+	}
+	if err := ValidateRunAs(strconv.Itoa(os.Getuid()), ""); err != nil {
+		t.Fatalf("expected current uid to resolve, got %v", err)
+	}
+}
@@ -0,0 +1,354 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultDockerHost is the daemon address used when DockerConfig.Host is empty.
+const DefaultDockerHost = "unix:///var/run/docker.sock"
+
+// DockerConfig selects the image and limits of the containers DockerRunner
+// starts. The image must already be present on the daemon; it is not pulled.
+type DockerConfig struct {
+	// Host is the daemon address, unix:///path or tcp://host:port
+	Host  string
+	Image string
+	// Mounts are bind mounts in docker's "host:container[:ro]" form
+	Mounts []string
+	// MemoryBytes, NanoCPUs and PidsLimit cap each container; zero means no limit
+	MemoryBytes int64
+	NanoCPUs    int64
+	PidsLimit   int64
+	// Network is the container network mode, e.g. "none" or "bridge"; empty uses the daemon default
+	Network string
+}
+
+// errNiceUnsupported is returned for jobs that ask the docker runner for a
+// nice value.
+var errNiceUnsupported = errors.New("nice is not supported by the docker runner")
+
+// dockerJobLabel marks containers with the job they run, to find strays.
+const dockerJobLabel = "childprocess.job_id"
+
+// dockerRemoveTimeout bounds removing a container after its job has ended.
+const dockerRemoveTimeout = 10 * time.Second
+
+// NewDockerRunner returns a Runner that runs each job in a throwaway container
+// of docker.Image, through the Docker Engine API. Output capture, streaming and
+// the other executor settings behave as with NewExecRunner; the job's working
+// directory, if any, is checked the same way and bind-mounted at the same path.
+func NewDockerRunner(docker DockerConfig, args ...RunnerOption) (Runner, error) {
+	if docker.Image == "" {
+		return nil, errors.New("docker runner requires an image")
+	}
+	host := docker.Host
+	if host == "" {
+		host = DefaultDockerHost
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker host %q: %w", host, err)
+	}
+
+	transport := &http.Transport{}
+	base := "http://docker"
+	switch u.Scheme {
+	case "unix":
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", u.Path)
+		}
+	case "tcp", "http":
+		base = "http://" + u.Host
+	default:
+		return nil, fmt.Errorf("unsupported docker host %q (want unix:// or tcp://)", host)
+	}
+
+	er, err := newExecRunner(args...)
+	if err != nil {
+		return nil, err
+	}
+	return &dockerRunner{
+		execRunner: er,
+		docker:     docker,
+		client:     &http.Client{Transport: transport},
+		base:       base,
+	}, nil
+}
+
+type dockerRunner struct {
+	*execRunner
+	docker DockerConfig
+	client *http.Client
+	base   string
+}
+
+func (dr *dockerRunner) Run(ctx context.Context, jobID string, command string, args []string, workingDir string, stdout, stderr io.Writer, opts ...RunOption) (res *ExecutionResult, err error) {
+	ctx, span := startRunSpan(ctx, jobID, command)
+	defer func() { endRunSpan(span, res, err) }()
+	if err := dr.validateInput(command, jobID); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	var runOpts RunOptions
+	for _, opt := range opts {
+		opt(&runOpts)
+	}
+	// The container's processes belong to the daemon, which may be on another
+	// host, so there is no process here to renice
+	if runOpts.Nice != 0 {
+		return nil, fmt.Errorf("validation failed: %w", errNiceUnsupported)
+	}
+
+	result := &ExecutionResult{
+		JobID:     jobID,
+		StartTime: time.Now(),
+	}
+
+	if command == "" {
+		command = dr.config.DefaultCommand
+	}
+	if prefix := dr.config.CommandPrefix; len(prefix) > 0 {
+		args = append(append(append([]string(nil), prefix[1:]...), command), args...)
+		command = prefix[0]
+	}
+
+	dir, cleanup, err := dr.prepareWorkingDir(jobID, workingDir, runOpts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid working directory: %w", err)
+	}
+	defer cleanup()
+
+	if limit := dr.config.MaxExecutionTime; limit > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, limit, ErrExecutionTimeout)
+		defer cancel()
+	}
+
+	id, err := dr.create(ctx, jobID, append([]string{command}, args...), dir, runOpts)
+	if err != nil {
+		result.Error = fmt.Errorf("%w: %w", errStartFailed, err)
+		return result, result.Error
+	}
+	// Removing with force also kills a container still running after a cancel
+	defer dr.remove(jobID, id)
+
+	if err := dr.post(ctx, "/containers/"+id+"/start", nil, nil); err != nil {
+		result.Error = fmt.Errorf("%w: %w", errStartFailed, err)
+		return result, result.Error
+	}
+	result.PID = dr.pid(ctx, id)
+	if runOpts.OnStart != nil {
+		runOpts.OnStart(result.PID)
+	}
+
+	// As with runSimpleWithOutput, output is recorded but not streamed without CaptureOutput
+	if !dr.config.CaptureOutput {
+		stdout, stderr = nil, nil
+	}
+	stdout, stderr, finishStreams := dr.bufferStreams(jobID, stdout, stderr)
+	defer finishStreams()
+	stdoutCapture, stderrCapture := dr.newCapture(stdout, jobID, runOpts), dr.newCapture(stderr, jobID, runOpts)
+	logErr := dr.follow(ctx, id, stdoutCapture, stderrCapture)
+	exitCode, waitErr := dr.wait(ctx, id)
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime)
+	result.recordOutput(stdoutCapture, stderrCapture)
+
+	switch {
+	case waitErr != nil:
+		result.ExitCode = -1
+		if errors.Is(context.Cause(ctx), ErrExecutionTimeout) {
+			result.Error = fmt.Errorf("%w (%s): %w", ErrExecutionTimeout, dr.config.MaxExecutionTime, waitErr)
+		} else {
+			result.Error = fmt.Errorf("command execution failed: %w", waitErr)
+		}
+	case exitCode != 0:
+		result.ExitCode = exitCode
+		result.Error = fmt.Errorf("command execution failed: exit status %d", exitCode)
+	default:
+		result.ExitCode = 0
+	}
+	if logErr != nil && ctx.Err() == nil {
+		slog.Warn("failed to read container output", "job_id", jobID, "container", id, "error", logErr)
+	}
+
+	dr.logExecutionResult(result, runOpts.Sensitive)
+	return result, result.Error
+}
+
+// create creates the job's container without starting it and returns its ID.
+func (dr *dockerRunner) create(ctx context.Context, jobID string, argv []string, dir string, opts RunOptions) (string, error) {
+	binds := append([]string(nil), dr.docker.Mounts...)
+	if dir != "" {
+		binds = append(binds, dir+":"+dir)
+	}
+	user := opts.RunAsUser
+	if opts.RunAsGroup != "" {
+		user += ":" + opts.RunAsGroup
+	}
+	body := map[string]any{
+		"Image":        dr.docker.Image,
+		"Cmd":          argv,
+		"WorkingDir":   dir,
+		"User":         user,
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Labels":       map[string]string{dockerJobLabel: jobID},
+		"HostConfig": map[string]any{
+			"Binds":       binds,
+			"Memory":      dr.docker.MemoryBytes,
+			"NanoCpus":    dr.docker.NanoCPUs,
+			"PidsLimit":   dr.docker.PidsLimit,
+			"NetworkMode": dr.docker.Network,
+		},
+	}
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := dr.post(ctx, "/containers/create", body, &created); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// pid returns the host PID of the container's main process, or 0 if it has
+// already exited or cannot be inspected.
+func (dr *dockerRunner) pid(ctx context.Context, id string) int {
+	var inspect struct {
+		State struct {
+			Pid int `json:"Pid"`
+		} `json:"State"`
+	}
+	if err := dr.do(ctx, http.MethodGet, "/containers/"+id+"/json", nil, &inspect); err != nil {
+		slog.Warn("failed to inspect container", "container", id, "error", err)
+		return 0
+	}
+	return inspect.State.Pid
+}
+
+// follow copies the container's output into the captures until it exits. The
+// daemon multiplexes both streams on one connection, each frame led by an
+// 8-byte header: the stream (1 stdout, 2 stderr) and a big-endian length.
+func (dr *dockerRunner) follow(ctx context.Context, id string, stdout, stderr io.Writer) error {
+	resp, err := dr.request(ctx, http.MethodGet, "/containers/"+id+"/logs?follow=1&stdout=1&stderr=1", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	r := bufio.NewReader(resp.Body)
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		dst := stdout
+		if header[0] == 2 {
+			dst = stderr
+		}
+		if _, err := io.CopyN(dst, r, int64(binary.BigEndian.Uint32(header[4:]))); err != nil {
+			return err
+		}
+	}
+}
+
+// wait blocks until the container exits and returns its exit code.
+func (dr *dockerRunner) wait(ctx context.Context, id string) (int, error) {
+	var status struct {
+		StatusCode int `json:"StatusCode"`
+		Error      *struct {
+			Message string `json:"Message"`
+		} `json:"Error"`
+	}
+	if err := dr.post(ctx, "/containers/"+id+"/wait", nil, &status); err != nil {
+		return 0, err
+	}
+	if status.Error != nil && status.Error.Message != "" {
+		return 0, errors.New(status.Error.Message)
+	}
+	return status.StatusCode, nil
+}
+
+// remove force-removes the container, killing it if it is still running. It
+// does not use the job's context, which may already be done.
+func (dr *dockerRunner) remove(jobID, id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), dockerRemoveTimeout)
+	defer cancel()
+	if err := dr.do(ctx, http.MethodDelete, "/containers/"+id+"?force=1&v=1", nil, nil); err != nil {
+		slog.Warn("failed to remove container", "job_id", jobID, "container", id, "error", err)
+	}
+}
+
+func (dr *dockerRunner) post(ctx context.Context, path string, body, out any) error {
+	return dr.do(ctx, http.MethodPost, path, body, out)
+}
+
+// do sends a JSON request and decodes the JSON response into out, if non-nil.
+func (dr *dockerRunner) do(ctx context.Context, method, path string, body, out any) error {
+	resp, err := dr.request(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("docker %s %s: failed to decode response: %w", method, path, err)
+	}
+	return nil
+}
+
+// request sends the request and turns error statuses into errors carrying the
+// daemon's message.
+func (dr *dockerRunner) request(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, dr.base+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := dr.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker %s %s: %w", method, path, err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if json.Unmarshal(raw, &apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(raw))
+		}
+		return nil, fmt.Errorf("docker %s %s: %s: %s", method, strings.SplitN(path, "?", 2)[0], resp.Status, apiErr.Message)
+	}
+	return resp, nil
+}
@@ -0,0 +1,154 @@
+package executor
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDockerd serves the few Engine API calls dockerRunner makes, for one
+// container that writes the given frames and exits with exitCode.
+type fakeDockerd struct {
+	mu       sync.Mutex
+	created  map[string]any
+	calls    []string
+	frames   [][2]string // stream ("1" or "2") and payload
+	exitCode int
+}
+
+func (d *fakeDockerd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	d.calls = append(d.calls, r.Method+" "+r.URL.Path)
+	d.mu.Unlock()
+	switch {
+	case r.URL.Path == "/containers/create":
+		json.NewDecoder(r.Body).Decode(&d.created)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"Id": "c1"})
+	case r.URL.Path == "/containers/c1/start":
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Path == "/containers/c1/json":
+		json.NewEncoder(w).Encode(map[string]any{"State": map[string]int{"Pid": 4242}})
+	case r.URL.Path == "/containers/c1/logs":
+		for _, f := range d.frames {
+			header := make([]byte, 8)
+			header[0] = f[0][0] - '0'
+			binary.BigEndian.PutUint32(header[4:], uint32(len(f[1])))
+			w.Write(append(header, f[1]...))
+		}
+	case r.URL.Path == "/containers/c1/wait":
+		json.NewEncoder(w).Encode(map[string]int{"StatusCode": d.exitCode})
+	case r.Method == http.MethodDelete && r.URL.Path == "/containers/c1":
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"message": "no such route"})
+	}
+}
+
+func startFakeDockerd(t *testing.T, d *fakeDockerd) string {
+	t.Helper()
+	sock := filepath.Join(t.TempDir(), "docker.sock")
+	lis, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(d)
+	srv.Listener = lis
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return "unix://" + sock
+}
+
+func TestDockerRunner_RunsJobInContainer(t *testing.T) {
+	d := &fakeDockerd{
+		frames:   [][2]string{{"1", "hello\n"}, {"2", "oops\n"}, {"1", "bye\n"}},
+		exitCode: 3,
+	}
+	host := startFakeDockerd(t, d)
+	workDir := t.TempDir()
+
+	r, err := NewDockerRunner(DockerConfig{Host: host, Image: "alpine:3", Mounts: []string{"/data:/data:ro"}, MemoryBytes: 64 << 20},
+		WithExecutorConfig(&ExecutorConfig{CaptureOutput: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var live strings.Builder
+	var pid int
+	result, err := r.Run(context.Background(), "job-1", "sh", []string{"-c", "work"}, workDir, &live, &live,
+		WithOnStart(func(p int) { pid = p }))
+	if err == nil || result.ExitCode != 3 {
+		t.Fatalf("exit code = %d, err = %v; want 3 and an error", result.ExitCode, err)
+	}
+	if result.Stdout != "hello\nbye\n" || result.Stderr != "oops\n" {
+		t.Fatalf("stdout = %q, stderr = %q", result.Stdout, result.Stderr)
+	}
+	if live.String() != "hello\noops\nbye\n" {
+		t.Fatalf("streamed %q, want both streams in order", live.String())
+	}
+	if pid != 4242 || result.PID != 4242 {
+		t.Fatalf("pid = %d, result.PID = %d, want the container's", pid, result.PID)
+	}
+
+	resolved, _ := filepath.EvalSymlinks(workDir)
+	if d.created["Image"] != "alpine:3" || d.created["WorkingDir"] != resolved {
+		t.Fatalf("created %v", d.created)
+	}
+	if cmd, _ := json.Marshal(d.created["Cmd"]); string(cmd) != `["sh","-c","work"]` {
+		t.Fatalf("Cmd = %s", cmd)
+	}
+	hostConfig := d.created["HostConfig"].(map[string]any)
+	if binds, _ := json.Marshal(hostConfig["Binds"]); string(binds) != `["/data:/data:ro","`+resolved+`:`+resolved+`"]` {
+		t.Fatalf("Binds = %s", binds)
+	}
+	if hostConfig["Memory"] != float64(64<<20) {
+		t.Fatalf("Memory = %v", hostConfig["Memory"])
+	}
+	if !slices.Contains(d.calls, "DELETE /containers/c1") {
+		t.Fatalf("container not removed; calls: %v", d.calls)
+	}
+}
+
+func TestDockerRunner_RejectsNice(t *testing.T) {
+	d := &fakeDockerd{}
+	r, err := NewDockerRunner(DockerConfig{Host: startFakeDockerd(t, d), Image: "alpine:3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Run(context.Background(), "job-1", "sh", nil, "", nil, nil, WithNice(5)); !errors.Is(err, errNiceUnsupported) {
+		t.Fatalf("err = %v, want errNiceUnsupported", err)
+	}
+	if len(d.calls) != 0 {
+		t.Fatalf("expected no container, got calls %v", d.calls)
+	}
+	if _, err := r.Run(context.Background(), "job-2", "sh", nil, "", nil, nil, WithNice(0)); err != nil {
+		t.Fatalf("expected nice 0 to run, got %v", err)
+	}
+}
+
+func TestDockerRunner_ReportsDaemonErrors(t *testing.T) {
+	host := startFakeDockerd(t, &fakeDockerd{})
+	r, err := NewDockerRunner(DockerConfig{Host: host, Image: "alpine:3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fake daemon only knows container c1; an unknown route is a 404
+	dr := r.(*dockerRunner)
+	err = dr.post(context.Background(), "/containers/missing/start", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "no such route") {
+		t.Fatalf("err = %v, want the daemon's message", err)
+	}
+
+	if _, err := NewDockerRunner(DockerConfig{Host: host}); err == nil {
+		t.Fatal("expected an error without an image")
+	}
+}
@@ -0,0 +1,29 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrCommandNotFound is returned when PathDirs is set and none of its
+// directories holds an executable with the command's name.
+var ErrCommandNotFound = errors.New("command not found")
+
+// lookPath resolves command against dirs only, ignoring the server's PATH. A
+// command that already contains a path separator is used as given, as with
+// exec.LookPath.
+func lookPath(command string, dirs []string) (string, error) {
+	if strings.ContainsRune(command, filepath.Separator) || strings.ContainsRune(command, '/') {
+		return command, nil
+	}
+	for _, dir := range dirs {
+		// A candidate with a separator is checked in place, without searching PATH
+		if path, err := exec.LookPath(filepath.Join(dir, command)); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q in %s", ErrCommandNotFound, command, strings.Join(dirs, string(filepath.ListSeparator)))
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package executor
+
+import "log/slog"
+
+// setPriority is a no-op on platforms without Unix scheduling priorities.
+func setPriority(pid, nice int) error {
+	slog.Warn("process priority is not supported on this platform, ignoring", "pid", pid, "nice", nice)
+	return nil
+}
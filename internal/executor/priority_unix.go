@@ -0,0 +1,10 @@
+//go:build unix
+
+package executor
+
+import "syscall"
+
+// setPriority sets the nice value of a running process.
+func setPriority(pid, nice int) error {
+	return syscall.Setpriority(syscall.PRIO_PROCESS, pid, nice)
+}
@@ -0,0 +1,25 @@
+//go:build unix
+
+package executor
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestRun_AppliesNice(t *testing.T) {
+	if _, err := exec.LookPath("nice"); err != nil {
+		t.Skip("nice not available")
+	}
+
+	// Priority is applied just after start, so give it a moment before reading it back
+	result, err := NewExecRunner().Run(context.Background(), "job-nice", "sh", []string{"-c", "sleep 0.2; nice"}, "", nil, nil, WithNice(7))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got := strings.TrimSpace(result.Stdout); got != "7" {
+		t.Fatalf("nice = %q, want 7", got)
+	}
+}
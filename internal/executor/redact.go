@@ -0,0 +1,62 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+// redactReplacement replaces every match of a redaction pattern.
+const redactReplacement = "***"
+
+// maxRedactLine bounds how much of an unterminated line is held back waiting
+// for its newline; longer lines are redacted in pieces.
+const maxRedactLine = 64 * 1024
+
+// CompileRedactPatterns compiles ExecutorConfig.RedactPatterns.
+func CompileRedactPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// redactLines returns the complete lines of pending+p with every pattern
+// match replaced, holding back a trailing partial line so a secret split
+// across writes is still matched.
+func (c *outputCapture) redactLines(p []byte) []byte {
+	c.pending = append(c.pending, p...)
+	end := bytes.LastIndexByte(c.pending, '\n') + 1
+	if end == 0 && len(c.pending) < maxRedactLine {
+		return nil
+	}
+	if end == 0 {
+		end = len(c.pending)
+	}
+	out := c.redactAll(c.pending[:end])
+	c.pending = append(c.pending[:0], c.pending[end:]...)
+	return out
+}
+
+func (c *outputCapture) redactAll(p []byte) []byte {
+	out := append([]byte(nil), p...)
+	for _, re := range c.redact {
+		out = re.ReplaceAllLiteral(out, []byte(redactReplacement))
+	}
+	return out
+}
+
+// flush writes out a held-back partial line once the command has exited.
+func (c *outputCapture) flush() {
+	if len(c.pending) == 0 {
+		return
+	}
+	p := c.redactAll(c.pending)
+	c.pending = nil
+	c.write(p)
+}
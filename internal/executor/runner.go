@@ -7,10 +7,14 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"math/rand/v2"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -24,22 +28,177 @@ type ExecutionResult struct {
 	EndTime   time.Time
 	Duration  time.Duration
 	Error     error
+
+	// StdoutBytes and StderrBytes count everything the command wrote, even past the output caps
+	StdoutBytes int
+	StderrBytes int
+	Truncated   bool
+	// StdoutCapturedBytes and StderrCapturedBytes are the sizes of Stdout and
+	// Stderr as captured, after the caps and any conversion
+	StdoutCapturedBytes int
+	StderrCapturedBytes int
+
+	// PID is the process ID of the last start attempt
+	PID int
+
+	// UserTime, SysTime and MaxRSSKB are the CPU time and peak resident memory
+	// of the process. They stay zero on platforms that do not report them.
+	UserTime time.Duration
+	SysTime  time.Duration
+	MaxRSSKB int64
 }
 
 type Runner interface {
-	Run(ctx context.Context, jobID string, command string, args []string, workingDir string, stdout, stderr io.Writer) (*ExecutionResult, error)
+	Run(ctx context.Context, jobID string, command string, args []string, workingDir string, stdout, stderr io.Writer, opts ...RunOption) (*ExecutionResult, error)
+}
+
+// RunOptions holds per-job settings that change how a command is started
+type RunOptions struct {
+	RunAsUser  string
+	RunAsGroup string
+	Nice       int
+	// OnStart is called with the process ID as soon as the command has started
+	OnStart func(pid int)
+	// CreateWorkingDir creates a missing working directory, or a scratch
+	// directory when none is given. CleanupWorkingDir removes it afterwards.
+	CreateWorkingDir  bool
+	CleanupWorkingDir bool
+	// Sensitive keeps the command's args and output out of the logs
+	Sensitive bool
+	// OutputEncoding is what the command writes; see WithOutputEncoding
+	OutputEncoding string
+}
+
+type RunOption func(*RunOptions)
+
+// Scheduling priority bounds accepted by WithNice.
+const (
+	MinNice = -20
+	MaxNice = 19
+)
+
+// WithNice runs the command at the given nice value (MinNice..MaxNice, higher is
+// lower priority). Only supported on Unix.
+func WithNice(nice int) RunOption {
+	return func(o *RunOptions) {
+		o.Nice = nice
+	}
+}
+
+// WithOnStart registers a callback that receives the process ID once the
+// command has started, before it is waited on.
+func WithOnStart(fn func(pid int)) RunOption {
+	return func(o *RunOptions) {
+		o.OnStart = fn
+	}
+}
+
+// WithCreateWorkingDir creates the working directory if it is missing, still
+// within the allowed roots, or a fresh scratch directory if none is given. With
+// cleanup, a directory created this way is removed once the command exits.
+func WithCreateWorkingDir(cleanup bool) RunOption {
+	return func(o *RunOptions) {
+		o.CreateWorkingDir = true
+		o.CleanupWorkingDir = cleanup
+	}
+}
+
+// WithSensitive keeps the job's args and output out of the logs, whatever
+// LogOutput and VerboseLogging say; only sizes and the exit code are logged.
+// The output is still captured and streamed.
+func WithSensitive() RunOption {
+	return func(o *RunOptions) {
+		o.Sensitive = true
+	}
+}
+
+// WithOutputEncoding declares the encoding the command writes its output in,
+// overriding ExecutorConfig.OutputCharset for this run: an IANA charset name
+// such as "windows-1252", decoded to UTF-8 when captured, "utf-8", whose
+// invalid bytes are replaced with U+FFFD, or OutputEncodingRaw to store the
+// output base64 encoded. Streamed output is passed on as written.
+func WithOutputEncoding(name string) RunOption {
+	return func(o *RunOptions) {
+		o.OutputEncoding = name
+	}
+}
+
+// WithRunAs runs the command as the given user and group (name or numeric ID).
+// Only supported on Unix.
+func WithRunAs(user, group string) RunOption {
+	return func(o *RunOptions) {
+		o.RunAsUser = user
+		o.RunAsGroup = group
+	}
 }
 
 // ExecutorConfig allows customization of execution behavior
 type ExecutorConfig struct {
 	DefaultCommand string
 	CaptureOutput  bool
-	MaxOutputSize  int // bytes, 0 for unlimited
+	// MaxOutputSize caps each stream's captured bytes, 0 for unlimited. It applies
+	// before charset conversion, which may grow the stored output past it.
+	MaxOutputSize  int
+	MaxOutputLines int // lines, 0 for unlimited
+	// LogOutput logs the start of each job's stdout and stderr when it ends. The
+	// output is already captured, so this only duplicates it into the server logs.
 	LogOutput      bool
 	StreamOutput   bool // if true, output is streamed in real-time
 	VerboseLogging bool // if true, more detailed logs are produced
+	// StopStreamingAtLimit stops live streaming once a capture cap is reached
+	StopStreamingAtLimit bool
+	// AllowedWorkingDirRoots restricts job working directories to these roots.
+	// Empty means any existing directory is accepted.
+	AllowedWorkingDirRoots []string
+	// StartRetries is how many times a spawn that failed for lack of resources
+	// (EAGAIN, ENOMEM) is retried. Failed exits are never retried here.
+	StartRetries int
+	// MaxExecutionTime kills any command still running after this long, whatever
+	// the caller's context allows. Zero means unlimited.
+	MaxExecutionTime time.Duration
+	// OutputCharset decodes captured stdout and stderr from this IANA charset to
+	// UTF-8. Empty stores the bytes as the command wrote them.
+	OutputCharset string
+	// SanitizeUTF8 replaces invalid UTF-8 in captured output with U+FFFD.
+	SanitizeUTF8 bool
+	// LinePrefix is prepended to every line of output before it is captured and
+	// streamed, e.g. "[{jobID}] " or "{ts} ". Empty disables prefixing.
+	LinePrefix string
+	// WorkingDirPerm is the mode for working directories created on request (default 0750)
+	WorkingDirPerm os.FileMode
+	// ScratchDir is where per-job scratch directories are created (default os.TempDir())
+	ScratchDir string
+	// BaseWorkingDir confines jobs to this directory: relative working directories
+	// are resolved against it, none may leave it, and jobs without one run in
+	// {BaseWorkingDir}/{jobID}, created for them.
+	BaseWorkingDir string
+	// CommandPrefix is prepended to every command line, e.g. ["/usr/bin/timeout", "60"],
+	// so the job's command and args become arguments of a fixed wrapper.
+	CommandPrefix []string
+	// RedactPatterns are regular expressions whose matches are replaced with
+	// "***" in captured and streamed output, line by line.
+	RedactPatterns []string
+	// PathDirs, if set, is searched instead of the server's PATH for commands
+	// given without a directory, so resolution does not depend on the environment.
+	PathDirs []string
+	// StreamBufferSize is how many bytes of output per stream are queued for a
+	// consumer that cannot keep up before further output to it is dropped; the
+	// captured output is unaffected (default 1 MiB)
+	StreamBufferSize int
+	// LogOutputDropRate is the fraction of LogOutput's output logs, between 0
+	// and 1, dropped at random to cut log volume. Zero logs all of them.
+	LogOutputDropRate float64
 }
 
+// ErrExecutionTimeout is returned when a command is killed for exceeding MaxExecutionTime.
+var ErrExecutionTimeout = errors.New("command exceeded max execution time")
+
+// startRetryBackoff is the delay before the first start retry; it doubles each time.
+const startRetryBackoff = 20 * time.Millisecond
+
+// errStartFailed wraps errors from starting the process, as opposed to running it.
+var errStartFailed = errors.New("failed to start command")
+
 type RunnerOption func(*execRunner)
 
 func WithExecutorConfig(config *ExecutorConfig) RunnerOption {
@@ -48,33 +207,67 @@ func WithExecutorConfig(config *ExecutorConfig) RunnerOption {
 	}
 }
 
+// WithAllowedWorkingDirRoots restricts job working directories to the given roots.
+func WithAllowedWorkingDirRoots(roots ...string) RunnerOption {
+	return func(r *execRunner) {
+		r.config.AllowedWorkingDirRoots = roots
+	}
+}
+
+// NewExecRunner returns a Runner that runs jobs as local processes. It panics
+// if a redact pattern does not compile, as running without masking would leak
+// what the patterns protect; check them with CompileRedactPatterns first.
 func NewExecRunner(args ...RunnerOption) Runner {
+	runner, err := newExecRunner(args...)
+	if err != nil {
+		panic(err)
+	}
+	return runner
+}
+
+func newExecRunner(args ...RunnerOption) (*execRunner, error) {
 	config := &ExecutorConfig{
 		DefaultCommand: os.Getenv("DEFAULT_COMMAND"),
 		CaptureOutput:  true,
 		MaxOutputSize:  1024 * 1024, // 1MB default
-		LogOutput:      true,
+		LogOutput:      false,
 		StreamOutput:   false,
 		VerboseLogging: false,
+		StartRetries:   3,
+		WorkingDirPerm: 0o750,
 	}
 
-	runner := &execRunner{config: config}
+	runner := &execRunner{config: config, startCmd: (*exec.Cmd).Start}
 
 	for _, arg := range args {
 		arg(runner)
 	}
-	return runner
+	redact, err := CompileRedactPatterns(runner.config.RedactPatterns)
+	if err != nil {
+		return nil, err
+	}
+	runner.redact = redact
+	return runner, nil
 }
 
 type execRunner struct {
-	config *ExecutorConfig
+	config   *ExecutorConfig
+	startCmd func(*exec.Cmd) error
+	redact   []*regexp.Regexp
 }
 
-func (er *execRunner) Run(ctx context.Context, jobID string, command string, args []string, workingDir string, stdout, stderr io.Writer) (*ExecutionResult, error) {
+func (er *execRunner) Run(ctx context.Context, jobID string, command string, args []string, workingDir string, stdout, stderr io.Writer, opts ...RunOption) (res *ExecutionResult, err error) {
+	ctx, span := startRunSpan(ctx, jobID, command)
+	defer func() { endRunSpan(span, res, err) }()
 	if err := er.validateInput(command, jobID); err != nil {
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
+	var runOpts RunOptions
+	for _, opt := range opts {
+		opt(&runOpts)
+	}
+
 	result := &ExecutionResult{
 		JobID:     jobID,
 		StartTime: time.Now(),
@@ -84,44 +277,125 @@ func (er *execRunner) Run(ctx context.Context, jobID string, command string, arg
 	if command == "" {
 		command = er.config.DefaultCommand
 	}
+	// Resolve the job's command before wrapping it, so the wrapper is handed an
+	// absolute path rather than searching the server's PATH itself
+	if len(er.config.PathDirs) > 0 {
+		resolved, err := lookPath(command, er.config.PathDirs)
+		if err != nil {
+			return nil, err
+		}
+		command = resolved
+	}
+	// Run the resolved command through the configured wrapper, if any
+	if prefix := er.config.CommandPrefix; len(prefix) > 0 {
+		wrapper := prefix[0]
+		if len(er.config.PathDirs) > 0 {
+			resolved, err := lookPath(wrapper, er.config.PathDirs)
+			if err != nil {
+				return nil, err
+			}
+			wrapper = resolved
+		}
+		args = append(append(append([]string(nil), prefix[1:]...), command), args...)
+		command = wrapper
+	}
 
 	if er.config.VerboseLogging {
+		argv := append([]string{command}, args...)
+		if runOpts.Sensitive {
+			argv = []string{command}
+		}
 		slog.Info("Starting job execution",
 			"job_id", jobID,
-			"command", command,
-			"args", args,
+			"argv", argv,
 			"working_dir", workingDir,
 			"start_time", result.StartTime.Format(time.RFC3339),
 		)
 	}
 
-	cmd := exec.CommandContext(ctx, command, args...)
-	if workingDir != "" {
-		if err := er.validateWorkingDir(workingDir); err != nil {
-			return nil, fmt.Errorf("invalid working directory: %w", err)
+	dir, cleanup, err := er.prepareWorkingDir(jobID, workingDir, runOpts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid working directory: %w", err)
+	}
+	defer cleanup()
+
+	if limit := er.config.MaxExecutionTime; limit > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, limit, ErrExecutionTimeout)
+		defer cancel()
+	}
+
+	// A Cmd cannot be started twice, so each attempt builds a fresh one
+	for attempt := 0; ; attempt++ {
+		cmd := exec.CommandContext(ctx, command, args...)
+		cmd.Dir = dir
+		if err := applyCredential(cmd, runOpts.RunAsUser, runOpts.RunAsGroup); err != nil {
+			return nil, fmt.Errorf("invalid run-as credential: %w", err)
+		}
+
+		res, err := er.runCmd(cmd, result, stdout, stderr, runOpts)
+		if err != nil && errors.Is(context.Cause(ctx), ErrExecutionTimeout) {
+			result.Error = fmt.Errorf("%w (%s): %w", ErrExecutionTimeout, er.config.MaxExecutionTime, err)
+			return res, result.Error
+		}
+		if attempt >= er.config.StartRetries || !isTransientStartError(err) {
+			return res, err
 		}
-		cmd.Dir = workingDir
+		backoff := startRetryBackoff << attempt
+		slog.Warn("retrying transient command start failure", "job_id", jobID, "attempt", attempt+1, "backoff", backoff.String(), "error", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return res, err
+		}
+		result.Error = nil
 	}
+}
+
+// isTransientStartError reports whether err is a spawn failure caused by a
+// temporary resource shortage, which is worth retrying.
+func isTransientStartError(err error) bool {
+	return errors.Is(err, errStartFailed) && (errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.ENOMEM))
+}
+
+func (er *execRunner) runCmd(cmd *exec.Cmd, result *ExecutionResult, stdout, stderr io.Writer, opts RunOptions) (*ExecutionResult, error) {
 
 	// Always capture output for visibility
 	if er.config.CaptureOutput {
 		if er.config.StreamOutput {
-			return er.runWithStreamedOutput(cmd, result, stdout, stderr)
+			return er.runWithStreamedOutput(cmd, result, stdout, stderr, opts)
 		}
-		return er.runWithCapturedOutput(cmd, result, stdout, stderr)
+		return er.runWithCapturedOutput(cmd, result, stdout, stderr, opts)
 	}
 
 	// Even for simple execution, we should capture some output
-	return er.runSimpleWithOutput(cmd, result)
+	return er.runSimpleWithOutput(cmd, result, opts)
+}
+
+// start starts cmd, records its PID and applies the per-job scheduling priority.
+func (er *execRunner) start(cmd *exec.Cmd, result *ExecutionResult, opts RunOptions) error {
+	if err := er.startCmd(cmd); err != nil {
+		return err
+	}
+	result.PID = cmd.Process.Pid
+	if opts.OnStart != nil {
+		opts.OnStart(result.PID)
+	}
+	if opts.Nice != 0 {
+		if err := setPriority(cmd.Process.Pid, opts.Nice); err != nil {
+			slog.Warn("failed to set process priority", "pid", cmd.Process.Pid, "nice", opts.Nice, "error", err)
+		}
+	}
+	return nil
 }
 
-func (er *execRunner) runWithCapturedOutput(cmd *exec.Cmd, result *ExecutionResult, stdout, stderr io.Writer) (*ExecutionResult, error) {
-	var stdoutBuilder, stderrBuilder strings.Builder
-	cmd.Stdout = io.MultiWriter(&stdoutBuilder, stdout)
-	cmd.Stderr = io.MultiWriter(&stderrBuilder, stderr)
+func (er *execRunner) runWithCapturedOutput(cmd *exec.Cmd, result *ExecutionResult, stdout, stderr io.Writer, opts RunOptions) (*ExecutionResult, error) {
+	stdoutCapture, stderrCapture := er.newCapture(stdout, result.JobID, opts), er.newCapture(stderr, result.JobID, opts)
+	cmd.Stdout = stdoutCapture
+	cmd.Stderr = stderrCapture
 
-	if err := cmd.Start(); err != nil {
-		result.Error = fmt.Errorf("failed to start command: %w", err)
+	if err := er.start(cmd, result, opts); err != nil {
+		result.Error = fmt.Errorf("%w: %w", errStartFailed, err)
 		return result, result.Error
 	}
 
@@ -130,6 +404,9 @@ func (er *execRunner) runWithCapturedOutput(cmd *exec.Cmd, result *ExecutionResu
 	result.EndTime = time.Now()
 	result.Duration = result.EndTime.Sub(result.StartTime)
 
+	result.recordOutput(stdoutCapture, stderrCapture)
+	result.recordUsage(cmd.ProcessState)
+
 	if err != nil {
 		if exitError, ok := err.(*exec.ExitError); ok {
 			result.ExitCode = exitError.ExitCode()
@@ -141,12 +418,15 @@ func (er *execRunner) runWithCapturedOutput(cmd *exec.Cmd, result *ExecutionResu
 		result.ExitCode = 0
 	}
 
-	er.logExecutionResult(result)
+	er.logExecutionResult(result, opts.Sensitive)
 	return result, result.Error
 }
 
-func (er *execRunner) runWithStreamedOutput(cmd *exec.Cmd, result *ExecutionResult, stdout, stderr io.Writer) (*ExecutionResult, error) {
-	var stdoutBuilder, stderrBuilder strings.Builder
+func (er *execRunner) runWithStreamedOutput(cmd *exec.Cmd, result *ExecutionResult, stdout, stderr io.Writer, opts RunOptions) (*ExecutionResult, error) {
+	// A slow consumer must not stop the pipes being drained, or the command blocks on write
+	stdout, stderr, finishStreams := er.bufferStreams(result.JobID, stdout, stderr)
+	defer finishStreams()
+	stdoutCapture, stderrCapture := er.newCapture(stdout, result.JobID, opts), er.newCapture(stderr, result.JobID, opts)
 	var wg sync.WaitGroup
 
 	// Create pipes for real-time streaming
@@ -160,8 +440,8 @@ func (er *execRunner) runWithStreamedOutput(cmd *exec.Cmd, result *ExecutionResu
 		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
 	}
 
-	if err := cmd.Start(); err != nil {
-		result.Error = fmt.Errorf("failed to start command: %w", err)
+	if err := er.start(cmd, result, opts); err != nil {
+		result.Error = fmt.Errorf("%w: %w", errStartFailed, err)
 		return result, result.Error
 	}
 
@@ -170,13 +450,13 @@ func (er *execRunner) runWithStreamedOutput(cmd *exec.Cmd, result *ExecutionResu
 	// Stream stdout
 	go func() {
 		defer wg.Done()
-		er.streamAndCapture(stdoutPipe, &stdoutBuilder, result.JobID, "stdout", stdout)
+		er.streamAndCapture(stdoutPipe, stdoutCapture, result.JobID, "stdout")
 	}()
 
 	// Stream stderr
 	go func() {
 		defer wg.Done()
-		er.streamAndCapture(stderrPipe, &stderrBuilder, result.JobID, "stderr", stderr)
+		er.streamAndCapture(stderrPipe, stderrCapture, result.JobID, "stderr")
 	}()
 
 	// Wait for streaming to complete
@@ -186,8 +466,8 @@ func (er *execRunner) runWithStreamedOutput(cmd *exec.Cmd, result *ExecutionResu
 	result.EndTime = time.Now()
 	result.Duration = result.EndTime.Sub(result.StartTime)
 
-	result.Stdout = stdoutBuilder.String()
-	result.Stderr = stderrBuilder.String()
+	result.recordOutput(stdoutCapture, stderrCapture)
+	result.recordUsage(cmd.ProcessState)
 
 	if err != nil {
 		if exitError, ok := err.(*exec.ExitError); ok {
@@ -200,22 +480,26 @@ func (er *execRunner) runWithStreamedOutput(cmd *exec.Cmd, result *ExecutionResu
 		result.ExitCode = 0
 	}
 
-	er.logExecutionResult(result)
+	er.logExecutionResult(result, opts.Sensitive)
 	return result, result.Error
 }
 
-func (er *execRunner) runSimpleWithOutput(cmd *exec.Cmd, result *ExecutionResult) (*ExecutionResult, error) {
+func (er *execRunner) runSimpleWithOutput(cmd *exec.Cmd, result *ExecutionResult, opts RunOptions) (*ExecutionResult, error) {
 	// Even in simple mode, capture output for visibility
-	var stdoutBuilder, stderrBuilder strings.Builder
-	cmd.Stdout = &stdoutBuilder
-	cmd.Stderr = &stderrBuilder
+	stdoutCapture, stderrCapture := er.newCapture(nil, result.JobID, opts), er.newCapture(nil, result.JobID, opts)
+	cmd.Stdout = stdoutCapture
+	cmd.Stderr = stderrCapture
 
-	err := cmd.Run()
+	if err := er.start(cmd, result, opts); err != nil {
+		result.Error = fmt.Errorf("%w: %w", errStartFailed, err)
+		return result, result.Error
+	}
+	err := cmd.Wait()
 	result.EndTime = time.Now()
 	result.Duration = result.EndTime.Sub(result.StartTime)
 
-	result.Stdout = stdoutBuilder.String()
-	result.Stderr = stderrBuilder.String()
+	result.recordOutput(stdoutCapture, stderrCapture)
+	result.recordUsage(cmd.ProcessState)
 
 	if err != nil {
 		if exitError, ok := err.(*exec.ExitError); ok {
@@ -228,37 +512,32 @@ func (er *execRunner) runSimpleWithOutput(cmd *exec.Cmd, result *ExecutionResult
 		result.ExitCode = 0
 	}
 
-	er.logExecutionResult(result)
+	er.logExecutionResult(result, opts.Sensitive)
 	return result, result.Error
 }
 
-func (er *execRunner) streamAndCapture(reader io.Reader, builder *strings.Builder, jobID, streamType string, writer io.Writer) {
-	scanner := bufio.NewScanner(reader)
-	maxScanTokenSize := 64 * 1024 // 64KB buffer for long lines
-	buf := make([]byte, maxScanTokenSize)
-	scanner.Buffer(buf, maxScanTokenSize)
-
-	for scanner.Scan() {
-		line := scanner.Bytes()
+func (er *execRunner) streamAndCapture(reader io.Reader, capture *outputCapture, jobID, streamType string) {
+	// Lines longer than the buffer are flushed in buffer-sized chunks rather than dropped
+	br := bufio.NewReaderSize(reader, 64*1024)
 
-		// Write to builder for capture
-		if er.config.MaxOutputSize <= 0 || builder.Len() < er.config.MaxOutputSize {
-			builder.Write(line)
-			builder.WriteString("\n")
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if len(chunk) > 0 {
+			// Capture within the configured caps and stream in real-time
+			capture.Write(chunk)
 		}
 
-		// Stream output in real-time
-		if writer != nil {
-			writer.Write(append(line, '\n'))
+		if err == nil || err == bufio.ErrBufferFull {
+			continue
 		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		slog.Error("Error scanning output",
-			"job_id", jobID,
-			"stream", streamType,
-			"error", err,
-		)
+		if err != io.EOF {
+			slog.Error("Error reading output",
+				"job_id", jobID,
+				"stream", streamType,
+				"error", err,
+			)
+		}
+		return
 	}
 }
 
@@ -274,24 +553,71 @@ func (er *execRunner) validateInput(command, jobID string) error {
 	return nil
 }
 
-func (er *execRunner) validateWorkingDir(workingDir string) error {
-	if workingDir == "" {
-		return nil
+// resolveWorkingDir returns the absolute, symlink-free form of workingDir after
+// checking that it is a directory inside one of the allowed roots.
+func (er *execRunner) resolveWorkingDir(workingDir string) (string, error) {
+	abs, err := filepath.Abs(filepath.Clean(workingDir))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve working directory: %w", err)
+	}
+
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return "", fmt.Errorf("working directory does not exist: %w", err)
 	}
 
-	info, err := os.Stat(workingDir)
+	info, err := os.Stat(resolved)
 	if err != nil {
-		return fmt.Errorf("working directory does not exist: %w", err)
+		return "", fmt.Errorf("working directory does not exist: %w", err)
 	}
 
 	if !info.IsDir() {
-		return errors.New("working directory path is not a directory")
+		return "", errors.New("working directory path is not a directory")
 	}
 
-	return nil
+	if !er.inAllowedRoots(resolved) {
+		return "", errors.New("working directory is outside the allowed roots")
+	}
+	if !er.inBase(resolved) {
+		return "", errOutsideBase
+	}
+	return resolved, nil
+}
+
+// inAllowedRoots reports whether a symlink-free absolute path lies under one of
+// the allowed roots. Any path is allowed when no roots are configured.
+func (er *execRunner) inAllowedRoots(resolved string) bool {
+	if len(er.config.AllowedWorkingDirRoots) == 0 {
+		return true
+	}
+	for _, root := range er.config.AllowedWorkingDirRoots {
+		rootAbs, err := filepath.Abs(filepath.Clean(root))
+		if err != nil {
+			continue
+		}
+		rootResolved, err := filepath.EvalSymlinks(rootAbs)
+		if err != nil {
+			continue
+		}
+		if isWithin(rootResolved, resolved) {
+			return true
+		}
+	}
+	return false
 }
 
-func (er *execRunner) logExecutionResult(result *ExecutionResult) {
+// isWithin reports whether path is root or a descendant of it.
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// logExecutionResult logs how the command ended and, with LogOutput, the start
+// of its output unless the job is sensitive or the output log is sampled out.
+func (er *execRunner) logExecutionResult(result *ExecutionResult, sensitive bool) {
 	logLevel := slog.LevelInfo
 	if result.Error != nil {
 		logLevel = slog.LevelError
@@ -311,8 +637,8 @@ func (er *execRunner) logExecutionResult(result *ExecutionResult) {
 
 	slog.Log(context.Background(), logLevel, "Job execution completed", attrs...)
 
-	// Always log output content for debugging (with truncation for safety)
-	if er.config.LogOutput || er.config.VerboseLogging {
+	// Log output content for debugging (with truncation for safety)
+	if er.config.LogOutput && !sensitive && !er.dropOutputLog() {
 		if result.Stdout != "" {
 			stdoutToLog := result.Stdout
 			if len(stdoutToLog) > 1000 {
@@ -336,3 +662,9 @@ func (er *execRunner) logExecutionResult(result *ExecutionResult) {
 		)
 	}
 }
+
+// dropOutputLog reports whether this job's output logs are sampled out.
+func (er *execRunner) dropOutputLog() bool {
+	rate := er.config.LogOutputDropRate
+	return rate > 0 && rand.Float64() < rate
+}
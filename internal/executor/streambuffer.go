@@ -0,0 +1,146 @@
+package executor
+
+import (
+	"io"
+	"log/slog"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// defaultStreamBufferSize is how much output per stream is queued for a slow
+// consumer when ExecutorConfig.StreamBufferSize is unset.
+const defaultStreamBufferSize = 1024 * 1024
+
+// streamDrainTimeout bounds how long a finished run waits for a consumer to
+// take the output still queued for it. A variable so tests can shorten it.
+var streamDrainTimeout = 5 * time.Second
+
+// streamBuffer hands writes to a goroutine that feeds a possibly slow consumer,
+// so reading the process's output never waits on it. Writes beyond limit
+// queued bytes are dropped rather than block.
+type streamBuffer struct {
+	w       io.Writer
+	limit   int
+	mu      sync.Mutex
+	queue   [][]byte
+	pending int
+	dropped int
+	closed  bool
+	wake    chan struct{}
+	done    chan struct{}
+}
+
+func newStreamBuffer(w io.Writer, limit int) *streamBuffer {
+	b := &streamBuffer{w: w, limit: limit, wake: make(chan struct{}, 1), done: make(chan struct{})}
+	go b.run()
+	return b
+}
+
+// Write queues a copy of p and always reports success.
+func (b *streamBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	if b.closed || b.pending+len(p) > b.limit {
+		b.dropped += len(p)
+		b.mu.Unlock()
+		return len(p), nil
+	}
+	b.queue = append(b.queue, append([]byte(nil), p...))
+	b.pending += len(p)
+	b.mu.Unlock()
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
+
+func (b *streamBuffer) run() {
+	defer close(b.done)
+	for {
+		b.mu.Lock()
+		if len(b.queue) == 0 {
+			closed := b.closed
+			b.mu.Unlock()
+			if closed {
+				return
+			}
+			<-b.wake
+			continue
+		}
+		chunk := b.queue[0]
+		b.queue = b.queue[1:]
+		b.pending -= len(chunk)
+		b.mu.Unlock()
+		// A failing consumer loses this chunk but keeps getting later ones
+		_, _ = b.w.Write(chunk)
+	}
+}
+
+// close stops accepting writes and waits up to timeout for the queue to drain,
+// then discards what is left; a write already handed to the consumer may still
+// land later. It returns how many bytes the consumer missed.
+func (b *streamBuffer) close(timeout time.Duration) int {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-b.done:
+	case <-timer.C:
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dropped += b.pending
+	b.queue, b.pending = nil, 0
+	return b.dropped
+}
+
+// bufferStreams puts a streamBuffer in front of each non-nil consumer, one
+// shared by both streams when they go to the same writer so their relative
+// order is kept. The returned finish drains them, as far as streamDrainTimeout
+// allows, once the process's output has been read, and logs what a slow
+// consumer missed.
+func (er *execRunner) bufferStreams(jobID string, stdout, stderr io.Writer) (io.Writer, io.Writer, func()) {
+	limit := er.config.StreamBufferSize
+	if limit <= 0 {
+		limit = defaultStreamBufferSize
+	}
+	var buffers []*streamBuffer
+	wrap := func(w io.Writer) io.Writer {
+		if w == nil {
+			return nil
+		}
+		b := newStreamBuffer(w, limit)
+		buffers = append(buffers, b)
+		return b
+	}
+	if stdout != nil && sameWriter(stdout, stderr) {
+		stdout = wrap(stdout)
+		stderr = stdout
+	} else {
+		stdout, stderr = wrap(stdout), wrap(stderr)
+	}
+	return stdout, stderr, func() {
+		deadline := time.Now().Add(streamDrainTimeout)
+		dropped := 0
+		for _, b := range buffers {
+			dropped += b.close(time.Until(deadline))
+		}
+		if dropped > 0 {
+			slog.Warn("dropped streamed output the consumer could not keep up with", "job_id", jobID, "bytes", dropped)
+		}
+	}
+}
+
+// sameWriter reports whether a and b are the same writer, without panicking on
+// writers whose type cannot be compared.
+func sameWriter(a, b io.Writer) bool {
+	return reflect.TypeOf(a) == reflect.TypeOf(b) && reflect.TypeOf(a).Comparable() && a == b
+}
@@ -0,0 +1,36 @@
+package executor
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/paulgrammer/childprocess/internal/executor")
+
+// startRunSpan starts the span covering one command execution, within the
+// caller's trace if ctx carries one.
+func startRunSpan(ctx context.Context, jobID, command string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "executor.run", trace.WithAttributes(
+		attribute.String("job.id", jobID),
+		attribute.String("process.command", command),
+	))
+}
+
+// endRunSpan records the process's outcome and ends the span.
+func endRunSpan(span trace.Span, result *ExecutionResult, err error) {
+	if result != nil {
+		span.SetAttributes(
+			attribute.Int("process.pid", result.PID),
+			attribute.Int("process.exit_code", result.ExitCode),
+			attribute.Int64("job.duration_ms", result.Duration.Milliseconds()),
+		)
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
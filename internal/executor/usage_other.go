@@ -0,0 +1,8 @@
+//go:build !unix
+
+package executor
+
+import "os"
+
+// recordUsage is a no-op on platforms without getrusage; the usage fields stay zero.
+func (r *ExecutionResult) recordUsage(state *os.ProcessState) {}
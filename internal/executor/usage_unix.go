@@ -0,0 +1,28 @@
+//go:build unix
+
+package executor
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// recordUsage copies the CPU time and peak memory of a finished process.
+func (r *ExecutionResult) recordUsage(state *os.ProcessState) {
+	if state == nil {
+		return
+	}
+	ru, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok || ru == nil {
+		return
+	}
+	r.UserTime = time.Duration(ru.Utime.Nano())
+	r.SysTime = time.Duration(ru.Stime.Nano())
+	// ru_maxrss is in kilobytes, except on Apple platforms where it is in bytes
+	r.MaxRSSKB = int64(ru.Maxrss)
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		r.MaxRSSKB /= 1024
+	}
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package executor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRun_RecordsUsage(t *testing.T) {
+	// Busy-loop long enough to register some CPU time
+	script := "i=0; while [ $i -lt 200000 ]; do i=$((i+1)); done"
+	result, err := NewExecRunner().Run(context.Background(), "job-usage", "sh", []string{"-c", script}, "", nil, nil)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if result.MaxRSSKB <= 0 {
+		t.Fatalf("MaxRSSKB = %d, want > 0", result.MaxRSSKB)
+	}
+	if result.UserTime+result.SysTime <= 0 {
+		t.Fatalf("expected some CPU time, got user %v sys %v", result.UserTime, result.SysTime)
+	}
+}
@@ -0,0 +1,148 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// prepareWorkingDir resolves the job's working directory, creating it first when
+// the job asked for that. With a BaseWorkingDir, relative directories are taken
+// from the base and jobs without one get {base}/{jobID}, always created. The
+// returned cleanup removes a directory this call created if cleanup was
+// requested; otherwise it does nothing.
+func (er *execRunner) prepareWorkingDir(jobID, workingDir string, opts RunOptions) (string, func(), error) {
+	noop := func() {}
+	if base := er.config.BaseWorkingDir; base != "" {
+		if workingDir == "" {
+			workingDir = filepath.Join(base, jobID)
+			opts.CreateWorkingDir = true
+		} else if !filepath.IsAbs(workingDir) {
+			workingDir = filepath.Join(base, workingDir)
+		}
+	}
+	if !opts.CreateWorkingDir {
+		if workingDir == "" {
+			return "", noop, nil
+		}
+		dir, err := er.resolveWorkingDir(workingDir)
+		return dir, noop, err
+	}
+
+	var created string
+	if workingDir == "" {
+		dir, err := os.MkdirTemp(er.config.ScratchDir, "job-"+jobID+"-")
+		if err != nil {
+			return "", noop, fmt.Errorf("failed to create scratch directory: %w", err)
+		}
+		if err := os.Chmod(dir, er.workingDirPerm()); err != nil {
+			_ = os.RemoveAll(dir)
+			return "", noop, fmt.Errorf("failed to create scratch directory: %w", err)
+		}
+		created, workingDir = dir, dir
+	} else {
+		var err error
+		if created, err = er.createWorkingDir(workingDir); err != nil {
+			return "", noop, err
+		}
+	}
+
+	remove := func() {
+		if created == "" || !opts.CleanupWorkingDir {
+			return
+		}
+		if err := os.RemoveAll(created); err != nil {
+			slog.Warn("failed to clean up working directory", "job_id", jobID, "dir", created, "error", err)
+		}
+	}
+	// Resolving again applies the usual checks, including the roots, to what now exists
+	dir, err := er.resolveWorkingDir(workingDir)
+	if err != nil {
+		if created != "" {
+			_ = os.RemoveAll(created)
+		}
+		return "", noop, err
+	}
+	return dir, remove, nil
+}
+
+// createWorkingDir creates workingDir if it is missing and returns it, or "" if
+// it already existed. The path is checked against the allowed roots before
+// anything is created.
+func (er *execRunner) createWorkingDir(workingDir string) (string, error) {
+	abs, err := filepath.Abs(filepath.Clean(workingDir))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve working directory: %w", err)
+	}
+	if _, err := os.Lstat(abs); err == nil {
+		return "", nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	resolved, err := resolveMissing(abs)
+	if err != nil {
+		return "", err
+	}
+	if !er.inAllowedRoots(resolved) {
+		return "", errors.New("working directory is outside the allowed roots")
+	}
+	if !er.inBase(resolved) {
+		return "", errOutsideBase
+	}
+	if err := os.MkdirAll(abs, er.workingDirPerm()); err != nil {
+		return "", fmt.Errorf("failed to create working directory: %w", err)
+	}
+	return abs, nil
+}
+
+// resolveMissing resolves symlinks in the deepest existing ancestor of path and
+// appends the components that do not exist yet.
+func resolveMissing(path string) (string, error) {
+	var missing []string
+	for {
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				resolved = filepath.Join(resolved, missing[i])
+			}
+			return resolved, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("failed to resolve working directory: %w", err)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", errors.New("working directory has no existing ancestor")
+		}
+		missing = append(missing, filepath.Base(path))
+		path = parent
+	}
+}
+
+// errOutsideBase rejects working directories that leave BaseWorkingDir.
+var errOutsideBase = errors.New("working directory is outside the base working directory")
+
+// inBase reports whether a symlink-free absolute path lies under BaseWorkingDir,
+// which need not exist yet. Any path is inside when no base is configured.
+func (er *execRunner) inBase(resolved string) bool {
+	if er.config.BaseWorkingDir == "" {
+		return true
+	}
+	abs, err := filepath.Abs(filepath.Clean(er.config.BaseWorkingDir))
+	if err != nil {
+		return false
+	}
+	base, err := resolveMissing(abs)
+	if err != nil {
+		return false
+	}
+	return isWithin(base, resolved)
+}
+
+func (er *execRunner) workingDirPerm() os.FileMode {
+	if er.config.WorkingDirPerm == 0 {
+		return 0o750
+	}
+	return er.config.WorkingDirPerm
+}
@@ -0,0 +1,4 @@
+// Package jobspb contains the protobuf definitions and generated gRPC bindings for the job service.
+package jobspb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative jobs.proto
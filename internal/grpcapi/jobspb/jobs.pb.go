@@ -0,0 +1,1080 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.28.3
+// source: jobs.proto
+
+package jobspb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// CreateJobRequest mirrors jobs.CreateJobRequest.
+type CreateJobRequest struct {
+	state                  protoimpl.MessageState `protogen:"open.v1"`
+	Command                string                 `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
+	Args                   []string               `protobuf:"bytes,2,rep,name=args,proto3" json:"args,omitempty"`
+	WorkingDir             string                 `protobuf:"bytes,3,opt,name=working_dir,json=workingDir,proto3" json:"working_dir,omitempty"`
+	WebhookUrl             string                 `protobuf:"bytes,4,opt,name=webhook_url,json=webhookUrl,proto3" json:"webhook_url,omitempty"`
+	Metadata               map[string]string      `protobuf:"bytes,5,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	RunAsUser              string                 `protobuf:"bytes,6,opt,name=run_as_user,json=runAsUser,proto3" json:"run_as_user,omitempty"`
+	RunAsGroup             string                 `protobuf:"bytes,7,opt,name=run_as_group,json=runAsGroup,proto3" json:"run_as_group,omitempty"`
+	IncludeOutputInWebhook bool                   `protobuf:"varint,8,opt,name=include_output_in_webhook,json=includeOutputInWebhook,proto3" json:"include_output_in_webhook,omitempty"`
+	Template               string                 `protobuf:"bytes,9,opt,name=template,proto3" json:"template,omitempty"`
+	Params                 map[string]string      `protobuf:"bytes,10,rep,name=params,proto3" json:"params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Nice                   int32                  `protobuf:"varint,11,opt,name=nice,proto3" json:"nice,omitempty"`
+	Tags                   []string               `protobuf:"bytes,12,rep,name=tags,proto3" json:"tags,omitempty"`
+	CreateWorkingDir       bool                   `protobuf:"varint,13,opt,name=create_working_dir,json=createWorkingDir,proto3" json:"create_working_dir,omitempty"`
+	CleanupWorkingDir      bool                   `protobuf:"varint,14,opt,name=cleanup_working_dir,json=cleanupWorkingDir,proto3" json:"cleanup_working_dir,omitempty"`
+	MaxQueueWaitSeconds    int32                  `protobuf:"varint,15,opt,name=max_queue_wait_seconds,json=maxQueueWaitSeconds,proto3" json:"max_queue_wait_seconds,omitempty"`
+	TimeoutSeconds         int32                  `protobuf:"varint,16,opt,name=timeout_seconds,json=timeoutSeconds,proto3" json:"timeout_seconds,omitempty"`
+	Sensitive              bool                   `protobuf:"varint,17,opt,name=sensitive,proto3" json:"sensitive,omitempty"`
+	RedactOutput           bool                   `protobuf:"varint,18,opt,name=redact_output,json=redactOutput,proto3" json:"redact_output,omitempty"`
+	Id                     string                 `protobuf:"bytes,19,opt,name=id,proto3" json:"id,omitempty"`
+	WebhookEvents          []string               `protobuf:"bytes,20,rep,name=webhook_events,json=webhookEvents,proto3" json:"webhook_events,omitempty"`
+	OutputEncoding         string                 `protobuf:"bytes,21,opt,name=output_encoding,json=outputEncoding,proto3" json:"output_encoding,omitempty"`
+	Setup                  *SetupStep             `protobuf:"bytes,22,opt,name=setup,proto3" json:"setup,omitempty"`
+	unknownFields          protoimpl.UnknownFields
+	sizeCache              protoimpl.SizeCache
+}
+
+func (x *CreateJobRequest) Reset() {
+	*x = CreateJobRequest{}
+	mi := &file_jobs_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateJobRequest) ProtoMessage() {}
+
+func (x *CreateJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_jobs_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateJobRequest.ProtoReflect.Descriptor instead.
+func (*CreateJobRequest) Descriptor() ([]byte, []int) {
+	return file_jobs_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CreateJobRequest) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *CreateJobRequest) GetArgs() []string {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *CreateJobRequest) GetWorkingDir() string {
+	if x != nil {
+		return x.WorkingDir
+	}
+	return ""
+}
+
+func (x *CreateJobRequest) GetWebhookUrl() string {
+	if x != nil {
+		return x.WebhookUrl
+	}
+	return ""
+}
+
+func (x *CreateJobRequest) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *CreateJobRequest) GetRunAsUser() string {
+	if x != nil {
+		return x.RunAsUser
+	}
+	return ""
+}
+
+func (x *CreateJobRequest) GetRunAsGroup() string {
+	if x != nil {
+		return x.RunAsGroup
+	}
+	return ""
+}
+
+func (x *CreateJobRequest) GetIncludeOutputInWebhook() bool {
+	if x != nil {
+		return x.IncludeOutputInWebhook
+	}
+	return false
+}
+
+func (x *CreateJobRequest) GetTemplate() string {
+	if x != nil {
+		return x.Template
+	}
+	return ""
+}
+
+func (x *CreateJobRequest) GetParams() map[string]string {
+	if x != nil {
+		return x.Params
+	}
+	return nil
+}
+
+func (x *CreateJobRequest) GetNice() int32 {
+	if x != nil {
+		return x.Nice
+	}
+	return 0
+}
+
+func (x *CreateJobRequest) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *CreateJobRequest) GetCreateWorkingDir() bool {
+	if x != nil {
+		return x.CreateWorkingDir
+	}
+	return false
+}
+
+func (x *CreateJobRequest) GetCleanupWorkingDir() bool {
+	if x != nil {
+		return x.CleanupWorkingDir
+	}
+	return false
+}
+
+func (x *CreateJobRequest) GetMaxQueueWaitSeconds() int32 {
+	if x != nil {
+		return x.MaxQueueWaitSeconds
+	}
+	return 0
+}
+
+func (x *CreateJobRequest) GetTimeoutSeconds() int32 {
+	if x != nil {
+		return x.TimeoutSeconds
+	}
+	return 0
+}
+
+func (x *CreateJobRequest) GetSensitive() bool {
+	if x != nil {
+		return x.Sensitive
+	}
+	return false
+}
+
+func (x *CreateJobRequest) GetRedactOutput() bool {
+	if x != nil {
+		return x.RedactOutput
+	}
+	return false
+}
+
+func (x *CreateJobRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *CreateJobRequest) GetWebhookEvents() []string {
+	if x != nil {
+		return x.WebhookEvents
+	}
+	return nil
+}
+
+func (x *CreateJobRequest) GetOutputEncoding() string {
+	if x != nil {
+		return x.OutputEncoding
+	}
+	return ""
+}
+
+func (x *CreateJobRequest) GetSetup() *SetupStep {
+	if x != nil {
+		return x.Setup
+	}
+	return nil
+}
+
+// SetupStep mirrors jobs.SetupStep.
+type SetupStep struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Command       string                 `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
+	Args          []string               `protobuf:"bytes,2,rep,name=args,proto3" json:"args,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetupStep) Reset() {
+	*x = SetupStep{}
+	mi := &file_jobs_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetupStep) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetupStep) ProtoMessage() {}
+
+func (x *SetupStep) ProtoReflect() protoreflect.Message {
+	mi := &file_jobs_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetupStep.ProtoReflect.Descriptor instead.
+func (*SetupStep) Descriptor() ([]byte, []int) {
+	return file_jobs_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SetupStep) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *SetupStep) GetArgs() []string {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+type SubmitJobResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitJobResponse) Reset() {
+	*x = SubmitJobResponse{}
+	mi := &file_jobs_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitJobResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitJobResponse) ProtoMessage() {}
+
+func (x *SubmitJobResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_jobs_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitJobResponse.ProtoReflect.Descriptor instead.
+func (*SubmitJobResponse) Descriptor() ([]byte, []int) {
+	return file_jobs_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SubmitJobResponse) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *SubmitJobResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type GetJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetJobRequest) Reset() {
+	*x = GetJobRequest{}
+	mi := &file_jobs_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetJobRequest) ProtoMessage() {}
+
+func (x *GetJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_jobs_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetJobRequest.ProtoReflect.Descriptor instead.
+func (*GetJobRequest) Descriptor() ([]byte, []int) {
+	return file_jobs_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetJobRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+// Job mirrors jobs.Job.
+type Job struct {
+	state                   protoimpl.MessageState `protogen:"open.v1"`
+	Id                      string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Command                 string                 `protobuf:"bytes,2,opt,name=command,proto3" json:"command,omitempty"`
+	Args                    []string               `protobuf:"bytes,3,rep,name=args,proto3" json:"args,omitempty"`
+	WorkingDir              string                 `protobuf:"bytes,4,opt,name=working_dir,json=workingDir,proto3" json:"working_dir,omitempty"`
+	WebhookUrl              string                 `protobuf:"bytes,5,opt,name=webhook_url,json=webhookUrl,proto3" json:"webhook_url,omitempty"`
+	Metadata                map[string]string      `protobuf:"bytes,6,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	ExitCode                *int32                 `protobuf:"varint,7,opt,name=exit_code,json=exitCode,proto3,oneof" json:"exit_code,omitempty"`
+	Stdout                  *string                `protobuf:"bytes,8,opt,name=stdout,proto3,oneof" json:"stdout,omitempty"`
+	Stderr                  *string                `protobuf:"bytes,9,opt,name=stderr,proto3,oneof" json:"stderr,omitempty"`
+	Status                  string                 `protobuf:"bytes,10,opt,name=status,proto3" json:"status,omitempty"`
+	Error                   string                 `protobuf:"bytes,11,opt,name=error,proto3" json:"error,omitempty"`
+	CreatedAt               *timestamppb.Timestamp `protobuf:"bytes,12,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	StartedAt               *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	CompletedAt             *timestamppb.Timestamp `protobuf:"bytes,14,opt,name=completed_at,json=completedAt,proto3" json:"completed_at,omitempty"`
+	RetryOf                 string                 `protobuf:"bytes,15,opt,name=retry_of,json=retryOf,proto3" json:"retry_of,omitempty"`
+	RunAsUser               string                 `protobuf:"bytes,16,opt,name=run_as_user,json=runAsUser,proto3" json:"run_as_user,omitempty"`
+	RunAsGroup              string                 `protobuf:"bytes,17,opt,name=run_as_group,json=runAsGroup,proto3" json:"run_as_group,omitempty"`
+	IncludeOutputInWebhook  bool                   `protobuf:"varint,18,opt,name=include_output_in_webhook,json=includeOutputInWebhook,proto3" json:"include_output_in_webhook,omitempty"`
+	Template                string                 `protobuf:"bytes,19,opt,name=template,proto3" json:"template,omitempty"`
+	Params                  map[string]string      `protobuf:"bytes,20,rep,name=params,proto3" json:"params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	StdoutBytes             int64                  `protobuf:"varint,21,opt,name=stdout_bytes,json=stdoutBytes,proto3" json:"stdout_bytes,omitempty"`
+	StderrBytes             int64                  `protobuf:"varint,22,opt,name=stderr_bytes,json=stderrBytes,proto3" json:"stderr_bytes,omitempty"`
+	Truncated               bool                   `protobuf:"varint,23,opt,name=truncated,proto3" json:"truncated,omitempty"`
+	Nice                    int32                  `protobuf:"varint,24,opt,name=nice,proto3" json:"nice,omitempty"`
+	Version                 int64                  `protobuf:"varint,25,opt,name=version,proto3" json:"version,omitempty"`
+	UpdatedAt               *timestamppb.Timestamp `protobuf:"bytes,26,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Tags                    []string               `protobuf:"bytes,27,rep,name=tags,proto3" json:"tags,omitempty"`
+	Pid                     int32                  `protobuf:"varint,28,opt,name=pid,proto3" json:"pid,omitempty"`
+	CreateWorkingDir        bool                   `protobuf:"varint,29,opt,name=create_working_dir,json=createWorkingDir,proto3" json:"create_working_dir,omitempty"`
+	CleanupWorkingDir       bool                   `protobuf:"varint,30,opt,name=cleanup_working_dir,json=cleanupWorkingDir,proto3" json:"cleanup_working_dir,omitempty"`
+	UserTimeMs              int64                  `protobuf:"varint,31,opt,name=user_time_ms,json=userTimeMs,proto3" json:"user_time_ms,omitempty"`
+	SysTimeMs               int64                  `protobuf:"varint,32,opt,name=sys_time_ms,json=sysTimeMs,proto3" json:"sys_time_ms,omitempty"`
+	MaxRssKb                int64                  `protobuf:"varint,33,opt,name=max_rss_kb,json=maxRssKb,proto3" json:"max_rss_kb,omitempty"`
+	MaxQueueWaitSeconds     int32                  `protobuf:"varint,34,opt,name=max_queue_wait_seconds,json=maxQueueWaitSeconds,proto3" json:"max_queue_wait_seconds,omitempty"`
+	TimeoutSeconds          int32                  `protobuf:"varint,35,opt,name=timeout_seconds,json=timeoutSeconds,proto3" json:"timeout_seconds,omitempty"`
+	Sensitive               bool                   `protobuf:"varint,36,opt,name=sensitive,proto3" json:"sensitive,omitempty"`
+	RedactOutput            bool                   `protobuf:"varint,37,opt,name=redact_output,json=redactOutput,proto3" json:"redact_output,omitempty"`
+	DurationMs              int64                  `protobuf:"varint,38,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	OutputUrl               string                 `protobuf:"bytes,39,opt,name=output_url,json=outputUrl,proto3" json:"output_url,omitempty"`
+	OutputUploadError       string                 `protobuf:"bytes,40,opt,name=output_upload_error,json=outputUploadError,proto3" json:"output_upload_error,omitempty"`
+	WebhookEvents           []string               `protobuf:"bytes,41,rep,name=webhook_events,json=webhookEvents,proto3" json:"webhook_events,omitempty"`
+	EffectiveTimeoutSeconds int32                  `protobuf:"varint,42,opt,name=effective_timeout_seconds,json=effectiveTimeoutSeconds,proto3" json:"effective_timeout_seconds,omitempty"`
+	OutputEncoding          string                 `protobuf:"bytes,43,opt,name=output_encoding,json=outputEncoding,proto3" json:"output_encoding,omitempty"`
+	Setup                   *SetupStep             `protobuf:"bytes,44,opt,name=setup,proto3" json:"setup,omitempty"`
+	StdoutCapturedBytes     int64                  `protobuf:"varint,45,opt,name=stdout_captured_bytes,json=stdoutCapturedBytes,proto3" json:"stdout_captured_bytes,omitempty"`
+	StderrCapturedBytes     int64                  `protobuf:"varint,46,opt,name=stderr_captured_bytes,json=stderrCapturedBytes,proto3" json:"stderr_captured_bytes,omitempty"`
+	unknownFields           protoimpl.UnknownFields
+	sizeCache               protoimpl.SizeCache
+}
+
+func (x *Job) Reset() {
+	*x = Job{}
+	mi := &file_jobs_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Job) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Job) ProtoMessage() {}
+
+func (x *Job) ProtoReflect() protoreflect.Message {
+	mi := &file_jobs_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Job.ProtoReflect.Descriptor instead.
+func (*Job) Descriptor() ([]byte, []int) {
+	return file_jobs_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Job) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Job) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *Job) GetArgs() []string {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *Job) GetWorkingDir() string {
+	if x != nil {
+		return x.WorkingDir
+	}
+	return ""
+}
+
+func (x *Job) GetWebhookUrl() string {
+	if x != nil {
+		return x.WebhookUrl
+	}
+	return ""
+}
+
+func (x *Job) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *Job) GetExitCode() int32 {
+	if x != nil && x.ExitCode != nil {
+		return *x.ExitCode
+	}
+	return 0
+}
+
+func (x *Job) GetStdout() string {
+	if x != nil && x.Stdout != nil {
+		return *x.Stdout
+	}
+	return ""
+}
+
+func (x *Job) GetStderr() string {
+	if x != nil && x.Stderr != nil {
+		return *x.Stderr
+	}
+	return ""
+}
+
+func (x *Job) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Job) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Job) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Job) GetStartedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartedAt
+	}
+	return nil
+}
+
+func (x *Job) GetCompletedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CompletedAt
+	}
+	return nil
+}
+
+func (x *Job) GetRetryOf() string {
+	if x != nil {
+		return x.RetryOf
+	}
+	return ""
+}
+
+func (x *Job) GetRunAsUser() string {
+	if x != nil {
+		return x.RunAsUser
+	}
+	return ""
+}
+
+func (x *Job) GetRunAsGroup() string {
+	if x != nil {
+		return x.RunAsGroup
+	}
+	return ""
+}
+
+func (x *Job) GetIncludeOutputInWebhook() bool {
+	if x != nil {
+		return x.IncludeOutputInWebhook
+	}
+	return false
+}
+
+func (x *Job) GetTemplate() string {
+	if x != nil {
+		return x.Template
+	}
+	return ""
+}
+
+func (x *Job) GetParams() map[string]string {
+	if x != nil {
+		return x.Params
+	}
+	return nil
+}
+
+func (x *Job) GetStdoutBytes() int64 {
+	if x != nil {
+		return x.StdoutBytes
+	}
+	return 0
+}
+
+func (x *Job) GetStderrBytes() int64 {
+	if x != nil {
+		return x.StderrBytes
+	}
+	return 0
+}
+
+func (x *Job) GetTruncated() bool {
+	if x != nil {
+		return x.Truncated
+	}
+	return false
+}
+
+func (x *Job) GetNice() int32 {
+	if x != nil {
+		return x.Nice
+	}
+	return 0
+}
+
+func (x *Job) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Job) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Job) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Job) GetPid() int32 {
+	if x != nil {
+		return x.Pid
+	}
+	return 0
+}
+
+func (x *Job) GetCreateWorkingDir() bool {
+	if x != nil {
+		return x.CreateWorkingDir
+	}
+	return false
+}
+
+func (x *Job) GetCleanupWorkingDir() bool {
+	if x != nil {
+		return x.CleanupWorkingDir
+	}
+	return false
+}
+
+func (x *Job) GetUserTimeMs() int64 {
+	if x != nil {
+		return x.UserTimeMs
+	}
+	return 0
+}
+
+func (x *Job) GetSysTimeMs() int64 {
+	if x != nil {
+		return x.SysTimeMs
+	}
+	return 0
+}
+
+func (x *Job) GetMaxRssKb() int64 {
+	if x != nil {
+		return x.MaxRssKb
+	}
+	return 0
+}
+
+func (x *Job) GetMaxQueueWaitSeconds() int32 {
+	if x != nil {
+		return x.MaxQueueWaitSeconds
+	}
+	return 0
+}
+
+func (x *Job) GetTimeoutSeconds() int32 {
+	if x != nil {
+		return x.TimeoutSeconds
+	}
+	return 0
+}
+
+func (x *Job) GetSensitive() bool {
+	if x != nil {
+		return x.Sensitive
+	}
+	return false
+}
+
+func (x *Job) GetRedactOutput() bool {
+	if x != nil {
+		return x.RedactOutput
+	}
+	return false
+}
+
+func (x *Job) GetDurationMs() int64 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+func (x *Job) GetOutputUrl() string {
+	if x != nil {
+		return x.OutputUrl
+	}
+	return ""
+}
+
+func (x *Job) GetOutputUploadError() string {
+	if x != nil {
+		return x.OutputUploadError
+	}
+	return ""
+}
+
+func (x *Job) GetWebhookEvents() []string {
+	if x != nil {
+		return x.WebhookEvents
+	}
+	return nil
+}
+
+func (x *Job) GetEffectiveTimeoutSeconds() int32 {
+	if x != nil {
+		return x.EffectiveTimeoutSeconds
+	}
+	return 0
+}
+
+func (x *Job) GetOutputEncoding() string {
+	if x != nil {
+		return x.OutputEncoding
+	}
+	return ""
+}
+
+func (x *Job) GetSetup() *SetupStep {
+	if x != nil {
+		return x.Setup
+	}
+	return nil
+}
+
+func (x *Job) GetStdoutCapturedBytes() int64 {
+	if x != nil {
+		return x.StdoutCapturedBytes
+	}
+	return 0
+}
+
+func (x *Job) GetStderrCapturedBytes() int64 {
+	if x != nil {
+		return x.StderrCapturedBytes
+	}
+	return 0
+}
+
+type StreamLogsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamLogsRequest) Reset() {
+	*x = StreamLogsRequest{}
+	mi := &file_jobs_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamLogsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamLogsRequest) ProtoMessage() {}
+
+func (x *StreamLogsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_jobs_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamLogsRequest.ProtoReflect.Descriptor instead.
+func (*StreamLogsRequest) Descriptor() ([]byte, []int) {
+	return file_jobs_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *StreamLogsRequest) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+type LogChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogChunk) Reset() {
+	*x = LogChunk{}
+	mi := &file_jobs_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogChunk) ProtoMessage() {}
+
+func (x *LogChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_jobs_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogChunk.ProtoReflect.Descriptor instead.
+func (*LogChunk) Descriptor() ([]byte, []int) {
+	return file_jobs_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *LogChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+var File_jobs_proto protoreflect.FileDescriptor
+
+const file_jobs_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"jobs.proto\x12\x14childprocess.jobs.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xef\a\n" +
+	"\x10CreateJobRequest\x12\x18\n" +
+	"\acommand\x18\x01 \x01(\tR\acommand\x12\x12\n" +
+	"\x04args\x18\x02 \x03(\tR\x04args\x12\x1f\n" +
+	"\vworking_dir\x18\x03 \x01(\tR\n" +
+	"workingDir\x12\x1f\n" +
+	"\vwebhook_url\x18\x04 \x01(\tR\n" +
+	"webhookUrl\x12P\n" +
+	"\bmetadata\x18\x05 \x03(\v24.childprocess.jobs.v1.CreateJobRequest.MetadataEntryR\bmetadata\x12\x1e\n" +
+	"\vrun_as_user\x18\x06 \x01(\tR\trunAsUser\x12 \n" +
+	"\frun_as_group\x18\a \x01(\tR\n" +
+	"runAsGroup\x129\n" +
+	"\x19include_output_in_webhook\x18\b \x01(\bR\x16includeOutputInWebhook\x12\x1a\n" +
+	"\btemplate\x18\t \x01(\tR\btemplate\x12J\n" +
+	"\x06params\x18\n" +
+	" \x03(\v22.childprocess.jobs.v1.CreateJobRequest.ParamsEntryR\x06params\x12\x12\n" +
+	"\x04nice\x18\v \x01(\x05R\x04nice\x12\x12\n" +
+	"\x04tags\x18\f \x03(\tR\x04tags\x12,\n" +
+	"\x12create_working_dir\x18\r \x01(\bR\x10createWorkingDir\x12.\n" +
+	"\x13cleanup_working_dir\x18\x0e \x01(\bR\x11cleanupWorkingDir\x123\n" +
+	"\x16max_queue_wait_seconds\x18\x0f \x01(\x05R\x13maxQueueWaitSeconds\x12'\n" +
+	"\x0ftimeout_seconds\x18\x10 \x01(\x05R\x0etimeoutSeconds\x12\x1c\n" +
+	"\tsensitive\x18\x11 \x01(\bR\tsensitive\x12#\n" +
+	"\rredact_output\x18\x12 \x01(\bR\fredactOutput\x12\x0e\n" +
+	"\x02id\x18\x13 \x01(\tR\x02id\x12%\n" +
+	"\x0ewebhook_events\x18\x14 \x03(\tR\rwebhookEvents\x12'\n" +
+	"\x0foutput_encoding\x18\x15 \x01(\tR\x0eoutputEncoding\x125\n" +
+	"\x05setup\x18\x16 \x01(\v2\x1f.childprocess.jobs.v1.SetupStepR\x05setup\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a9\n" +
+	"\vParamsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"9\n" +
+	"\tSetupStep\x12\x18\n" +
+	"\acommand\x18\x01 \x01(\tR\acommand\x12\x12\n" +
+	"\x04args\x18\x02 \x03(\tR\x04args\"B\n" +
+	"\x11SubmitJobResponse\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\"\x1f\n" +
+	"\rGetJobRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x85\x0f\n" +
+	"\x03Job\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
+	"\acommand\x18\x02 \x01(\tR\acommand\x12\x12\n" +
+	"\x04args\x18\x03 \x03(\tR\x04args\x12\x1f\n" +
+	"\vworking_dir\x18\x04 \x01(\tR\n" +
+	"workingDir\x12\x1f\n" +
+	"\vwebhook_url\x18\x05 \x01(\tR\n" +
+	"webhookUrl\x12C\n" +
+	"\bmetadata\x18\x06 \x03(\v2'.childprocess.jobs.v1.Job.MetadataEntryR\bmetadata\x12 \n" +
+	"\texit_code\x18\a \x01(\x05H\x00R\bexitCode\x88\x01\x01\x12\x1b\n" +
+	"\x06stdout\x18\b \x01(\tH\x01R\x06stdout\x88\x01\x01\x12\x1b\n" +
+	"\x06stderr\x18\t \x01(\tH\x02R\x06stderr\x88\x01\x01\x12\x16\n" +
+	"\x06status\x18\n" +
+	" \x01(\tR\x06status\x12\x14\n" +
+	"\x05error\x18\v \x01(\tR\x05error\x129\n" +
+	"\n" +
+	"created_at\x18\f \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"started_at\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\tstartedAt\x12=\n" +
+	"\fcompleted_at\x18\x0e \x01(\v2\x1a.google.protobuf.TimestampR\vcompletedAt\x12\x19\n" +
+	"\bretry_of\x18\x0f \x01(\tR\aretryOf\x12\x1e\n" +
+	"\vrun_as_user\x18\x10 \x01(\tR\trunAsUser\x12 \n" +
+	"\frun_as_group\x18\x11 \x01(\tR\n" +
+	"runAsGroup\x129\n" +
+	"\x19include_output_in_webhook\x18\x12 \x01(\bR\x16includeOutputInWebhook\x12\x1a\n" +
+	"\btemplate\x18\x13 \x01(\tR\btemplate\x12=\n" +
+	"\x06params\x18\x14 \x03(\v2%.childprocess.jobs.v1.Job.ParamsEntryR\x06params\x12!\n" +
+	"\fstdout_bytes\x18\x15 \x01(\x03R\vstdoutBytes\x12!\n" +
+	"\fstderr_bytes\x18\x16 \x01(\x03R\vstderrBytes\x12\x1c\n" +
+	"\ttruncated\x18\x17 \x01(\bR\ttruncated\x12\x12\n" +
+	"\x04nice\x18\x18 \x01(\x05R\x04nice\x12\x18\n" +
+	"\aversion\x18\x19 \x01(\x03R\aversion\x129\n" +
+	"\n" +
+	"updated_at\x18\x1a \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12\x12\n" +
+	"\x04tags\x18\x1b \x03(\tR\x04tags\x12\x10\n" +
+	"\x03pid\x18\x1c \x01(\x05R\x03pid\x12,\n" +
+	"\x12create_working_dir\x18\x1d \x01(\bR\x10createWorkingDir\x12.\n" +
+	"\x13cleanup_working_dir\x18\x1e \x01(\bR\x11cleanupWorkingDir\x12 \n" +
+	"\fuser_time_ms\x18\x1f \x01(\x03R\n" +
+	"userTimeMs\x12\x1e\n" +
+	"\vsys_time_ms\x18  \x01(\x03R\tsysTimeMs\x12\x1c\n" +
+	"\n" +
+	"max_rss_kb\x18! \x01(\x03R\bmaxRssKb\x123\n" +
+	"\x16max_queue_wait_seconds\x18\" \x01(\x05R\x13maxQueueWaitSeconds\x12'\n" +
+	"\x0ftimeout_seconds\x18# \x01(\x05R\x0etimeoutSeconds\x12\x1c\n" +
+	"\tsensitive\x18$ \x01(\bR\tsensitive\x12#\n" +
+	"\rredact_output\x18% \x01(\bR\fredactOutput\x12\x1f\n" +
+	"\vduration_ms\x18& \x01(\x03R\n" +
+	"durationMs\x12\x1d\n" +
+	"\n" +
+	"output_url\x18' \x01(\tR\toutputUrl\x12.\n" +
+	"\x13output_upload_error\x18( \x01(\tR\x11outputUploadError\x12%\n" +
+	"\x0ewebhook_events\x18) \x03(\tR\rwebhookEvents\x12:\n" +
+	"\x19effective_timeout_seconds\x18* \x01(\x05R\x17effectiveTimeoutSeconds\x12'\n" +
+	"\x0foutput_encoding\x18+ \x01(\tR\x0eoutputEncoding\x125\n" +
+	"\x05setup\x18, \x01(\v2\x1f.childprocess.jobs.v1.SetupStepR\x05setup\x122\n" +
+	"\x15stdout_captured_bytes\x18- \x01(\x03R\x13stdoutCapturedBytes\x122\n" +
+	"\x15stderr_captured_bytes\x18. \x01(\x03R\x13stderrCapturedBytes\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a9\n" +
+	"\vParamsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\f\n" +
+	"\n" +
+	"_exit_codeB\t\n" +
+	"\a_stdoutB\t\n" +
+	"\a_stderr\"*\n" +
+	"\x11StreamLogsRequest\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\"\x1e\n" +
+	"\bLogChunk\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data2\x8d\x02\n" +
+	"\n" +
+	"JobService\x12\\\n" +
+	"\tSubmitJob\x12&.childprocess.jobs.v1.CreateJobRequest\x1a'.childprocess.jobs.v1.SubmitJobResponse\x12H\n" +
+	"\x06GetJob\x12#.childprocess.jobs.v1.GetJobRequest\x1a\x19.childprocess.jobs.v1.Job\x12W\n" +
+	"\n" +
+	"StreamLogs\x12'.childprocess.jobs.v1.StreamLogsRequest\x1a\x1e.childprocess.jobs.v1.LogChunk0\x01B=Z;github.com/paulgrammer/childprocess/internal/grpcapi/jobspbb\x06proto3"
+
+var (
+	file_jobs_proto_rawDescOnce sync.Once
+	file_jobs_proto_rawDescData []byte
+)
+
+func file_jobs_proto_rawDescGZIP() []byte {
+	file_jobs_proto_rawDescOnce.Do(func() {
+		file_jobs_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_jobs_proto_rawDesc), len(file_jobs_proto_rawDesc)))
+	})
+	return file_jobs_proto_rawDescData
+}
+
+var file_jobs_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_jobs_proto_goTypes = []any{
+	(*CreateJobRequest)(nil),      // 0: childprocess.jobs.v1.CreateJobRequest
+	(*SetupStep)(nil),             // 1: childprocess.jobs.v1.SetupStep
+	(*SubmitJobResponse)(nil),     // 2: childprocess.jobs.v1.SubmitJobResponse
+	(*GetJobRequest)(nil),         // 3: childprocess.jobs.v1.GetJobRequest
+	(*Job)(nil),                   // 4: childprocess.jobs.v1.Job
+	(*StreamLogsRequest)(nil),     // 5: childprocess.jobs.v1.StreamLogsRequest
+	(*LogChunk)(nil),              // 6: childprocess.jobs.v1.LogChunk
+	nil,                           // 7: childprocess.jobs.v1.CreateJobRequest.MetadataEntry
+	nil,                           // 8: childprocess.jobs.v1.CreateJobRequest.ParamsEntry
+	nil,                           // 9: childprocess.jobs.v1.Job.MetadataEntry
+	nil,                           // 10: childprocess.jobs.v1.Job.ParamsEntry
+	(*timestamppb.Timestamp)(nil), // 11: google.protobuf.Timestamp
+}
+var file_jobs_proto_depIdxs = []int32{
+	7,  // 0: childprocess.jobs.v1.CreateJobRequest.metadata:type_name -> childprocess.jobs.v1.CreateJobRequest.MetadataEntry
+	8,  // 1: childprocess.jobs.v1.CreateJobRequest.params:type_name -> childprocess.jobs.v1.CreateJobRequest.ParamsEntry
+	1,  // 2: childprocess.jobs.v1.CreateJobRequest.setup:type_name -> childprocess.jobs.v1.SetupStep
+	9,  // 3: childprocess.jobs.v1.Job.metadata:type_name -> childprocess.jobs.v1.Job.MetadataEntry
+	11, // 4: childprocess.jobs.v1.Job.created_at:type_name -> google.protobuf.Timestamp
+	11, // 5: childprocess.jobs.v1.Job.started_at:type_name -> google.protobuf.Timestamp
+	11, // 6: childprocess.jobs.v1.Job.completed_at:type_name -> google.protobuf.Timestamp
+	10, // 7: childprocess.jobs.v1.Job.params:type_name -> childprocess.jobs.v1.Job.ParamsEntry
+	11, // 8: childprocess.jobs.v1.Job.updated_at:type_name -> google.protobuf.Timestamp
+	1,  // 9: childprocess.jobs.v1.Job.setup:type_name -> childprocess.jobs.v1.SetupStep
+	0,  // 10: childprocess.jobs.v1.JobService.SubmitJob:input_type -> childprocess.jobs.v1.CreateJobRequest
+	3,  // 11: childprocess.jobs.v1.JobService.GetJob:input_type -> childprocess.jobs.v1.GetJobRequest
+	5,  // 12: childprocess.jobs.v1.JobService.StreamLogs:input_type -> childprocess.jobs.v1.StreamLogsRequest
+	2,  // 13: childprocess.jobs.v1.JobService.SubmitJob:output_type -> childprocess.jobs.v1.SubmitJobResponse
+	4,  // 14: childprocess.jobs.v1.JobService.GetJob:output_type -> childprocess.jobs.v1.Job
+	6,  // 15: childprocess.jobs.v1.JobService.StreamLogs:output_type -> childprocess.jobs.v1.LogChunk
+	13, // [13:16] is the sub-list for method output_type
+	10, // [10:13] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_jobs_proto_init() }
+func file_jobs_proto_init() {
+	if File_jobs_proto != nil {
+		return
+	}
+	file_jobs_proto_msgTypes[4].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_jobs_proto_rawDesc), len(file_jobs_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_jobs_proto_goTypes,
+		DependencyIndexes: file_jobs_proto_depIdxs,
+		MessageInfos:      file_jobs_proto_msgTypes,
+	}.Build()
+	File_jobs_proto = out.File
+	file_jobs_proto_goTypes = nil
+	file_jobs_proto_depIdxs = nil
+}
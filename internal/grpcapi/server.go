@@ -0,0 +1,223 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/paulgrammer/childprocess/internal/grpcapi/jobspb"
+	"github.com/paulgrammer/childprocess/internal/jobs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type server struct {
+	jobspb.UnimplementedJobServiceServer
+	manager  *jobs.Manager
+	streamer *jobs.LogStreamer
+}
+
+// WithClientCertForMutations refuses SubmitJob calls that did not present a
+// verified client certificate, as httpapi does for non-GET requests. It needs
+// the server to run with TLS credentials that verify client certificates.
+func WithClientCertForMutations() grpc.ServerOption {
+	return grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if info.FullMethod == jobspb.JobService_SubmitJob_FullMethodName && !hasVerifiedClientCert(ctx) {
+			return nil, status.Error(codes.Unauthenticated, "client certificate required")
+		}
+		return handler(ctx, req)
+	})
+}
+
+func hasVerifiedClientCert(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	return ok && len(info.State.VerifiedChains) > 0
+}
+
+// NewServer returns a gRPC server exposing the JobService backed by the given manager and streamer.
+func NewServer(manager *jobs.Manager, streamer *jobs.LogStreamer, opts ...grpc.ServerOption) *grpc.Server {
+	gs := grpc.NewServer(opts...)
+	jobspb.RegisterJobServiceServer(gs, &server{manager: manager, streamer: streamer})
+	return gs
+}
+
+func (s *server) SubmitJob(ctx context.Context, req *jobspb.CreateJobRequest) (*jobspb.SubmitJobResponse, error) {
+	body := jobs.CreateJobRequest{
+		ID:         req.GetId(),
+		Command:    req.GetCommand(),
+		Args:       req.GetArgs(),
+		WorkingDir: req.GetWorkingDir(),
+		WebhookURL: req.GetWebhookUrl(),
+		Metadata:   req.GetMetadata(),
+		RunAsUser:  req.GetRunAsUser(),
+		RunAsGroup: req.GetRunAsGroup(),
+
+		IncludeOutputInWebhook: req.GetIncludeOutputInWebhook(),
+		Template:               req.GetTemplate(),
+		Params:                 req.GetParams(),
+		Nice:                   int(req.GetNice()),
+		Tags:                   req.GetTags(),
+		CreateWorkingDir:       req.GetCreateWorkingDir(),
+		CleanupWorkingDir:      req.GetCleanupWorkingDir(),
+		MaxQueueWaitSeconds:    int(req.GetMaxQueueWaitSeconds()),
+		TimeoutSeconds:         int(req.GetTimeoutSeconds()),
+		Sensitive:              req.GetSensitive(),
+		RedactOutput:           req.GetRedactOutput(),
+		WebhookEvents:          req.GetWebhookEvents(),
+		OutputEncoding:         req.GetOutputEncoding(),
+	}
+	if s := req.GetSetup(); s != nil {
+		body.Setup = &jobs.SetupStep{Command: s.GetCommand(), Args: s.GetArgs()}
+	}
+	if body.Command == "" && body.Template == "" && len(body.Args) > 0 {
+		body.Command = body.Args[0]
+		body.Args = body.Args[1:]
+	}
+
+	id, err := s.manager.Submit(ctx, body)
+	if errors.Is(err, jobs.ErrInvalidRequest) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, jobs.ErrJobExists) {
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	}
+	if errors.Is(err, jobs.ErrQueueFull) || errors.Is(err, jobs.ErrTooManyJobs) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if errors.Is(err, jobs.ErrManagerStopped) {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to queue job")
+	}
+	return &jobspb.SubmitJobResponse{JobId: id, Status: string(jobs.JobStatusQueued)}, nil
+}
+
+func (s *server) GetJob(ctx context.Context, req *jobspb.GetJobRequest) (*jobspb.Job, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "job id required")
+	}
+	job, ok := s.manager.Get(req.GetId())
+	if !ok {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	return toProtoJob(job), nil
+}
+
+func (s *server) StreamLogs(req *jobspb.StreamLogsRequest, stream jobspb.JobService_StreamLogsServer) error {
+	id := req.GetJobId()
+	if id == "" {
+		return status.Error(codes.InvalidArgument, "job id required")
+	}
+	if _, ok := s.manager.Get(id); !ok {
+		return status.Error(codes.NotFound, "not found")
+	}
+
+	sub := &streamSubscriber{stream: stream, done: make(chan struct{})}
+	if err := s.streamer.Subscribe(id, sub); err != nil {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	defer s.streamer.Unsubscribe(id, sub)
+
+	// The job may have finished before we subscribed, in which case no Close will arrive.
+	if job, ok := s.manager.Get(id); ok && job.Status.Terminal() {
+		return nil
+	}
+
+	select {
+	case <-sub.done:
+		return nil
+	case <-stream.Context().Done():
+		return stream.Context().Err()
+	}
+}
+
+// streamSubscriber adapts a server stream to jobs.LogSubscriber.
+type streamSubscriber struct {
+	mu     sync.Mutex
+	stream jobspb.JobService_StreamLogsServer
+	once   sync.Once
+	done   chan struct{}
+}
+
+func (s *streamSubscriber) WriteMessage(_ int, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stream.Send(&jobspb.LogChunk{Data: append([]byte(nil), data...)})
+}
+
+func (s *streamSubscriber) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return nil
+}
+
+func toProtoJob(j jobs.Job) *jobspb.Job {
+	out := &jobspb.Job{
+		Id:         j.ID,
+		Command:    j.Command,
+		Args:       j.Args,
+		WorkingDir: j.WorkingDir,
+		WebhookUrl: j.WebhookURL,
+		Metadata:   j.Metadata,
+		Stdout:     j.Stdout,
+		Stderr:     j.Stderr,
+		Status:     string(j.Status),
+		Error:      j.Error,
+		CreatedAt:  timestamppb.New(j.CreatedAt),
+		RetryOf:    j.RetryOf,
+		RunAsUser:  j.RunAsUser,
+		RunAsGroup: j.RunAsGroup,
+
+		IncludeOutputInWebhook: j.IncludeOutputInWebhook,
+		Template:               j.Template,
+		Params:                 j.Params,
+		StdoutBytes:            int64(j.StdoutBytes),
+		StderrBytes:            int64(j.StderrBytes),
+		Truncated:              j.Truncated,
+		StdoutCapturedBytes:    int64(j.StdoutCapturedBytes),
+		StderrCapturedBytes:    int64(j.StderrCapturedBytes),
+		Nice:                   int32(j.Nice),
+		Version:                j.Version,
+		UpdatedAt:              timestamppb.New(j.UpdatedAt),
+		Tags:                   j.Tags,
+		Pid:                    int32(j.PID),
+		CreateWorkingDir:       j.CreateWorkingDir,
+		CleanupWorkingDir:      j.CleanupWorkingDir,
+		UserTimeMs:             j.UserTimeMs,
+		SysTimeMs:              j.SysTimeMs,
+		MaxRssKb:               j.MaxRSSKB,
+		DurationMs:             j.DurationMs,
+		OutputUrl:              j.OutputURL,
+		OutputUploadError:      j.OutputUploadError,
+		MaxQueueWaitSeconds:    int32(j.MaxQueueWaitSeconds),
+		TimeoutSeconds:         int32(j.TimeoutSeconds),
+		Sensitive:              j.Sensitive,
+		RedactOutput:           j.RedactOutput,
+		WebhookEvents:          j.WebhookEvents,
+		OutputEncoding:         j.OutputEncoding,
+
+		EffectiveTimeoutSeconds: int32(j.EffectiveTimeoutSeconds),
+	}
+	if j.Setup != nil {
+		out.Setup = &jobspb.SetupStep{Command: j.Setup.Command, Args: j.Setup.Args}
+	}
+	if j.ExitCode != nil {
+		code := int32(*j.ExitCode)
+		out.ExitCode = &code
+	}
+	if j.StartedAt != nil {
+		out.StartedAt = timestamppb.New(*j.StartedAt)
+	}
+	if j.CompletedAt != nil {
+		out.CompletedAt = timestamppb.New(*j.CompletedAt)
+	}
+	return out
+}
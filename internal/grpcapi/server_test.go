@@ -0,0 +1,176 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/paulgrammer/childprocess/internal/executor"
+	"github.com/paulgrammer/childprocess/internal/grpcapi/jobspb"
+	"github.com/paulgrammer/childprocess/internal/jobs"
+	"github.com/paulgrammer/childprocess/internal/webhook"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestServer_SubmitAndGetJob(t *testing.T) {
+	streamer := jobs.NewLogStreamer()
+	manager, err := jobs.NewManager(1, jobs.NewInMemoryStore(), webhook.NewHTTPSender(time.Second, 0), executor.NewExecRunner(), streamer)
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	defer manager.Stop()
+
+	lis := bufconn.Listen(1 << 20)
+	gs := NewServer(manager, streamer)
+	go gs.Serve(lis)
+	defer gs.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	client := jobspb.NewJobServiceClient(conn)
+	ctx := context.Background()
+
+	resp, err := client.SubmitJob(ctx, &jobspb.CreateJobRequest{Command: "echo", Args: []string{"hello"}})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := client.GetJob(ctx, &jobspb.GetJobRequest{Id: resp.GetJobId()})
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		if job.GetStatus() == string(jobs.JobStatusCompleted) {
+			if job.GetExitCode() != 0 {
+				t.Fatalf("expected exit code 0, got %d", job.GetExitCode())
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job did not complete, last status %q", job.GetStatus())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_, err = client.GetJob(ctx, &jobspb.GetJobRequest{Id: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
+
+func TestServer_SubmitToStoppedManagerIsUnavailable(t *testing.T) {
+	streamer := jobs.NewLogStreamer()
+	manager, err := jobs.NewManager(1, jobs.NewInMemoryStore(), webhook.NewHTTPSender(time.Second, 0), executor.NewExecRunner(), streamer)
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	manager.Stop()
+
+	_, err = (&server{manager: manager, streamer: streamer}).SubmitJob(context.Background(), &jobspb.CreateJobRequest{Command: "echo"})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable, got %v", err)
+	}
+}
+
+// newCert returns a certificate for cn signed by parent, or self-signed when
+// parent is nil.
+func newCert(t *testing.T, cn string, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := tmpl, any(key)
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestServer_SubmitRequiresClientCert(t *testing.T) {
+	streamer := jobs.NewLogStreamer()
+	manager, err := jobs.NewManager(1, jobs.NewInMemoryStore(), webhook.NewHTTPSender(time.Second, 0), executor.NewExecRunner(), streamer)
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	defer manager.Stop()
+
+	ca := newCert(t, "ca", nil)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	serverTLS := &tls.Config{
+		Certificates: []tls.Certificate{newCert(t, "bufnet", &ca)},
+		ClientCAs:    pool,
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	gs := NewServer(manager, streamer, grpc.Creds(credentials.NewTLS(serverTLS)), WithClientCertForMutations())
+	go gs.Serve(lis)
+	defer gs.Stop()
+
+	dial := func(clientCerts ...tls.Certificate) jobspb.JobServiceClient {
+		conn, err := grpc.NewClient("passthrough:///bufnet",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+			grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: pool, ServerName: "bufnet", Certificates: clientCerts})),
+		)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return jobspb.NewJobServiceClient(conn)
+	}
+	ctx := context.Background()
+
+	anonymous := dial()
+	if _, err := anonymous.SubmitJob(ctx, &jobspb.CreateJobRequest{Command: "echo"}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated without a client certificate, got %v", err)
+	}
+	// Reads stay open, as on the HTTP API
+	if _, err := anonymous.GetJob(ctx, &jobspb.GetJobRequest{Id: "missing"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+
+	if _, err := dial(newCert(t, "client", &ca)).SubmitJob(ctx, &jobspb.CreateJobRequest{Command: "echo"}); err != nil {
+		t.Fatalf("expected a client with a certificate to submit, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package httpapi
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/paulgrammer/childprocess/internal/jobs"
+)
+
+// adminOnly rejects requests whose X-API-Key does not match the admin key.
+func (r *router) adminOnly(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		key := req.Header.Get("X-API-Key")
+		if subtle.ConstantTimeCompare([]byte(key), []byte(r.adminAPIKey)) != 1 {
+			respondWithError(w, http.StatusUnauthorized, "admin api key required")
+			return
+		}
+		next(w, req)
+	})
+}
+
+func (r *router) handleAdminQueue(w http.ResponseWriter, req *http.Request) {
+	ids := r.manager.QueuedIDs()
+	respondWithJSON(w, http.StatusOK, map[string]any{"depth": len(ids), "job_ids": ids})
+}
+
+func (r *router) handleAdminQueueFlush(w http.ResponseWriter, req *http.Request) {
+	ids := r.manager.FlushQueue(req.Context())
+	respondWithJSON(w, http.StatusOK, map[string]any{"flushed": len(ids), "job_ids": ids})
+}
+
+func (r *router) handleAdminPoolPause(w http.ResponseWriter, req *http.Request) {
+	r.manager.Pause()
+	respondWithJSON(w, http.StatusOK, map[string]bool{"paused": true})
+}
+
+func (r *router) handleAdminPoolResume(w http.ResponseWriter, req *http.Request) {
+	r.manager.Resume()
+	respondWithJSON(w, http.StatusOK, map[string]bool{"paused": false})
+}
+
+// handleEvents streams the status events of every job over a WebSocket,
+// optionally only those with a status in ?status=a,b.
+func (r *router) handleEvents(w http.ResponseWriter, req *http.Request) {
+	var statuses []jobs.JobStatus
+	if raw := req.URL.Query().Get("status"); raw != "" {
+		for _, s := range strings.Split(raw, ",") {
+			status := jobs.JobStatus(strings.TrimSpace(s))
+			if !slices.Contains(jobs.JobStatuses, status) {
+				respondWithError(w, http.StatusBadRequest, "unknown status "+string(status))
+				return
+			}
+			statuses = append(statuses, status)
+		}
+	}
+
+	conn, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		slog.Error("failed to upgrade connection", "error", err)
+		return
+	}
+	r.manager.SubscribeAllEvents(conn, statuses...)
+	defer r.manager.UnsubscribeAllEvents(conn)
+
+	// Keep the connection open until the client goes away
+	for {
+		if _, _, err := conn.NextReader(); err != nil {
+			conn.Close()
+			break
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/paulgrammer/childprocess/internal/jobs"
+)
+
+// ErrorCode is a stable, machine-readable identifier for an API error.
+type ErrorCode string
+
+const (
+	CodeInvalidRequest ErrorCode = "INVALID_REQUEST"
+	CodeUnauthorized   ErrorCode = "UNAUTHORIZED"
+	CodeNotFound       ErrorCode = "NOT_FOUND"
+	CodeConflict       ErrorCode = "CONFLICT"
+	CodeUnavailable    ErrorCode = "UNAVAILABLE"
+	CodeQueueFull      ErrorCode = "QUEUE_FULL"
+	CodeTooManyJobs    ErrorCode = "TOO_MANY_JOBS"
+	CodeTooLarge       ErrorCode = "PAYLOAD_TOO_LARGE"
+	CodeInternal       ErrorCode = "INTERNAL"
+)
+
+// AppError is the error body returned by every endpoint:
+// {"error":{"code":"...","message":"...","details":{...}}}
+type AppError struct {
+	Status  int            `json:"-"`
+	Code    ErrorCode      `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+func (e AppError) Error() string {
+	return string(e.Code) + ": " + e.Message
+}
+
+// codeForStatus picks the default code for errors raised directly by handlers.
+func codeForStatus(status int) ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case http.StatusRequestEntityTooLarge:
+		return CodeTooLarge
+	default:
+		return CodeInternal
+	}
+}
+
+// appErrorFrom maps errors returned by the jobs package to API errors.
+// fallback is used as the message for unrecognized errors so internals are not leaked.
+func appErrorFrom(err error, fallback string) AppError {
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		return AppError{Status: http.StatusNotFound, Code: CodeNotFound, Message: "not found"}
+	case errors.Is(err, jobs.ErrInvalidRequest):
+		appErr := AppError{Status: http.StatusBadRequest, Code: CodeInvalidRequest, Message: err.Error()}
+		var fe jobs.FieldErrors
+		if errors.As(err, &fe) {
+			appErr.Details = map[string]any{"fields": fe}
+		}
+		return appErr
+	case errors.Is(err, jobs.ErrJobExists):
+		return AppError{Status: http.StatusConflict, Code: CodeConflict, Message: "job id already exists"}
+	case errors.Is(err, jobs.ErrQueueFull):
+		return AppError{Status: http.StatusServiceUnavailable, Code: CodeQueueFull, Message: "job queue is full, retry later"}
+	case errors.Is(err, jobs.ErrTooManyJobs):
+		return AppError{Status: http.StatusServiceUnavailable, Code: CodeTooManyJobs, Message: "too many unfinished jobs, retry later"}
+	case errors.Is(err, jobs.ErrManagerStopped):
+		return AppError{Status: http.StatusServiceUnavailable, Code: CodeUnavailable, Message: "server is shutting down"}
+	default:
+		return AppError{Status: http.StatusInternalServerError, Code: CodeInternal, Message: fallback}
+	}
+}
@@ -0,0 +1,37 @@
+package httpapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/paulgrammer/childprocess/internal/jobs"
+)
+
+func TestAppErrorFrom(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		status int
+		code   ErrorCode
+	}{
+		{jobs.ErrJobNotFound, http.StatusNotFound, CodeNotFound},
+		{fmt.Errorf("%w: %w", jobs.ErrInvalidRequest, jobs.FieldErrors{"command": "must not be empty"}), http.StatusBadRequest, CodeInvalidRequest},
+		{jobs.ErrQueueFull, http.StatusServiceUnavailable, CodeQueueFull},
+		{jobs.ErrTooManyJobs, http.StatusServiceUnavailable, CodeTooManyJobs},
+		{jobs.ErrManagerStopped, http.StatusServiceUnavailable, CodeUnavailable},
+		{errors.New("disk on fire"), http.StatusInternalServerError, CodeInternal},
+	} {
+		got := appErrorFrom(tc.err, "fallback")
+		if got.Status != tc.status || got.Code != tc.code {
+			t.Errorf("%v: got %d %s, want %d %s", tc.err, got.Status, got.Code, tc.status, tc.code)
+		}
+	}
+
+	if got := appErrorFrom(errors.New("disk on fire"), "fallback"); got.Message != "fallback" {
+		t.Errorf("expected internal errors to use the fallback message, got %q", got.Message)
+	}
+	if got := appErrorFrom(fmt.Errorf("%w: %w", jobs.ErrInvalidRequest, jobs.FieldErrors{"command": "x"}), ""); got.Details["fields"] == nil {
+		t.Errorf("expected field details on validation errors, got %+v", got)
+	}
+}
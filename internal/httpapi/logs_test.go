@@ -0,0 +1,453 @@
+package httpapi
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/paulgrammer/childprocess/internal/executor"
+	"github.com/paulgrammer/childprocess/internal/jobs"
+	"github.com/paulgrammer/childprocess/internal/webhook"
+)
+
+func TestRouter_LogStreamEndsWithResultAndCloseFrame(t *testing.T) {
+	srv := httptest.NewServer(newTestRouter(t))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/jobs", "application/json", strings.NewReader(`{"command":"sh","args":["-c","sleep 0.3; echo hi; exit 3"]}`))
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	var accepted map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&accepted); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	resp.Body.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/jobs/"+accepted["job_id"]+"/logs", nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var logs strings.Builder
+	var last []byte
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err == nil {
+			logs.Write(msg)
+			last = msg
+			continue
+		}
+		var ce *websocket.CloseError
+		if !errors.As(err, &ce) {
+			t.Fatalf("expected a close frame, got %v (logs %q)", err, logs.String())
+		}
+		if ce.Code != jobs.CloseJobFailed {
+			t.Fatalf("expected close code %d for a failed job, got %d", jobs.CloseJobFailed, ce.Code)
+		}
+		var reason struct {
+			Status   string `json:"status"`
+			ExitCode *int   `json:"exit_code"`
+		}
+		if err := json.Unmarshal([]byte(ce.Text), &reason); err != nil {
+			t.Fatalf("expected a JSON close reason, got %q", ce.Text)
+		}
+		if reason.Status != "failed" || reason.ExitCode == nil || *reason.ExitCode != 3 {
+			t.Fatalf("expected failed with exit 3, got %+v", reason)
+		}
+		break
+	}
+	if !strings.Contains(logs.String(), "hi") {
+		t.Fatalf("expected job output before the close frame, got %q", logs.String())
+	}
+	var result struct {
+		Type     string `json:"type"`
+		Status   string `json:"status"`
+		ExitCode *int   `json:"exit_code"`
+	}
+	if err := json.Unmarshal(last, &result); err != nil {
+		t.Fatalf("expected a JSON result as the last message, got %q", last)
+	}
+	if result.Type != "result" || result.Status != "failed" || result.ExitCode == nil || *result.ExitCode != 3 {
+		t.Fatalf("expected a failed result with exit 3, got %s", last)
+	}
+}
+
+func TestRouter_LogStreamClosesNormallyOnSuccess(t *testing.T) {
+	srv := httptest.NewServer(newTestRouter(t))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/jobs", "application/json", strings.NewReader(`{"command":"sh","args":["-c","sleep 0.3; echo ok"]}`))
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	var accepted map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&accepted); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	resp.Body.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/jobs/"+accepted["job_id"]+"/logs", nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Fatalf("expected a normal close for a completed job, got %v", err)
+	}
+}
+
+func TestRouter_JobEventsEndAfterTerminalEvent(t *testing.T) {
+	srv := httptest.NewServer(newTestRouter(t))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/jobs", "application/json", strings.NewReader(`{"command":"sh","args":["-c","sleep 0.3"]}`))
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	var accepted map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&accepted); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	resp.Body.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/jobs/"+accepted["job_id"]+"/events", nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var statuses []string
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				t.Fatalf("expected a normal close, got %v (events %v)", err, statuses)
+			}
+			break
+		}
+		var event struct {
+			JobID  string `json:"job_id"`
+			Status string `json:"status"`
+		}
+		if err := json.Unmarshal(msg, &event); err != nil || event.JobID != accepted["job_id"] {
+			t.Fatalf("unexpected event %q", msg)
+		}
+		statuses = append(statuses, event.Status)
+	}
+	if len(statuses) == 0 || statuses[len(statuses)-1] != "completed" {
+		t.Fatalf("expected the stream to end with completed, got %v", statuses)
+	}
+}
+
+// newArchivedJob runs a job with body as its request on a router that archives
+// logs, and returns the router once the job's archive is complete.
+func newArchivedJob(t *testing.T, body string) (http.Handler, jobs.Job) {
+	t.Helper()
+	sink, err := jobs.NewFileLogSink(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	streamer := jobs.NewLogStreamer(jobs.WithLogSink(sink))
+	manager, err := jobs.NewManager(1, jobs.NewInMemoryStore(), webhook.NewHTTPSender(time.Second, 0), executor.NewExecRunner(), streamer)
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(manager.Stop)
+	h := NewRouter(manager, streamer)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs?wait=true", strings.NewReader(body)))
+	var job jobs.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatalf("decode job: %v", err)
+	}
+	// The stream, and with it the archive, is closed just after the job finishes
+	time.Sleep(50 * time.Millisecond)
+	return h, job
+}
+
+func TestRouter_LogArchiveSupportsRanges(t *testing.T) {
+	h, job := newArchivedJob(t, `{"command":"printf","args":["0123456789"]}`)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID+"/logs/archive", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Accept-Ranges") != "bytes" {
+		t.Fatalf("expected 200 with Accept-Ranges, got %d %v", rec.Code, rec.Header())
+	}
+	full := rec.Body.String()
+	etag := rec.Header().Get("ETag")
+	if !strings.Contains(full, "0123456789") || etag == "" {
+		t.Fatalf("expected the job output and an ETag, got %q (etag %q)", full, etag)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID+"/logs/archive", nil)
+	req.Header.Set("Range", "bytes=4-9")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("expected 206, got %d", rec.Code)
+	}
+	if got := rec.Body.String(); got != full[4:10] {
+		t.Fatalf("range body = %q, want %q", got, full[4:10])
+	}
+	if got := rec.Header().Get("Content-Length"); got != "6" {
+		t.Fatalf("Content-Length = %q, want 6", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID+"/logs/archive", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for a matching ETag, got %d", rec.Code)
+	}
+}
+
+func TestRouter_LogArchiveGzip(t *testing.T) {
+	h, job := newArchivedJob(t, `{"command":"seq","args":["1","5000"]}`)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID+"/logs/archive", nil))
+	full := rec.Body.String()
+	if rec.Header().Get("Content-Encoding") != "" || !strings.Contains(full, "\n5000\n") {
+		t.Fatalf("expected the plain archive without Accept-Encoding, got %v", rec.Header())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID+"/logs/archive", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("expected a gzip response, got %d %v", rec.Code, rec.Header())
+	}
+	if rec.Body.Len() >= len(full) {
+		t.Fatalf("expected the body to shrink, got %d bytes for %d", rec.Body.Len(), len(full))
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("failed to open gzip body: %v", err)
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil || string(raw) != full {
+		t.Fatalf("expected the body to decompress to the archive, got %d bytes (%v)", len(raw), err)
+	}
+	etag := rec.Header().Get("ETag")
+	if !strings.HasPrefix(etag, "W/") {
+		t.Fatalf("expected a weak ETag on the compressed body, got %q", etag)
+	}
+
+	// Still revalidates with the weak ETag
+	req = httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID+"/logs/archive", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for the weak ETag, got %d", rec.Code)
+	}
+
+	// Ranges refer to the uncompressed archive
+	req = httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID+"/logs/archive", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Range", "bytes=0-1999")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusPartialContent || rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != full[:2000] {
+		t.Fatalf("expected an uncompressed 206, got %d %v", rec.Code, rec.Header())
+	}
+
+	// Small downloads are not worth compressing
+	req = httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID+"/logs/tail?lines=3", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "4998\n4999\n5000\n" {
+		t.Fatalf("expected a plain tail, got %v %q", rec.Header(), rec.Body.String())
+	}
+}
+
+func TestRouter_LogArchiveConflictWhileRunning(t *testing.T) {
+	h := newTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(`{"command":"sleep","args":["0.3"]}`)))
+	var accepted map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &accepted); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+accepted["job_id"]+"/logs/archive", nil))
+	var resp struct {
+		Error AppError `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusConflict || resp.Error.Code != CodeConflict {
+		t.Fatalf("expected 409 CONFLICT, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRouter_LogTail(t *testing.T) {
+	h := newTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs?wait=true", strings.NewReader(`{"command":"seq","args":["1","500"]}`)))
+	var job jobs.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil || job.Status != jobs.JobStatusCompleted {
+		t.Fatalf("expected a completed job, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID+"/logs/tail?lines=3", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "498\n499\n500\n" {
+		t.Fatalf("expected the last 3 lines in order, got %d %q", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("expected plain text, got %q", ct)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID+"/logs/tail", nil))
+	if got := strings.Count(rec.Body.String(), "\n"); got != defaultTailLines || !strings.HasPrefix(rec.Body.String(), "401\n") {
+		t.Fatalf("expected the default %d lines, got %d", defaultTailLines, got)
+	}
+
+	for _, lines := range []string{"0", "-1", "x"} {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID+"/logs/tail?lines="+lines, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("lines=%s: expected 400, got %d", lines, rec.Code)
+		}
+	}
+}
+
+func TestRouter_LogStreamTailAndFollow(t *testing.T) {
+	streamer := jobs.NewLogStreamer(jobs.WithBacklog(10))
+	manager, err := jobs.NewManager(2, jobs.NewInMemoryStore(), webhook.NewHTTPSender(time.Second, 0), executor.NewExecRunner(), streamer)
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(manager.Stop)
+	srv := httptest.NewServer(NewRouter(manager, streamer))
+	defer srv.Close()
+	ctx := context.Background()
+	dial := func(id, query string) *websocket.Conn {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/jobs/"+id+"/logs?"+query, nil)
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		return conn
+	}
+	readAll := func(conn *websocket.Conn) ([]string, error) {
+		var msgs []string
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return msgs, err
+			}
+			msgs = append(msgs, string(msg))
+		}
+	}
+
+	// A running job without follow gets its last lines and a normal close
+	running, err := manager.Submit(ctx, jobs.CreateJobRequest{Command: "sh", Args: []string{"-c", "printf 'one\\ntwo\\nthree\\n'; exec sleep 5"}})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	defer manager.Cancel(ctx, running)
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(string(streamer.Tail(running, 10)), "three") {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for output")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	conn := dial(running, "tail=2&follow=false")
+	msgs, err := readAll(conn)
+	conn.Close()
+	if !websocket.IsCloseError(err, websocket.CloseNormalClosure) || strings.Join(msgs, "") != "two\nthree\n" {
+		t.Fatalf("expected the last 2 lines then a normal close, got %q, %v", msgs, err)
+	}
+
+	// A finished job gets its last lines, result and close frame straight away
+	done, err := manager.Submit(ctx, jobs.CreateJobRequest{Command: "seq", Args: []string{"1", "5"}})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	if _, err := manager.Wait(ctx, done); err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	conn = dial(done, "tail=2")
+	msgs, err = readAll(conn)
+	conn.Close()
+	if !websocket.IsCloseError(err, websocket.CloseNormalClosure) || len(msgs) != 2 || msgs[0] != "4\n5\n" || !strings.Contains(msgs[1], `"type":"result"`) {
+		t.Fatalf("expected the last 2 lines and the result, got %q, %v", msgs, err)
+	}
+
+	for _, query := range []string{"tail=-1", "tail=x", "follow=maybe"} {
+		resp, err := http.Get(srv.URL + "/jobs/" + done + "/logs?" + query)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, resp.StatusCode)
+		}
+	}
+	resp, err := http.Get(srv.URL + "/jobs/missing/logs")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown job, got %d", resp.StatusCode)
+	}
+}
+
+func TestTailLines(t *testing.T) {
+	var b strings.Builder
+	for i := range 20000 {
+		fmt.Fprintf(&b, "line %05d\n", i)
+	}
+	full := b.String()
+	for _, tc := range []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{full, 2, "line 19998\nline 19999\n"},
+		{full, 9000, full[len(full)-9000*11:]}, // spans several read chunks
+		{"a\nb", 1, "b"},
+		{"a\nb\n", 5, "a\nb\n"},
+		{"", 3, ""},
+	} {
+		got, err := tailLines(strings.NewReader(tc.in), tc.n)
+		if err != nil {
+			t.Fatalf("tailLines: %v", err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("tailLines(%d lines of %d bytes) = %d bytes, want %d", tc.n, len(tc.in), len(got), len(tc.want))
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package httpapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec is the handwritten OpenAPI 3 document for this API.
+// openapi_test.go keeps its schemas in sync with the jobs package types.
+//
+//go:embed static/openapi.json
+var openAPISpec []byte
+
+//go:embed static/docs.html
+var docsPage []byte
+
+func (r *router) handleOpenAPI(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(openAPISpec)
+}
+
+func (r *router) handleDocs(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("content-type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(docsPage)
+}
@@ -0,0 +1,75 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/paulgrammer/childprocess/internal/buildinfo"
+	"github.com/paulgrammer/childprocess/internal/jobs"
+)
+
+type openAPIDoc struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func jsonFieldNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func schemaFieldNames(doc openAPIDoc, schema string) []string {
+	var names []string
+	for name := range doc.Components.Schemas[schema].Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestOpenAPISpec_MatchesTypes(t *testing.T) {
+	var doc openAPIDoc
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("openapi.json is not valid json: %v", err)
+	}
+
+	for schema, typ := range map[string]reflect.Type{
+		"CreateJobRequest": reflect.TypeOf(jobs.CreateJobRequest{}),
+		"Job":              reflect.TypeOf(jobs.Job{}),
+		"BuildInfo":        reflect.TypeOf(buildinfo.Info{}),
+	} {
+		want := jsonFieldNames(typ)
+		got := schemaFieldNames(doc, schema)
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("schema %s out of sync with struct tags:\n want %v\n  got %v", schema, want, got)
+		}
+	}
+
+	for _, route := range []struct{ path, method string }{
+		{"/healthz", "get"},
+		{"/version", "get"},
+		{"/jobs", "post"},
+		{"/jobs/{id}", "get"},
+		{"/jobs/{id}/logs", "get"},
+	} {
+		if _, ok := doc.Paths[route.path][route.method]; !ok {
+			t.Errorf("missing %s %s in spec", strings.ToUpper(route.method), route.path)
+		}
+	}
+}
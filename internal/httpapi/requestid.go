@@ -0,0 +1,53 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/paulgrammer/childprocess/internal/jobs"
+)
+
+// requestIDHeader is the header used to propagate correlation IDs.
+const requestIDHeader = "X-Request-ID"
+
+// requestIDMetadataKey is the Job.Metadata key that carries the submitting request's ID.
+const requestIDMetadataKey = "request_id"
+
+type requestIDKey struct{}
+
+// requestID reads the incoming X-Request-ID (or generates one), stores it in the
+// request context and echoes it back in the response header.
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" {
+			id = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// withRequestID records id under requestIDMetadataKey. It is left out when it
+// would push metadata past the jobs limits, so that a request valid as sent is
+// not rejected for an entry the caller did not add.
+func withRequestID(metadata map[string]string, id string) map[string]string {
+	if id == "" || len(id) > jobs.MaxMetadataValueBytes {
+		return metadata
+	}
+	if _, ok := metadata[requestIDMetadataKey]; !ok && len(metadata) >= jobs.MaxMetadataEntries {
+		return metadata
+	}
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	metadata[requestIDMetadataKey] = id
+	return metadata
+}
+
+// requestIDFromContext returns the request ID stored by the requestID middleware, if any.
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
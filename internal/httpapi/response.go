@@ -15,9 +15,17 @@ func respondWithJSON(w http.ResponseWriter, status int, payload interface{}) {
 	}
 }
 
-// respondWithError writes a standardized JSON error payload.
+// respondWithError writes a standardized JSON error payload with the default code for status.
 func respondWithError(w http.ResponseWriter, status int, message string) {
-	respondWithJSON(w, status, map[string]string{"error": message})
+	respondWithAppError(w, AppError{Status: status, Code: codeForStatus(status), Message: message})
+}
+
+// respondWithAppError writes err as {"error":{"code":...,"message":...,"details":...}}.
+func respondWithAppError(w http.ResponseWriter, err AppError) {
+	if err.Status == 0 {
+		err.Status = http.StatusInternalServerError
+	}
+	respondWithJSON(w, err.Status, map[string]AppError{"error": err})
 }
 
 
@@ -2,11 +2,18 @@ package httpapi
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/paulgrammer/childprocess/internal/buildinfo"
 	"github.com/paulgrammer/childprocess/internal/jobs"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -20,31 +27,149 @@ var upgrader = websocket.Upgrader{
 }
 
 type router struct {
-	manager  *jobs.Manager
-	streamer *jobs.LogStreamer
+	manager            *jobs.Manager
+	streamer           *jobs.LogStreamer
+	frontendDir        string
+	requireClientCerts bool
+	adminAPIKey        string
+	maxWait            time.Duration
+	maxBodyBytes       int64
+	lenientJSON        bool
 }
 
-func NewRouter(manager *jobs.Manager, streamer *jobs.LogStreamer) http.Handler {
-	r := &router{manager: manager, streamer: streamer}
+// defaultMaxWait bounds POST /jobs?wait=true when no WithMaxWait is given.
+const defaultMaxWait = 50 * time.Second
+
+// defaultMaxBodyBytes bounds job submissions when no WithMaxBodyBytes is given.
+const defaultMaxBodyBytes = 1 << 20
+
+type RouterOption func(*router)
+
+// WithFrontendDir serves static files from dir on GET requests that match no API route.
+// The directory is ignored if it does not exist.
+func WithFrontendDir(dir string) RouterOption {
+	return func(r *router) {
+		r.frontendDir = dir
+	}
+}
+
+// WithClientCertForMutations rejects non-GET requests that did not present a
+// verified TLS client certificate.
+func WithClientCertForMutations() RouterOption {
+	return func(r *router) {
+		r.requireClientCerts = true
+	}
+}
+
+// WithAdminAPIKey enables the /admin routes, guarded by the given key sent in X-API-Key.
+// Without a key the admin routes are not registered.
+func WithAdminAPIKey(key string) RouterOption {
+	return func(r *router) {
+		r.adminAPIKey = key
+	}
+}
+
+// WithMaxWait caps how long POST /jobs?wait=true holds the request open, whatever
+// timeout the client asks for. The server's write timeout must allow for it.
+func WithMaxWait(d time.Duration) RouterOption {
+	return func(r *router) {
+		if d > 0 {
+			r.maxWait = d
+		}
+	}
+}
+
+// WithMaxBodyBytes caps the size of a job submission; larger bodies get 413.
+func WithMaxBodyBytes(n int64) RouterOption {
+	return func(r *router) {
+		if n > 0 {
+			r.maxBodyBytes = n
+		}
+	}
+}
+
+// WithLenientJSON accepts job submissions with fields CreateJobRequest does not
+// have, ignoring them, instead of rejecting them with 400.
+func WithLenientJSON() RouterOption {
+	return func(r *router) {
+		r.lenientJSON = true
+	}
+}
+
+func NewRouter(manager *jobs.Manager, streamer *jobs.LogStreamer, opts ...RouterOption) http.Handler {
+	r := &router{manager: manager, streamer: streamer, maxWait: defaultMaxWait, maxBodyBytes: defaultMaxBodyBytes}
+	for _, opt := range opts {
+		opt(r)
+	}
 	m := http.NewServeMux()
 	m.HandleFunc("GET /healthz", r.handleHealth)
+	m.HandleFunc("GET /readyz", r.handleReady)
+	m.HandleFunc("GET /version", r.handleVersion)
+	m.HandleFunc("GET /jobs", r.handleJobBatch)
 	m.HandleFunc("POST /jobs", r.handleJobs)
+	m.HandleFunc("POST /jobs/cancel", r.handleCancelByTag)
 	m.HandleFunc("GET /jobs/{id}", r.handleJob)
+	m.HandleFunc("DELETE /jobs/{id}", r.handleJobDelete)
+	m.HandleFunc("POST /jobs/{id}/retry", r.handleJobRetry)
 	m.HandleFunc("GET /jobs/{id}/logs", r.handleJobLogs)
+	m.HandleFunc("GET /jobs/{id}/events", r.handleJobEvents)
+	m.HandleFunc("GET /jobs/{id}/logs/archive", r.handleJobLogArchive)
+	m.HandleFunc("GET /jobs/{id}/logs/tail", r.handleJobLogTail)
+	m.HandleFunc("GET /stats", r.handleStats)
 	m.Handle("GET /metrics", promhttp.Handler())
-	m.Handle("/", http.FileServer(http.Dir("./frontend")))
-	return logging(m)
+	if r.adminAPIKey != "" {
+		m.Handle("GET /admin/queue", r.adminOnly(r.handleAdminQueue))
+		m.Handle("POST /admin/queue/flush", r.adminOnly(r.handleAdminQueueFlush))
+		m.Handle("POST /admin/pool/pause", r.adminOnly(r.handleAdminPoolPause))
+		m.Handle("POST /admin/pool/resume", r.adminOnly(r.handleAdminPoolResume))
+		m.Handle("GET /events", r.adminOnly(r.handleEvents))
+	}
+	m.HandleFunc("GET /openapi.json", r.handleOpenAPI)
+	m.HandleFunc("GET /docs", r.handleDocs)
+	if isDir(r.frontendDir) {
+		m.Handle("GET /", http.FileServer(http.Dir(r.frontendDir)))
+	} else if r.frontendDir != "" {
+		slog.Warn("frontend directory not found, static file serving disabled", "dir", r.frontendDir)
+	}
+	m.HandleFunc("/", r.handleNotFound)
+	var h http.Handler = m
+	if r.requireClientCerts {
+		h = clientCertForMutations(h)
+	}
+	return requestID(traceContext(logging(gzipResponses(h))))
 }
 
 func (r *router) handleJobs(w http.ResponseWriter, req *http.Request) {
 	var body jobs.CreateJobRequest
-	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+	req.Body = http.MaxBytesReader(w, req.Body, r.maxBodyBytes)
+	dec := json.NewDecoder(req.Body)
+	if !r.lenientJSON {
+		// A misspelled field would otherwise be dropped silently, e.g. "commnd"
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(&body); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+			return
+		}
+		// encoding/json has no error type for this, only the message
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			field, _ = strconv.Unquote(field)
+			respondWithAppError(w, AppError{
+				Status:  http.StatusBadRequest,
+				Code:    CodeInvalidRequest,
+				Message: fmt.Sprintf("unknown field %q", field),
+				Details: map[string]any{"fields": map[string]string{field: "unknown field"}},
+			})
+			return
+		}
 		respondWithError(w, http.StatusBadRequest, "invalid json")
 		return
 	}
 
 	// Ensure command is set safely
-	if body.Command == "" {
+	if body.Command == "" && body.Template == "" {
 		if len(body.Args) > 0 {
 			// use first arg as command, rest as args
 			body.Command = body.Args[0]
@@ -52,9 +177,40 @@ func (r *router) handleJobs(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
+	body.Metadata = withRequestID(body.Metadata, requestIDFromContext(req.Context()))
+
+	// wait=true runs the job synchronously; it is canceled if the client disconnects.
+	// Past the timeout the unfinished job is returned with a Location to poll.
+	if req.URL.Query().Get("wait") == "true" {
+		timeout := r.maxWait
+		if v := req.URL.Query().Get("timeout"); v != "" {
+			secs, err := strconv.Atoi(v)
+			if err != nil || secs <= 0 {
+				respondWithError(w, http.StatusBadRequest, "timeout must be a positive number of seconds")
+				return
+			}
+			if secs < int(timeout/time.Second) {
+				timeout = time.Duration(secs) * time.Second
+			}
+		}
+		job, err := r.manager.SubmitAndWait(req.Context(), body, timeout)
+		if req.Context().Err() != nil {
+			return
+		}
+		if err != nil {
+			respondWithAppError(w, appErrorFrom(err, "failed to queue job"))
+			return
+		}
+		if !job.Status.Terminal() {
+			w.Header().Set("Location", "/jobs/"+job.ID)
+		}
+		respondWithJSON(w, http.StatusOK, job)
+		return
+	}
+
 	id, err := r.manager.Submit(req.Context(), body)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "failed to queue job")
+		respondWithAppError(w, appErrorFrom(err, "failed to queue job"))
 		return
 	}
 	respondWithJSON(w, http.StatusAccepted, map[string]string{"job_id": id, "status": string(jobs.JobStatusQueued)})
@@ -71,27 +227,404 @@ func (r *router) handleJob(w http.ResponseWriter, req *http.Request) {
 		respondWithError(w, http.StatusNotFound, "not found")
 		return
 	}
+	// Pollers revalidate every time and get 304 until the job changes
+	etag := jobETag(job)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(req.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 	respondWithJSON(w, http.StatusOK, job)
 }
 
+// jobETag identifies a version of a job. The store advances Version on every
+// update, so it changes exactly when the body does. It is weak because the
+// body may be re-encoded by the gzip middleware.
+func jobETag(job jobs.Job) string {
+	return fmt.Sprintf(`W/"%s-%d"`, job.Status, job.Version)
+}
+
+// etagMatches applies the weak comparison If-None-Match calls for.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	want := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
+
+// maxBatchIDs caps how many jobs one GET /jobs?ids= request may look up
+const maxBatchIDs = 100
+
+// jobBatch is the response of GET /jobs?ids=
+type jobBatch struct {
+	Jobs     map[string]jobs.Job `json:"jobs"`
+	NotFound []string            `json:"not_found"`
+}
+
+func (r *router) handleJobBatch(w http.ResponseWriter, req *http.Request) {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range strings.Split(req.URL.Query().Get("ids"), ",") {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		respondWithError(w, http.StatusBadRequest, "ids required")
+		return
+	}
+	if len(ids) > maxBatchIDs {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("at most %d ids per request", maxBatchIDs))
+		return
+	}
+
+	batch := jobBatch{Jobs: make(map[string]jobs.Job, len(ids)), NotFound: []string{}}
+	for _, id := range ids {
+		if job, ok := r.manager.Get(id); ok {
+			batch.Jobs[id] = job
+		} else {
+			batch.NotFound = append(batch.NotFound, id)
+		}
+	}
+	respondWithJSON(w, http.StatusOK, batch)
+}
+
+// handleJobDelete purges a finished job, answering 204. A queued or running job
+// is canceled instead and kept, answering 202 with its state; delete it again
+// once it has finished.
+func (r *router) handleJobDelete(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+	err := r.manager.Delete(req.Context(), id)
+	if errors.Is(err, jobs.ErrJobNotFinished) {
+		if err := r.manager.Cancel(req.Context(), id); err != nil {
+			respondWithAppError(w, appErrorFrom(err, "failed to cancel job"))
+			return
+		}
+		job, _ := r.manager.Get(id)
+		respondWithJSON(w, http.StatusAccepted, job)
+		return
+	}
+	if err != nil {
+		respondWithAppError(w, appErrorFrom(err, "failed to delete job"))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (r *router) handleJobRetry(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+	if id == "" {
+		respondWithError(w, http.StatusBadRequest, "job id required")
+		return
+	}
+	newID, err := r.manager.Retry(req.Context(), id)
+	if err != nil {
+		respondWithAppError(w, appErrorFrom(err, "failed to queue job"))
+		return
+	}
+	respondWithJSON(w, http.StatusAccepted, map[string]string{"job_id": newID, "status": string(jobs.JobStatusQueued), "retry_of": id})
+}
+
+func (r *router) handleCancelByTag(w http.ResponseWriter, req *http.Request) {
+	tag := req.URL.Query().Get("tag")
+	if tag == "" {
+		respondWithError(w, http.StatusBadRequest, "tag required")
+		return
+	}
+	n, err := r.manager.CancelByTag(req.Context(), tag)
+	if err != nil {
+		respondWithAppError(w, appErrorFrom(err, "failed to cancel jobs"))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]int{"canceled": n})
+}
+
 func logging(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		next.ServeHTTP(w, r)
-		slog.Info("http request", "method", r.Method, "path", r.URL.Path, "duration", time.Since(start).String())
+		slog.Info("http request", "method", r.Method, "path", r.URL.Path, "duration", time.Since(start).String(), "request_id", requestIDFromContext(r.Context()))
+	})
+}
+
+func clientCertForMutations(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+				respondWithError(w, http.StatusUnauthorized, "client certificate required")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
 	})
 }
 
+func (r *router) handleNotFound(w http.ResponseWriter, req *http.Request) {
+	respondWithError(w, http.StatusNotFound, "not found")
+}
+
+func isDir(path string) bool {
+	if path == "" {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
 func (r *router) handleHealth(w http.ResponseWriter, req *http.Request) {
 	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
+func (r *router) handleVersion(w http.ResponseWriter, req *http.Request) {
+	respondWithJSON(w, http.StatusOK, buildinfo.Get())
+}
+
+func (r *router) handleStats(w http.ResponseWriter, req *http.Request) {
+	stats, err := r.manager.Stats()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to count jobs")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, stats)
+}
+
+type readiness struct {
+	Status string `json:"status"`
+	jobs.Health
+}
+
+func (r *router) handleReady(w http.ResponseWriter, req *http.Request) {
+	h := r.manager.Health()
+	if !h.Ready {
+		respondWithJSON(w, http.StatusServiceUnavailable, readiness{Status: "not_ready", Health: h})
+		return
+	}
+	respondWithJSON(w, http.StatusOK, readiness{Status: "ready", Health: h})
+}
+
+// archiveFile is a log archive that can be served with http.ServeContent, such
+// as the *os.File opened by FileLogSink.
+type archiveFile interface {
+	io.ReadSeeker
+	Stat() (os.FileInfo, error)
+}
+
+func (r *router) handleJobLogArchive(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+	if id == "" {
+		respondWithError(w, http.StatusBadRequest, "job id required")
+		return
+	}
+	job, ok := r.manager.Get(id)
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "not found")
+		return
+	}
+	// The log only stops growing once the job is done, so ranges and ETags
+	// would not hold before then
+	if !job.Status.Terminal() {
+		respondWithError(w, http.StatusConflict, "job has not finished")
+		return
+	}
+
+	archive, err := r.streamer.Archive(id)
+	switch {
+	case errors.Is(err, jobs.ErrNoLogSink):
+		respondWithError(w, http.StatusNotFound, "log archive not configured")
+		return
+	case errors.Is(err, os.ErrNotExist):
+		respondWithError(w, http.StatusNotFound, "no logs archived for job")
+		return
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, "failed to open log archive")
+		return
+	}
+	defer archive.Close()
+
+	w.Header().Set("content-type", "text/plain; charset=utf-8")
+	// File-backed archives support Range and conditional requests, so large
+	// downloads can be resumed
+	if f, ok := archive.(archiveFile); ok {
+		if info, err := f.Stat(); err == nil {
+			w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+			http.ServeContent(w, req, "", info.ModTime(), f)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, archive); err != nil {
+		slog.Error("failed to write log archive", "job_id", id, "error", err)
+	}
+}
+
+// Line counts accepted by GET /jobs/{id}/logs/tail; larger requests are capped.
+const (
+	defaultTailLines = 100
+	maxTailLines     = 10000
+)
+
+// handleJobLogTail returns the last lines of a finished job's output, read
+// backwards from the log archive when there is one and from the stored stdout
+// and stderr otherwise.
+func (r *router) handleJobLogTail(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+	if id == "" {
+		respondWithError(w, http.StatusBadRequest, "job id required")
+		return
+	}
+	lines := defaultTailLines
+	if v := req.URL.Query().Get("lines"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			respondWithError(w, http.StatusBadRequest, "lines must be a positive integer")
+			return
+		}
+		lines = min(n, maxTailLines)
+	}
+	job, ok := r.manager.Get(id)
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if !job.Status.Terminal() {
+		respondWithError(w, http.StatusConflict, "job has not finished")
+		return
+	}
+
+	tail, err := r.outputTail(job, lines)
+	if errors.Is(err, errNoOutput) {
+		respondWithError(w, http.StatusNotFound, "no output captured for job")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to read log tail", "job_id", id, "error", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to read log tail")
+		return
+	}
+	w.Header().Set("content-type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(tail)
+}
+
+var errNoOutput = errors.New("no output captured for job")
+
+// outputTail returns the last lines of a finished job's output, read from its
+// log archive if there is one and from its stored output otherwise.
+func (r *router) outputTail(job jobs.Job, lines int) ([]byte, error) {
+	var src io.ReadSeeker
+	archive, err := r.streamer.Archive(job.ID)
+	switch {
+	case err == nil:
+		defer archive.Close()
+		if rs, ok := archive.(io.ReadSeeker); ok {
+			src = rs
+		}
+	case !errors.Is(err, jobs.ErrNoLogSink) && !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("failed to open log archive: %w", err)
+	}
+	if src == nil {
+		if job.Stdout == nil && job.Stderr == nil {
+			return nil, errNoOutput
+		}
+		src = strings.NewReader(joinOutput(job.Stdout, job.Stderr))
+	}
+	return tailLines(src, lines)
+}
+
+// joinOutput puts stderr after stdout, on a line of its own.
+func joinOutput(stdout, stderr *string) string {
+	var out, errOut string
+	if stdout != nil {
+		out = *stdout
+	}
+	if stderr != nil {
+		errOut = *stderr
+	}
+	if out != "" && errOut != "" && !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	return out + errOut
+}
+
+// tailLines returns the last n lines of r, reading backwards from the end in
+// chunks so only the tail is loaded. A final newline does not start a line.
+func tailLines(r io.ReadSeeker, n int) ([]byte, error) {
+	const chunk = 64 * 1024
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	var tail []byte
+	found := 0
+	for pos := size; pos > 0; {
+		step := min(chunk, pos)
+		pos -= step
+		block := make([]byte, step)
+		if _, err := r.Seek(pos, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r, block); err != nil {
+			return nil, err
+		}
+		for i := len(block) - 1; i >= 0; i-- {
+			if block[i] != '\n' || pos+int64(i) == size-1 {
+				continue
+			}
+			if found++; found == n {
+				return append(block[i+1:], tail...), nil
+			}
+		}
+		tail = append(block, tail...)
+	}
+	return tail, nil
+}
+
+// handleJobLogs streams a job's output over a WebSocket, like tail -f: the last
+// ?tail= lines (default 0) come first, then new output until the job ends. With
+// follow=false only those lines are sent. A finished job's lines are read from
+// its archive or stored output, followed by its result and close frame.
 func (r *router) handleJobLogs(w http.ResponseWriter, req *http.Request) {
 	id := req.PathValue("id")
 	if id == "" {
 		respondWithError(w, http.StatusBadRequest, "job id required")
 		return
 	}
+	follow := true
+	if v := req.URL.Query().Get("follow"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "follow must be true or false")
+			return
+		}
+		follow = b
+	}
+	lines := 0
+	if v := req.URL.Query().Get("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			respondWithError(w, http.StatusBadRequest, "tail must be a non-negative integer")
+			return
+		}
+		lines = min(n, maxTailLines)
+	}
+	job, ok := r.manager.Get(id)
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "not found")
+		return
+	}
 
 	conn, err := upgrader.Upgrade(w, req, nil)
 	if err != nil {
@@ -99,7 +632,38 @@ func (r *router) handleJobLogs(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	r.streamer.Subscribe(id, conn)
+	if !job.Status.Terminal() && !follow {
+		if tail := r.streamer.Tail(id, lines); len(tail) > 0 {
+			_ = conn.WriteMessage(websocket.TextMessage, tail)
+		}
+		msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+		_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		conn.Close()
+		return
+	}
+	if !job.Status.Terminal() {
+		err = r.streamer.SubscribeTail(id, conn, lines)
+		if errors.Is(err, jobs.ErrStreamEnded) {
+			// Finished since we looked; its final state is stored by now
+			job, _ = r.manager.Get(id)
+		}
+	}
+	if job.Status.Terminal() {
+		var tail []byte
+		if lines > 0 {
+			if tail, err = r.outputTail(job, lines); err != nil && !errors.Is(err, errNoOutput) {
+				slog.Warn("failed to read log tail", "job_id", id, "error", err)
+			}
+		}
+		jobs.SendFinished(conn, tail, job.Status, job.ExitCode)
+		return
+	}
+	if err != nil {
+		msg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, err.Error())
+		_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		conn.Close()
+		return
+	}
 	defer r.streamer.Unsubscribe(id, conn)
 
 	// Keep the connection open
@@ -110,3 +674,37 @@ func (r *router) handleJobLogs(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 }
+
+func (r *router) handleJobEvents(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+	if id == "" {
+		respondWithError(w, http.StatusBadRequest, "job id required")
+		return
+	}
+	if _, ok := r.manager.Get(id); !ok {
+		respondWithError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		slog.Error("failed to upgrade connection", "error", err)
+		return
+	}
+
+	if err := r.manager.SubscribeEvents(id, conn); err != nil {
+		msg := websocket.FormatCloseMessage(websocket.CloseInternalServerErr, err.Error())
+		_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		conn.Close()
+		return
+	}
+	defer r.manager.UnsubscribeEvents(id, conn)
+
+	// Keep the connection open until the stream ends or the client goes away
+	for {
+		if _, _, err := conn.NextReader(); err != nil {
+			conn.Close()
+			break
+		}
+	}
+}
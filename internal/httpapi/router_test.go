@@ -0,0 +1,587 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paulgrammer/childprocess/internal/buildinfo"
+	"github.com/paulgrammer/childprocess/internal/executor"
+	"github.com/paulgrammer/childprocess/internal/jobs"
+	"github.com/paulgrammer/childprocess/internal/webhook"
+)
+
+func newTestRouter(t *testing.T, opts ...RouterOption) http.Handler {
+	t.Helper()
+	streamer := jobs.NewLogStreamer()
+	manager, err := jobs.NewManager(1, jobs.NewInMemoryStore(), webhook.NewHTTPSender(time.Second, 0), executor.NewExecRunner(), streamer)
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(manager.Stop)
+	return NewRouter(manager, streamer, opts...)
+}
+
+func TestRouter_NoFrontendReturnsJSONNotFound(t *testing.T) {
+	h := newTestRouter(t, WithFrontendDir(filepath.Join(t.TempDir(), "missing")))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+	var body struct {
+		Error AppError `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected json body, got %q", rec.Body.String())
+	}
+	if body.Error.Code != CodeNotFound || body.Error.Message == "" {
+		t.Fatalf("expected NOT_FOUND error, got %+v", body.Error)
+	}
+}
+
+func TestRouter_FrontendDoesNotShadowAPI(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html></html>"), 0o644); err != nil {
+		t.Fatalf("write index: %v", err)
+	}
+	h := newTestRouter(t, WithFrontendDir(dir))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected frontend index, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("content-type") != "application/json" {
+		t.Fatalf("expected healthz json, got %d %q", rec.Code, rec.Header().Get("content-type"))
+	}
+}
+
+func TestRouter_AdminRoutesRequireKey(t *testing.T) {
+	h := newTestRouter(t, WithAdminAPIKey("secret"))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/queue", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without key, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/queue", nil)
+	req.Header.Set("X-API-Key", "secret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 with key, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/events?status=done", nil)
+	req.Header.Set("X-API-Key", "secret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown status filter, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	newTestRouter(t).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/queue", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected admin routes to be disabled without a key, got %d", rec.Code)
+	}
+}
+
+func TestRouter_Version(t *testing.T) {
+	h := newTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	var info buildinfo.Info
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("expected build info, got %d %s", rec.Code, rec.Body.String())
+	}
+	if info.Version != buildinfo.Version || info.GoVersion != runtime.Version() {
+		t.Fatalf("unexpected build info %+v", info)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if want := `childprocess_build_info{build_time="`; !strings.Contains(rec.Body.String(), want) {
+		t.Fatalf("expected %s in metrics", want)
+	}
+}
+
+func TestRouter_SubmitValidationErrors(t *testing.T) {
+	h := newTestRouter(t)
+
+	body := strings.NewReader(`{"command": "echo", "webhook_url": "not a url", "working_dir": "../etc"}`)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs", body))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Error struct {
+			Code    ErrorCode `json:"code"`
+			Details struct {
+				Fields map[string]string `json:"fields"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Error.Code != CodeInvalidRequest {
+		t.Fatalf("expected INVALID_REQUEST, got %q", resp.Error.Code)
+	}
+	for _, field := range []string{"webhook_url", "working_dir"} {
+		if resp.Error.Details.Fields[field] == "" {
+			t.Errorf("expected field error for %s, got %v", field, resp.Error.Details.Fields)
+		}
+	}
+}
+
+func TestRouter_SubmitWaitReturnsFinishedJob(t *testing.T) {
+	h := newTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/jobs?wait=true", strings.NewReader(`{"command":"echo","args":["hi"]}`))
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var job jobs.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatalf("expected a job body, got %q", rec.Body.String())
+	}
+	if job.Status != jobs.JobStatusCompleted || job.Stdout == nil || *job.Stdout != "hi\n" {
+		t.Fatalf("expected completed job with output, got %+v", job)
+	}
+}
+
+func TestRouter_StatsAggregatesJobs(t *testing.T) {
+	h := newTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs?wait=true", strings.NewReader(`{"command":"true"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("submit failed: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var counts map[string]float64
+	if err := json.Unmarshal(rec.Body.Bytes(), &counts); err != nil {
+		t.Fatalf("expected json body, got %q", rec.Body.String())
+	}
+	if counts["avg_duration_ms"] <= 0 {
+		t.Errorf("expected a positive avg_duration_ms, got %v", counts["avg_duration_ms"])
+	}
+	want := map[string]float64{"queued": 0, "in_progress": 0, "completed": 1, "failed": 0, "canceled": 0, "queue_depth": 0}
+	for status, n := range want {
+		if got, ok := counts[status]; !ok || got != n {
+			t.Errorf("%s = %v (present %v), want %v", status, got, ok, n)
+		}
+	}
+}
+
+func TestRouter_GetJobConditional(t *testing.T) {
+	h := newTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs?wait=true", strings.NewReader(`{"command":"true"}`)))
+	var job jobs.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatalf("submit failed: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID, nil))
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with an ETag, got %d %q", rec.Code, etag)
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Fatalf("Cache-Control = %q, want no-cache", cc)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID, nil)
+	req.Header.Set("If-None-Match", `"other", `+etag)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("expected empty 304, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID, nil)
+	req.Header.Set("If-None-Match", `W/"queued-1"`)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a stale ETag, got %d", rec.Code)
+	}
+}
+
+func TestRouter_CancelByTag(t *testing.T) {
+	h := newTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs/cancel", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a tag, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs/cancel?tag=deploy-123", nil))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"canceled":0}` {
+		t.Fatalf("expected 200 with no jobs canceled, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRouter_SubmitQueueFull(t *testing.T) {
+	streamer := jobs.NewLogStreamer()
+	manager, err := jobs.NewManager(1, jobs.NewInMemoryStore(), webhook.NewHTTPSender(time.Second, 0), executor.NewExecRunner(), streamer, jobs.WithQueueSize(1))
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(manager.Stop)
+	h := NewRouter(manager, streamer)
+	// Stop the sleepers so Stop does not wait on them
+	t.Cleanup(func() { _, _ = manager.CancelByTag(context.Background(), "queue-full") })
+
+	body := `{"command":"sleep","args":["5"],"tags":["queue-full"]}`
+	var last *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		last = httptest.NewRecorder()
+		h.ServeHTTP(last, httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(body)))
+		if i == 0 {
+			// Let the worker pick up the first job so the second one fills the queue
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+
+	if last.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d %s", last.Code, last.Body.String())
+	}
+	var resp struct {
+		Error AppError `json:"error"`
+	}
+	if err := json.Unmarshal(last.Body.Bytes(), &resp); err != nil || resp.Error.Code != CodeQueueFull {
+		t.Fatalf("expected QUEUE_FULL, got %s", last.Body.String())
+	}
+}
+
+func TestRouter_GetJobBatch(t *testing.T) {
+	h := newTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs?wait=true", strings.NewReader(`{"command":"true"}`)))
+	var job jobs.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatalf("decode job: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs?ids="+job.ID+",missing,"+job.ID, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	var batch jobBatch
+	if err := json.Unmarshal(rec.Body.Bytes(), &batch); err != nil {
+		t.Fatalf("decode batch: %v", err)
+	}
+	if len(batch.Jobs) != 1 || batch.Jobs[job.ID].Status != jobs.JobStatusCompleted {
+		t.Fatalf("expected the completed job, got %+v", batch.Jobs)
+	}
+	if len(batch.NotFound) != 1 || batch.NotFound[0] != "missing" {
+		t.Fatalf("expected missing to be not found, got %v", batch.NotFound)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without ids, got %d", rec.Code)
+	}
+
+	ids := make([]string, maxBatchIDs+1)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("job-%d", i)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs?ids="+strings.Join(ids, ","), nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 past the cap, got %d", rec.Code)
+	}
+}
+
+func TestRouter_GetJobReportsOutputSizes(t *testing.T) {
+	h := newTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs?wait=true", strings.NewReader(`{"command":"sh","args":["-c","printf hello; printf err >&2"]}`)))
+	var job jobs.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatalf("decode job: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID, nil))
+	var sizes struct {
+		StdoutBytes         int  `json:"stdout_bytes"`
+		StderrBytes         int  `json:"stderr_bytes"`
+		Truncated           bool `json:"truncated"`
+		StdoutCapturedBytes int  `json:"stdout_captured_bytes"`
+		StderrCapturedBytes int  `json:"stderr_captured_bytes"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &sizes); err != nil {
+		t.Fatalf("decode sizes: %v", err)
+	}
+	if sizes.StdoutBytes != 5 || sizes.StderrBytes != 3 || sizes.Truncated || sizes.StdoutCapturedBytes != 5 || sizes.StderrCapturedBytes != 3 {
+		t.Fatalf("got %+v, want 5 stdout bytes, 3 stderr bytes, all captured", sizes)
+	}
+}
+
+func TestRouter_GetJobReportsTruncatedOutputSizes(t *testing.T) {
+	streamer := jobs.NewLogStreamer()
+	runner := executor.NewExecRunner(executor.WithExecutorConfig(&executor.ExecutorConfig{CaptureOutput: true, MaxOutputSize: 100}))
+	manager, err := jobs.NewManager(1, jobs.NewInMemoryStore(), webhook.NewHTTPSender(time.Second, 0), runner, streamer)
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(manager.Stop)
+	h := NewRouter(manager, streamer)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs?wait=true", strings.NewReader(`{"command":"sh","args":["-c","head -c 5000 /dev/zero | tr '\\0' x"]}`)))
+	var job jobs.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatalf("decode job: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID, nil))
+	var got jobs.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode job: %v", err)
+	}
+	if got.Stdout == nil || !got.Truncated {
+		t.Fatalf("expected truncated stdout, got %+v", got)
+	}
+	// The total counts what the command wrote, the captured size what was kept
+	if got.StdoutBytes != 5000 {
+		t.Fatalf("stdout_bytes = %d, want 5000", got.StdoutBytes)
+	}
+	if got.StdoutCapturedBytes != len(*got.Stdout) || got.StdoutCapturedBytes >= 5000 || !strings.HasPrefix(*got.Stdout, strings.Repeat("x", 100)) {
+		t.Fatalf("stdout_captured_bytes = %d for %d stored bytes", got.StdoutCapturedBytes, len(*got.Stdout))
+	}
+}
+
+func TestRouter_GetJobReportsDuration(t *testing.T) {
+	h := newTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs?wait=true", strings.NewReader(`{"command":"sleep","args":["0.05"]}`)))
+	var job jobs.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatalf("decode job: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID, nil))
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode job: %v", err)
+	}
+	// A plain JSON number of milliseconds, not a Go duration string
+	ms, ok := body["duration_ms"].(float64)
+	if !ok || ms < 50 {
+		t.Fatalf("duration_ms = %#v, want a number of at least 50", body["duration_ms"])
+	}
+	if body["exit_code"] != float64(0) || body["completed_at"] == nil {
+		t.Fatalf("expected exit code and completion time, got %v", body)
+	}
+}
+
+func TestRouter_SubmitWaitTimesOutWithLocation(t *testing.T) {
+	h := newTestRouter(t, WithMaxWait(100*time.Millisecond))
+
+	// The requested timeout is capped by WithMaxWait
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs?wait=true&timeout=3600", strings.NewReader(`{"command":"sleep","args":["1"]}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var job jobs.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatalf("decode job: %v", err)
+	}
+	if job.Status.Terminal() {
+		t.Fatalf("expected the job to still be running, got %s", job.Status)
+	}
+	if got, want := rec.Header().Get("Location"), "/jobs/"+job.ID; got != want {
+		t.Fatalf("Location = %q, want %q", got, want)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs?wait=true&timeout=soon", strings.NewReader(`{"command":"echo"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad timeout, got %d", rec.Code)
+	}
+}
+
+func TestRouter_SubmitRejectsOversizedBody(t *testing.T) {
+	h := newTestRouter(t, WithMaxBodyBytes(64))
+
+	body := `{"command":"echo","metadata":{"blob":"` + strings.Repeat("x", 100) + `"}}`
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(body)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"PAYLOAD_TOO_LARGE"`) {
+		t.Fatalf("expected PAYLOAD_TOO_LARGE, got %s", rec.Body.String())
+	}
+
+	// Malformed JSON under the limit is still a 400
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(`{"command":`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestRouter_SubmitRejectsUnknownFields(t *testing.T) {
+	h := newTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(`{"commnd":"echo","args":["hi"]}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"INVALID_REQUEST"`) || !strings.Contains(rec.Body.String(), `"commnd":"unknown field"`) {
+		t.Fatalf("expected the unknown field to be named, got %s", rec.Body.String())
+	}
+
+	// Lenient routers ignore it, as before
+	h = newTestRouter(t, WithLenientJSON())
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(`{"command":"echo","extra":true}`)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRouter_SubmitWithCallerID(t *testing.T) {
+	h := newTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(`{"id":"upstream-1","command":"echo"}`)))
+	if rec.Code != http.StatusAccepted || !strings.Contains(rec.Body.String(), `"job_id":"upstream-1"`) {
+		t.Fatalf("expected the job queued under the given id, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(`{"id":"upstream-1","command":"echo"}`)))
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), `"CONFLICT"`) {
+		t.Fatalf("expected 409 for a taken id, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(`{"id":"../x","command":"echo"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unsafe id, got %d", rec.Code)
+	}
+}
+
+func TestRouter_SubmitRequestIDFitsMetadataLimits(t *testing.T) {
+	h := newTestRouter(t)
+
+	full := make(map[string]string, jobs.MaxMetadataEntries)
+	for i := range jobs.MaxMetadataEntries {
+		full[fmt.Sprintf("k%d", i)] = "v"
+	}
+	for name, tt := range map[string]struct {
+		metadata map[string]string
+		rid      string
+	}{
+		"full metadata": {full, "req-1"},
+		"long id":       {map[string]string{"k": "v"}, strings.Repeat("r", jobs.MaxMetadataValueBytes+1)},
+	} {
+		body, _ := json.Marshal(map[string]any{"command": "echo", "metadata": tt.metadata})
+		req := httptest.NewRequest(http.MethodPost, "/jobs?wait=true", strings.NewReader(string(body)))
+		req.Header.Set("X-Request-ID", tt.rid)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", name, rec.Code, rec.Body.String())
+		}
+		var job jobs.Job
+		if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+			t.Fatalf("%s: expected a job body, got %q", name, rec.Body.String())
+		}
+		if _, ok := job.Metadata["request_id"]; ok || len(job.Metadata) != len(tt.metadata) {
+			t.Fatalf("%s: expected the request id left out, got %d entries", name, len(job.Metadata))
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/jobs?wait=true", strings.NewReader(`{"command":"echo"}`))
+	req.Header.Set("X-Request-ID", "req-2")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), `"request_id":"req-2"`) {
+		t.Fatalf("expected the request id in the metadata, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRouter_DeleteJob(t *testing.T) {
+	h := newTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs?wait=true", strings.NewReader(`{"id":"done","command":"echo"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/jobs/done", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 for a finished job, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/done", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected the deleted job to be gone, got %d", rec.Code)
+	}
+
+	// A running job is canceled rather than deleted
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(`{"id":"busy","command":"sleep","args":["5"]}`)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/jobs/busy", nil))
+	if rec.Code != http.StatusAccepted || !strings.Contains(rec.Body.String(), `"id":"busy"`) {
+		t.Fatalf("expected 202 with the job for a running job, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/jobs/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown job, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Store backends selectable with NewStore.
+const (
+	BackendMemory = "memory"
+	BackendSQLite = "sqlite"
+)
+
+// StoreBackends lists every backend NewStore accepts.
+var StoreBackends = []string{BackendMemory, BackendSQLite}
+
+// ErrUnknownStoreBackend is returned by NewStore for a backend it does not know.
+var ErrUnknownStoreBackend = errors.New("unknown store backend")
+
+// StoreConfig selects the job store backend and carries its settings.
+type StoreConfig struct {
+	// Backend is one of StoreBackends; empty means BackendMemory
+	Backend string
+	// Path is the database file of BackendSQLite
+	Path string
+}
+
+// Validate checks that the backend is known and has the settings it needs,
+// without opening anything.
+func (cfg StoreConfig) Validate() error {
+	switch cfg.Backend {
+	case "", BackendMemory:
+		return nil
+	case BackendSQLite:
+		if cfg.Path == "" {
+			return errors.New("sqlite backend requires a path")
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w %q (want one of: %s)", ErrUnknownStoreBackend, cfg.Backend, strings.Join(StoreBackends, ", "))
+	}
+}
+
+// NewStore validates cfg and creates the store it selects.
+func NewStore(cfg StoreConfig) (Store, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Backend == BackendSQLite {
+		return NewSQLiteStore(cfg.Path)
+	}
+	return NewInMemoryStore(), nil
+}
@@ -0,0 +1,252 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrJobCanceled is the cause recorded when a job is canceled explicitly.
+var ErrJobCanceled = errors.New("job canceled")
+
+// ErrJobTimeout is the cause recorded when a job runs past its TimeoutSeconds.
+var ErrJobTimeout = errors.New("job timed out")
+
+// ErrClientDisconnected is the cause recorded when the client waiting on
+// SubmitAndWait goes away before the job finishes.
+var ErrClientDisconnected = errors.New("client disconnected")
+
+// jobRuns is the single place cancellation is funneled through. Every unfinished
+// job has a done channel; running jobs also have the cancel func of their context.
+//
+// A job's execution context is owned here, not by whoever submitted it:
+//   - track, on submit, makes the job cancelable and waitable;
+//   - start, once a worker picks the job up, creates the context, detached from
+//     the submitter and bounded by the job's timeout if it has one;
+//   - cancel ends it early with a cause, or before start arranges for it to
+//     start canceled;
+//   - finish, when the worker is done, releases the context and wakes waiters.
+type jobRuns struct {
+	mu      sync.Mutex
+	done    map[string]chan struct{}
+	cancels map[string]context.CancelCauseFunc
+	pending map[string]error // canceled after leaving the queue but before starting
+	// reserved counts jobs admitted by reserve that are not yet tracked
+	reserved int
+}
+
+func newJobRuns() *jobRuns {
+	return &jobRuns{
+		done:    make(map[string]chan struct{}),
+		cancels: make(map[string]context.CancelCauseFunc),
+		pending: make(map[string]error),
+	}
+}
+
+// reserve admits one more unfinished job unless limit, if positive, are
+// already reserved or tracked. Each reservation is either passed to track or
+// returned with unreserve.
+func (r *jobRuns) reserve(limit int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if limit > 0 && len(r.done)+r.reserved >= limit {
+		return false
+	}
+	r.reserved++
+	return true
+}
+
+func (r *jobRuns) unreserve() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reserved--
+}
+
+// track registers a job that has been accepted but not yet finished, taking
+// over its reservation.
+func (r *jobRuns) track(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reserved--
+	r.done[id] = make(chan struct{})
+}
+
+// start returns the job-scoped context the job executes under, derived from
+// parent for its values such as the trace. The context is detached from
+// whoever submitted the job; only cancel, or the timeout if it is positive,
+// ends it early. A timeout's cause wraps ErrJobTimeout.
+func (r *jobRuns) start(parent context.Context, id string, timeout time.Duration) context.Context {
+	ctx, cancel := context.WithCancelCause(context.WithoutCancel(parent))
+	if timeout > 0 {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%w after %s", ErrJobTimeout, timeout))
+		// Canceling the parent cancels ctx too; stop only releases the timer
+		parentCancel := cancel
+		cancel = func(cause error) {
+			parentCancel(cause)
+			stop()
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cancels[id] = cancel
+	if cause, ok := r.pending[id]; ok {
+		delete(r.pending, id)
+		cancel(cause)
+	}
+	return ctx
+}
+
+// cancel ends a running job's context with cause, or arranges for it to be
+// canceled on start. It reports false if the job is not tracked.
+func (r *jobRuns) cancel(id string, cause error) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.done[id]; !ok {
+		return false
+	}
+	if cancel, ok := r.cancels[id]; ok {
+		cancel(cause)
+	} else {
+		r.pending[id] = cause
+	}
+	return true
+}
+
+// finish releases the job's context and wakes anyone waiting on it.
+func (r *jobRuns) finish(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cancel, ok := r.cancels[id]; ok {
+		cancel(nil)
+		delete(r.cancels, id)
+	}
+	if done, ok := r.done[id]; ok {
+		close(done)
+		delete(r.done, id)
+	}
+	delete(r.pending, id)
+}
+
+// ids returns the jobs accepted but not yet finished.
+func (r *jobRuns) ids() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]string, 0, len(r.done))
+	for id := range r.done {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// wait returns a channel closed once the job has finished.
+func (r *jobRuns) wait(id string) <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if done, ok := r.done[id]; ok {
+		return done
+	}
+	closed := make(chan struct{})
+	close(closed)
+	return closed
+}
+
+// Cancel stops a queued or running job. Canceling a job that already finished is a no-op.
+func (m *Manager) Cancel(ctx context.Context, id string) error {
+	return m.cancel(ctx, id, ErrJobCanceled)
+}
+
+func (m *Manager) cancel(ctx context.Context, id string, cause error) error {
+	if m.tryCancel(ctx, id, cause) {
+		return nil
+	}
+	if _, ok := m.store.Get(id); !ok {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+// CancelByTag cancels every queued or running job carrying tag and returns how many it stopped.
+func (m *Manager) CancelByTag(ctx context.Context, tag string) (int, error) {
+	matches, err := m.store.ListByTag(tag)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, job := range matches {
+		if !job.Status.Terminal() && m.tryCancel(ctx, job.ID, ErrJobCanceled) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// tryCancel cancels the job if it has not finished and reports whether it did.
+func (m *Manager) tryCancel(ctx context.Context, id string, cause error) bool {
+	if m.queued.remove(id) {
+		// Never reached a worker, so record the outcome here
+		job, ok := m.transition(id, func(j *Job) {
+			now := time.Now().UTC()
+			j.Status = JobStatusCanceled
+			j.Error = cause.Error()
+			j.CompletedAt = &now
+		})
+		if ok {
+			m.notify(ctx, *job)
+			JobsCanceledTotal.Inc()
+		}
+		m.runs.finish(id)
+		return true
+	}
+	// execute records the outcome once the runner returns
+	return m.runs.cancel(id, cause)
+}
+
+// SubmitAndWait queues a job and blocks until it finishes or, if timeout is
+// positive, until timeout elapses, when the job is returned in its current,
+// unfinished state and keeps running. Unlike Submit, the job is tied to ctx: if
+// ctx ends first the job is canceled with ErrClientDisconnected and ctx's error
+// is returned along with the job's final state.
+func (m *Manager) SubmitAndWait(ctx context.Context, req CreateJobRequest, timeout time.Duration) (Job, error) {
+	id, err := m.submit(ctx, req, "")
+	if err != nil {
+		return Job{}, err
+	}
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	done := m.runs.wait(id)
+	select {
+	case <-done:
+	case <-expired:
+	case <-ctx.Done():
+		_ = m.cancel(context.WithoutCancel(ctx), id, ErrClientDisconnected)
+		<-done
+		job, _ := m.Get(id)
+		return job, ctx.Err()
+	}
+	job, _ := m.Get(id)
+	return job, nil
+}
+
+// Wait blocks until the job reaches a terminal status and returns it. It returns
+// at once for a job that has already finished. If ctx ends first, the job's
+// current state is returned with ctx's error; the job itself keeps running.
+func (m *Manager) Wait(ctx context.Context, id string) (Job, error) {
+	select {
+	case <-m.runs.wait(id):
+	case <-ctx.Done():
+		job, _ := m.Get(id)
+		return job, ctx.Err()
+	}
+	job, ok := m.Get(id)
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	return job, nil
+}
@@ -0,0 +1,312 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/paulgrammer/childprocess/internal/executor"
+)
+
+// ctxRunner holds every job until its context ends or release is closed.
+type ctxRunner struct {
+	started chan string
+	release chan struct{}
+}
+
+func newCtxRunner() *ctxRunner {
+	return &ctxRunner{started: make(chan string, 16), release: make(chan struct{})}
+}
+
+func (r *ctxRunner) Run(ctx context.Context, jobID string, command string, args []string, workingDir string, stdout, stderr io.Writer, opts ...executor.RunOption) (*executor.ExecutionResult, error) {
+	r.started <- jobID
+	select {
+	case <-ctx.Done():
+		return &executor.ExecutionResult{JobID: jobID, ExitCode: -1}, ctx.Err()
+	case <-r.release:
+		return &executor.ExecutionResult{JobID: jobID}, nil
+	}
+}
+
+func TestManager_AsyncSubmitSurvivesCallerCancel(t *testing.T) {
+	runner := newCtxRunner()
+	m := newTestManager(t, runner)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	id, err := m.Submit(ctx, CreateJobRequest{Command: "sleep"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	<-runner.started
+	cancel()
+
+	time.Sleep(20 * time.Millisecond)
+	if j, _ := m.Get(id); j.Status != JobStatusInProgress {
+		t.Fatalf("expected job to keep running after the caller went away, got %s", j.Status)
+	}
+	close(runner.release)
+	waitForStatus(t, m, id, JobStatusCompleted)
+}
+
+func TestManager_SubmitAndWaitCancelsOnDisconnect(t *testing.T) {
+	runner := newCtxRunner()
+	m := newTestManager(t, runner)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-runner.started
+		cancel()
+	}()
+	job, err := m.SubmitAndWait(ctx, CreateJobRequest{Command: "sleep"}, 0)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if job.Status != JobStatusCanceled || job.Error != ErrClientDisconnected.Error() {
+		t.Fatalf("expected job canceled by disconnect, got %s %q", job.Status, job.Error)
+	}
+}
+
+func TestManager_SubmitAndWaitReturnsFinishedJob(t *testing.T) {
+	m := newTestManager(t, fakeRunner{})
+	job, err := m.SubmitAndWait(context.Background(), CreateJobRequest{Command: "echo"}, time.Minute)
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	if job.Status != JobStatusCompleted || job.Stdout == nil || *job.Stdout != "ok\n" {
+		t.Fatalf("expected the finished job, got %+v", job)
+	}
+}
+
+func TestManager_SubmitAndWaitTimeoutLeavesJobRunning(t *testing.T) {
+	runner := newCtxRunner()
+	m := newTestManager(t, runner)
+
+	job, err := m.SubmitAndWait(context.Background(), CreateJobRequest{Command: "sleep"}, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("expected no error on timeout, got %v", err)
+	}
+	if job.Status.Terminal() {
+		t.Fatalf("expected an unfinished job, got %s", job.Status)
+	}
+	close(runner.release)
+	waitForStatus(t, m, job.ID, JobStatusCompleted)
+}
+
+func TestManager_CancelRunningAndQueued(t *testing.T) {
+	runner := newCtxRunner()
+	m := newTestManager(t, runner)
+	ctx := context.Background()
+
+	running, err := m.Submit(ctx, CreateJobRequest{Command: "sleep"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	<-runner.started
+	queued, err := m.Submit(ctx, CreateJobRequest{Command: "sleep"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+
+	if err := m.Cancel(ctx, queued); err != nil {
+		t.Fatalf("cancel queued failed: %v", err)
+	}
+	if j := waitForStatus(t, m, queued, JobStatusCanceled); j.Error != ErrJobCanceled.Error() {
+		t.Fatalf("unexpected error on canceled queued job: %q", j.Error)
+	}
+	if err := m.Cancel(ctx, running); err != nil {
+		t.Fatalf("cancel running failed: %v", err)
+	}
+	waitForStatus(t, m, running, JobStatusCanceled)
+
+	if err := m.Cancel(ctx, "missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected ErrJobNotFound, got %v", err)
+	}
+}
+
+func TestManager_CancelByTag(t *testing.T) {
+	runner := newCtxRunner()
+	m := newTestManager(t, runner)
+	ctx := context.Background()
+
+	submit := func(tags ...string) string {
+		t.Helper()
+		id, err := m.Submit(ctx, CreateJobRequest{Command: "sleep", Tags: tags})
+		if err != nil {
+			t.Fatalf("submit failed: %v", err)
+		}
+		return id
+	}
+	running := submit("deploy-1")
+	<-runner.started
+	queued := submit("team-a", "deploy-1")
+	other := submit("deploy-2")
+	untagged := submit()
+
+	n, err := m.CancelByTag(ctx, "deploy-1")
+	if err != nil {
+		t.Fatalf("cancel by tag failed: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("canceled %d jobs, want 2", n)
+	}
+	waitForStatus(t, m, running, JobStatusCanceled)
+	waitForStatus(t, m, queued, JobStatusCanceled)
+
+	close(runner.release)
+	waitForStatus(t, m, other, JobStatusCompleted)
+	waitForStatus(t, m, untagged, JobStatusCompleted)
+
+	// Finished jobs are not counted again
+	if n, _ := m.CancelByTag(ctx, "deploy-1"); n != 0 {
+		t.Fatalf("second cancel by tag reported %d jobs", n)
+	}
+}
+
+func TestManager_WaitNotifiesEveryWaiter(t *testing.T) {
+	runner := newCtxRunner()
+	m := newTestManager(t, runner)
+	ctx := context.Background()
+
+	id, err := m.Submit(ctx, CreateJobRequest{Command: "sleep"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	<-runner.started
+
+	const waiters = 5
+	results := make(chan Job, waiters)
+	for i := 0; i < waiters; i++ {
+		go func() {
+			job, err := m.Wait(ctx, id)
+			if err != nil {
+				t.Errorf("wait failed: %v", err)
+			}
+			results <- job
+		}()
+	}
+
+	// A waiter whose context ends gets the current state back
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if job, err := m.Wait(short, id); !errors.Is(err, context.DeadlineExceeded) || job.Status != JobStatusInProgress {
+		t.Fatalf("expected deadline with job in progress, got %v, %s", err, job.Status)
+	}
+
+	close(runner.release)
+	for i := 0; i < waiters; i++ {
+		select {
+		case job := <-results:
+			if job.Status != JobStatusCompleted {
+				t.Fatalf("waiter saw status %s, want completed", job.Status)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("only %d of %d waiters were notified", i, waiters)
+		}
+	}
+
+	// Already finished jobs return immediately
+	if job, err := m.Wait(ctx, id); err != nil || job.Status != JobStatusCompleted {
+		t.Fatalf("expected completed job without blocking, got %v, %s", err, job.Status)
+	}
+	if _, err := m.Wait(ctx, "missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected ErrJobNotFound, got %v", err)
+	}
+}
+
+func TestManager_TimeoutFailsRunningJob(t *testing.T) {
+	runner := newCtxRunner()
+	m := newTestManager(t, runner)
+
+	id, err := m.Submit(context.Background(), CreateJobRequest{Command: "sleep", TimeoutSeconds: 1})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	<-runner.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	job, err := m.Wait(ctx, id)
+	if err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	if job.Status != JobStatusFailed || job.Error != "job timed out after 1s" || job.CompletedAt == nil {
+		t.Fatalf("expected the job to fail on its timeout, got status %q error %q", job.Status, job.Error)
+	}
+}
+
+func TestManager_EffectiveTimeout(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []ManagerOption
+		requested int
+		want      int
+	}{
+		{"none", nil, 0, 0},
+		{"default applied", []ManagerOption{WithDefaultTimeout(30 * time.Second)}, 0, 30},
+		{"client override", []ManagerOption{WithDefaultTimeout(30 * time.Second)}, 5, 5},
+		{"client override above default", []ManagerOption{WithDefaultTimeout(30 * time.Second), WithMaxTimeout(time.Minute)}, 45, 45},
+		{"clamped to ceiling", []ManagerOption{WithMaxTimeout(time.Minute)}, 600, 60},
+		{"ceiling without default", []ManagerOption{WithMaxTimeout(time.Minute)}, 0, 60},
+		{"default clamped to ceiling", []ManagerOption{WithDefaultTimeout(time.Hour), WithMaxTimeout(time.Minute)}, 0, 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewManager(1, NewInMemoryStore(), nopSender{}, fakeRunner{}, NewLogStreamer(), tt.opts...)
+			if err != nil {
+				t.Fatalf("failed to create manager: %v", err)
+			}
+			defer m.Stop()
+
+			id, err := m.Submit(context.Background(), CreateJobRequest{Command: "echo", TimeoutSeconds: tt.requested})
+			if err != nil {
+				t.Fatalf("submit failed: %v", err)
+			}
+			job, ok := m.Get(id)
+			if !ok {
+				t.Fatalf("job %s not found", id)
+			}
+			if job.EffectiveTimeoutSeconds != tt.want || job.TimeoutSeconds != tt.requested {
+				t.Fatalf("expected effective timeout %d with requested %d kept, got %d and %d", tt.want, tt.requested, job.EffectiveTimeoutSeconds, job.TimeoutSeconds)
+			}
+		})
+	}
+}
+
+func TestManager_DefaultTimeoutFailsRunningJob(t *testing.T) {
+	runner := newCtxRunner()
+	m, err := NewManager(1, NewInMemoryStore(), nopSender{}, runner, NewLogStreamer(), WithDefaultTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	defer m.Stop()
+
+	id, err := m.Submit(context.Background(), CreateJobRequest{Command: "sleep"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	<-runner.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	job, err := m.Wait(ctx, id)
+	if err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	if job.Status != JobStatusFailed || job.Error != "job timed out after 1s" {
+		t.Fatalf("expected the job to fail on the default timeout, got status %q error %q", job.Status, job.Error)
+	}
+}
+
+func TestJobRuns_CancelBeatsTimeout(t *testing.T) {
+	r := newJobRuns()
+	r.track("job")
+	ctx := r.start(context.Background(), "job", time.Hour)
+	r.cancel("job", ErrJobCanceled)
+	<-ctx.Done()
+	if cause := context.Cause(ctx); !errors.Is(cause, ErrJobCanceled) {
+		t.Fatalf("cause = %v, want ErrJobCanceled", cause)
+	}
+	r.finish("job")
+}
@@ -0,0 +1,161 @@
+package jobs
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/paulgrammer/childprocess/internal/webhook"
+)
+
+// eventBus fans job status events out to local subscribers, alongside the
+// webhook: per-job streams and firehose subscribers that see every job. It
+// reuses the buffered subscription writer of LogStreamer, so a slow reader never
+// holds up the manager.
+type eventBus struct {
+	mu          sync.Mutex
+	subscribers map[string][]*subscription
+	firehose    []*firehoseSubscription
+	bufferSize  int
+}
+
+// firehoseSubscription receives the events of every job whose status is in
+// statuses, or of every job if statuses is empty.
+type firehoseSubscription struct {
+	*subscription
+	statuses map[JobStatus]bool
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		subscribers: make(map[string][]*subscription),
+		bufferSize:  defaultSubscriberBuffer,
+	}
+}
+
+// eventsCloseFrame ends an event stream once the terminal event is written.
+var eventsCloseFrame = websocket.FormatCloseMessage(websocket.CloseNormalClosure, "job finished")
+
+// subscribe adds conn to the job's event stream. Events are published after the
+// store is updated and under the same lock, so a job current reports as terminal
+// has no events left to publish; conn then gets the final one at once.
+func (h *eventBus) subscribe(jobID string, conn LogSubscriber, current func() (Job, bool)) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	job, ok := current()
+	if !ok {
+		return ErrJobNotFound
+	}
+	s := newSubscription(conn, h.bufferSize, defaultWriteTimeout)
+	if job.Status.Terminal() {
+		s.send(encodeEvent(jobEvent(job)))
+		s.finish(eventsCloseFrame)
+		return nil
+	}
+	h.subscribers[jobID] = append(h.subscribers[jobID], s)
+	return nil
+}
+
+func (h *eventBus) unsubscribe(jobID string, conn LogSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	subscribers := h.subscribers[jobID]
+	for i, s := range subscribers {
+		if s.conn == conn {
+			s.finish(nil)
+			h.subscribers[jobID] = append(subscribers[:i], subscribers[i+1:]...)
+			break
+		}
+	}
+	if len(h.subscribers[jobID]) == 0 {
+		delete(h.subscribers, jobID)
+	}
+}
+
+func (h *eventBus) subscribeAll(conn LogSubscriber, statuses []JobStatus) {
+	f := &firehoseSubscription{subscription: newSubscription(conn, h.bufferSize, defaultWriteTimeout)}
+	if len(statuses) > 0 {
+		f.statuses = make(map[JobStatus]bool, len(statuses))
+		for _, s := range statuses {
+			f.statuses[s] = true
+		}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.firehose = append(h.firehose, f)
+}
+
+func (h *eventBus) unsubscribeAll(conn LogSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, f := range h.firehose {
+		if f.conn == conn {
+			f.finish(nil)
+			h.firehose = append(h.firehose[:i], h.firehose[i+1:]...)
+			break
+		}
+	}
+}
+
+// publish queues event for the job's subscribers, ending their streams after a
+// terminal event, and for the firehose. Firehose subscribers that fall behind
+// miss events rather than being disconnected.
+func (h *eventBus) publish(event webhook.Event, terminal bool) {
+	msg := encodeEvent(event)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, f := range h.firehose {
+		if f.statuses != nil && !f.statuses[JobStatus(event.Status)] {
+			continue
+		}
+		if !f.trySend(msg) {
+			EventsDroppedTotal.Inc()
+		}
+	}
+	for _, s := range h.subscribers[event.JobID] {
+		if s.send(msg) {
+			slog.Warn("disconnected slow event subscriber", "job_id", event.JobID)
+		}
+		if terminal {
+			s.finish(eventsCloseFrame)
+		}
+	}
+	if terminal {
+		delete(h.subscribers, event.JobID)
+	}
+}
+
+func encodeEvent(event webhook.Event) []byte {
+	msg, err := json.Marshal(event)
+	if err != nil {
+		slog.Warn("failed to encode job event", "job_id", event.JobID, "error", err)
+	}
+	return msg
+}
+
+// SubscribeEvents streams the job's status events to conn as JSON, in the same
+// shape as webhook events, and closes it after the terminal event. A job that
+// has already finished gets its final event straight away. The subscription
+// ends early on UnsubscribeEvents.
+func (m *Manager) SubscribeEvents(jobID string, conn LogSubscriber) error {
+	return m.events.subscribe(jobID, conn, func() (Job, bool) { return m.Get(jobID) })
+}
+
+// UnsubscribeEvents removes conn from the job's event stream.
+func (m *Manager) UnsubscribeEvents(jobID string, conn LogSubscriber) {
+	m.events.unsubscribe(jobID, conn)
+}
+
+// SubscribeAllEvents streams the status events of every job to conn, limited to
+// the given statuses if any are given. The stream stays open until
+// UnsubscribeAllEvents; events that conn is too slow to take are dropped and
+// counted in EventsDroppedTotal.
+func (m *Manager) SubscribeAllEvents(conn LogSubscriber, statuses ...JobStatus) {
+	m.events.subscribeAll(conn, statuses)
+}
+
+// UnsubscribeAllEvents removes conn from the stream of every job's events.
+func (m *Manager) UnsubscribeAllEvents(conn LogSubscriber) {
+	m.events.unsubscribeAll(conn)
+}
@@ -0,0 +1,133 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// eventStatuses waits for sub to be closed and returns the statuses it received.
+func eventStatuses(t *testing.T, sub *recordingSubscriber) []string {
+	t.Helper()
+	select {
+	case <-sub.closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("event stream was not closed")
+	}
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	var statuses []string
+	for _, msg := range sub.msgs {
+		var event struct {
+			JobID  string `json:"job_id"`
+			Status string `json:"status"`
+		}
+		if err := json.Unmarshal([]byte(msg), &event); err != nil {
+			t.Fatalf("event is not JSON: %q", msg)
+		}
+		statuses = append(statuses, event.Status)
+	}
+	return statuses
+}
+
+func TestManager_SubscribeEventsStreamsTransitions(t *testing.T) {
+	runner := newCtxRunner()
+	m := newTestManager(t, runner)
+	ctx := context.Background()
+
+	// Occupy the only worker so the second job stays queued while we subscribe
+	if _, err := m.Submit(ctx, CreateJobRequest{Command: "sleep"}); err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	<-runner.started
+	id, err := m.Submit(ctx, CreateJobRequest{Command: "true"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+
+	sub := &recordingSubscriber{got: make(chan struct{}, 16), closed: make(chan struct{})}
+	if err := m.SubscribeEvents(id, sub); err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+	close(runner.release)
+
+	got := eventStatuses(t, sub)
+	if len(got) != 2 || got[0] != string(JobStatusInProgress) || got[1] != string(JobStatusCompleted) {
+		t.Fatalf("expected in_progress then completed, got %v", got)
+	}
+
+	// A finished job gets its final event straight away
+	late := &recordingSubscriber{got: make(chan struct{}, 16), closed: make(chan struct{})}
+	if err := m.SubscribeEvents(id, late); err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+	if got := eventStatuses(t, late); len(got) != 1 || got[0] != string(JobStatusCompleted) {
+		t.Fatalf("expected only completed, got %v", got)
+	}
+
+	if err := m.SubscribeEvents("missing", late); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected ErrJobNotFound, got %v", err)
+	}
+}
+
+func TestManager_SubscribeAllEventsFiltersByStatus(t *testing.T) {
+	runner := newCtxRunner()
+	close(runner.release)
+	m := newTestManager(t, runner)
+
+	sub := &recordingSubscriber{got: make(chan struct{}, 16), closed: make(chan struct{})}
+	m.SubscribeAllEvents(sub, JobStatusCompleted)
+	defer m.UnsubscribeAllEvents(sub)
+
+	for i := 0; i < 2; i++ {
+		if _, err := m.Submit(context.Background(), CreateJobRequest{Command: "true"}); err != nil {
+			t.Fatalf("submit failed: %v", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-sub.got:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("got %d of 2 completed events", i)
+		}
+	}
+
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	for _, msg := range sub.msgs {
+		var event struct {
+			Status string `json:"status"`
+		}
+		if err := json.Unmarshal([]byte(msg), &event); err != nil || event.Status != string(JobStatusCompleted) {
+			t.Fatalf("expected only completed events, got %q", msg)
+		}
+	}
+}
+
+func TestManager_SlowFirehoseSubscriberDropsEvents(t *testing.T) {
+	runner := newCtxRunner()
+	close(runner.release)
+	m := newTestManager(t, runner)
+	m.events.bufferSize = 1
+
+	slow := &slowSubscriber{closed: make(chan struct{})}
+	m.SubscribeAllEvents(slow)
+	defer m.UnsubscribeAllEvents(slow)
+
+	before := testutil.ToFloat64(EventsDroppedTotal)
+	id, err := m.Submit(context.Background(), CreateJobRequest{Command: "true"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	if _, err := m.Wait(context.Background(), id); err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	// queued is being written and in_progress is buffered, so completed has no room
+	if dropped := testutil.ToFloat64(EventsDroppedTotal) - before; dropped < 1 {
+		t.Fatalf("expected dropped events to be counted, got %v", dropped)
+	}
+}
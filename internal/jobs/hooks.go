@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// PreExecHook checks a job just before it runs, with its command, args and
+// other settings fully resolved. Returning an error rejects the job: it is
+// marked failed with the error as its reason and never reaches the runner.
+type PreExecHook func(ctx context.Context, job *Job) error
+
+// WithPreExecHook adds hooks that every job must pass before it runs. Hooks
+// run in the order they were added and the first error stops the chain.
+func WithPreExecHook(hooks ...PreExecHook) ManagerOption {
+	return func(m *Manager) {
+		m.preExecHooks = append(m.preExecHooks, hooks...)
+	}
+}
+
+// rejectByHooks runs the pre-exec hooks against the job and, if one refuses
+// it, fails the job and reports true so it is not run.
+func (m *Manager) rejectByHooks(ctx context.Context, id string) bool {
+	if len(m.preExecHooks) == 0 {
+		return false
+	}
+	job, ok := m.store.Get(id)
+	if !ok {
+		return false
+	}
+	var reason error
+	for _, hook := range m.preExecHooks {
+		if reason = hook(ctx, job); reason != nil {
+			break
+		}
+	}
+	if reason == nil {
+		return false
+	}
+	rejected, ok := m.transition(id, func(j *Job) {
+		now := time.Now().UTC()
+		j.Status = JobStatusFailed
+		j.Error = reason.Error()
+		j.CompletedAt = &now
+	})
+	if !ok {
+		// Canceled or otherwise finished while the hooks ran
+		return true
+	}
+	slog.Warn("job rejected by pre-exec hook, not running it", "job_id", id, "error", reason)
+	m.notify(ctx, *rejected)
+	JobsFailedTotal.Inc()
+	return true
+}
@@ -0,0 +1,57 @@
+package jobs
+
+import "bytes"
+
+// maxBacklogBytes bounds the output kept per job for SubscribeTail, whatever
+// the line limit, so a job printing very long lines cannot hold much memory.
+const maxBacklogBytes = 1024 * 1024
+
+// logBacklog is the tail of a running job's streamed output, split into lines.
+type logBacklog struct {
+	lines   [][]byte // complete lines, oldest first, each ending in '\n'
+	partial []byte   // output after the last newline
+	bytes   int
+}
+
+// write appends msg, then drops the oldest lines beyond maxLines or
+// maxBacklogBytes. msg is copied, never retained.
+func (b *logBacklog) write(msg []byte, maxLines int) {
+	for len(msg) > 0 {
+		i := bytes.IndexByte(msg, '\n')
+		if i < 0 {
+			b.partial = append(b.partial, msg...)
+			b.bytes += len(msg)
+			break
+		}
+		b.lines = append(b.lines, append(b.partial, msg[:i+1]...))
+		b.partial = nil
+		b.bytes += i + 1
+		msg = msg[i+1:]
+	}
+	for len(b.lines) > 0 && (len(b.lines) > maxLines || b.bytes > maxBacklogBytes) {
+		b.bytes -= len(b.lines[0])
+		b.lines[0] = nil
+		b.lines = b.lines[1:]
+	}
+	// A single unfinished line over the limit keeps only its end
+	if len(b.partial) > maxBacklogBytes {
+		b.partial = append([]byte(nil), b.partial[len(b.partial)-maxBacklogBytes:]...)
+		b.bytes = len(b.partial)
+	}
+}
+
+// tail returns a copy of the last n lines, counting an unfinished line as one.
+func (b *logBacklog) tail(n int) []byte {
+	if n <= 0 {
+		return nil
+	}
+	if len(b.partial) > 0 {
+		n--
+	}
+	lines := b.lines[max(len(b.lines)-n, 0):]
+	out := make([]byte, 0, b.bytes)
+	for _, line := range lines {
+		out = append(out, line...)
+	}
+	return append(out, b.partial...)
+}
@@ -0,0 +1,111 @@
+package jobs
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// LogSink persists a job's log stream alongside the live fan-out
+type LogSink interface {
+	// Write appends p to the job's log
+	Write(jobID string, p []byte) error
+	// Close flushes and releases the job's log
+	Close(jobID string) error
+	// Open returns a reader over the job's persisted log
+	Open(jobID string) (io.ReadCloser, error)
+	// Remove deletes the job's persisted log; a log that does not exist is not an error
+	Remove(jobID string) error
+}
+
+// FileLogSink writes each job's log to {dir}/{jobID}.log
+type FileLogSink struct {
+	dir   string
+	mu    sync.Mutex
+	files map[string]*logFile
+}
+
+type logFile struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+// NewFileLogSink creates a FileLogSink rooted at dir, creating it if needed
+func NewFileLogSink(dir string) (*FileLogSink, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create log dir: %w", err)
+	}
+	return &FileLogSink{dir: dir, files: make(map[string]*logFile)}, nil
+}
+
+func (s *FileLogSink) path(jobID string) (string, error) {
+	if jobID == "" || jobID != filepath.Base(jobID) || strings.Contains(jobID, "..") {
+		return "", fmt.Errorf("invalid job id %q", jobID)
+	}
+	return filepath.Join(s.dir, jobID+".log"), nil
+}
+
+// Write appends p to the job's log file, opening it on first use
+func (s *FileLogSink) Write(jobID string, p []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lf, ok := s.files[jobID]
+	if !ok {
+		path, err := s.path(jobID)
+		if err != nil {
+			return err
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %w", err)
+		}
+		lf = &logFile{f: f, w: bufio.NewWriter(f)}
+		s.files[jobID] = lf
+	}
+	_, err := lf.w.Write(p)
+	return err
+}
+
+// Close flushes the job's buffered output and closes its file
+func (s *FileLogSink) Close(jobID string) error {
+	s.mu.Lock()
+	lf, ok := s.files[jobID]
+	delete(s.files, jobID)
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return errors.Join(lf.w.Flush(), lf.f.Close())
+}
+
+// Remove closes the job's log file if it is still open and deletes it
+func (s *FileLogSink) Remove(jobID string) error {
+	path, err := s.path(jobID)
+	if err != nil {
+		return err
+	}
+	closeErr := s.Close(jobID)
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return closeErr
+}
+
+// Open returns the job's persisted log, flushing any buffered output first
+func (s *FileLogSink) Open(jobID string) (io.ReadCloser, error) {
+	path, err := s.path(jobID)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	if lf, ok := s.files[jobID]; ok {
+		_ = lf.w.Flush()
+	}
+	s.mu.Unlock()
+	return os.Open(path)
+}
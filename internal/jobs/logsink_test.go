@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"io"
+	"testing"
+)
+
+func TestLogStreamer_ArchiveContainsBroadcasts(t *testing.T) {
+	sink, err := NewFileLogSink(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	ls := NewLogStreamer(WithLogSink(sink))
+
+	ls.Broadcast("job-1", []byte("line one\n"))
+	ls.Broadcast("job-1", []byte("line two\n"))
+	ls.Broadcast("job-2", []byte("other job\n"))
+	ls.Close("job-1")
+
+	r, err := ls.Archive("job-1")
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer r.Close()
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	if want := "line one\nline two\n"; string(got) != want {
+		t.Fatalf("archive = %q, want %q", got, want)
+	}
+
+	if _, err := ls.Archive("../job-1"); err == nil {
+		t.Fatalf("expected traversal job id to be rejected")
+	}
+}
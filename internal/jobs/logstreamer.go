@@ -1,63 +1,530 @@
 package jobs
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// LogSubscriber receives a job's log messages; *websocket.Conn satisfies it
+type LogSubscriber interface {
+	WriteMessage(messageType int, data []byte) error
+	Close() error
+}
+
+// controlWriter is implemented by subscribers that can send WebSocket control
+// frames, such as *websocket.Conn; they get a close frame describing the outcome.
+type controlWriter interface {
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+}
+
+// writeDeadliner is implemented by subscribers whose writes can be given a
+// deadline, such as *websocket.Conn.
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// defaultWriteTimeout bounds each write to a subscriber, so a connection that
+// died without the TCP stack noticing yet is dropped instead of holding its
+// writer forever.
+const defaultWriteTimeout = 5 * time.Second
+
+// closeFrameTimeout bounds how long sending the final close frame may take.
+const closeFrameTimeout = time.Second
+
+// defaultSubscriberBuffer is how many messages may queue for one subscriber before it is dropped
+const defaultSubscriberBuffer = 256
+
+// defaultBatchBytes flushes a batch early once it holds this much output.
+const defaultBatchBytes = 64 * 1024
+
+// endedRetention is how long SubscribeTail remembers that a job's stream ended,
+// so a client racing the end of a job is told instead of waiting forever.
+const endedRetention = time.Minute
+
 // LogStreamer manages log subscribers for jobs
 type LogStreamer struct {
 	mu          sync.RWMutex
-	subscribers map[string][]*websocket.Conn
+	subscribers map[string][]*subscription
+	sink        LogSink
+	bufferSize  int
+	maxPerJob   int
+	// writeTimeout bounds each write to a subscriber; 0 means none
+	writeTimeout time.Duration
+
+	// Batching of broadcasts; batchMu is taken before mu, never after
+	batchInterval time.Duration
+	batchBytes    int
+	batchMu       sync.Mutex
+	batches       map[string]*logBatch
+
+	// Recent output of running jobs for SubscribeTail, taken while holding mu
+	backlogLines int
+	backlogMu    sync.Mutex
+	backlogs     map[string]*logBacklog
+	// ended holds jobs whose stream closed within endedRetention, guarded by mu
+	ended map[string]struct{}
+}
+
+// logBatch is the output broadcast for a job since its last flush.
+type logBatch struct {
+	buf   []byte
+	timer *time.Timer
+}
+
+type LogStreamerOption func(*LogStreamer)
+
+// WithLogSink persists every broadcast message to sink
+func WithLogSink(sink LogSink) LogStreamerOption {
+	return func(ls *LogStreamer) {
+		ls.sink = sink
+	}
+}
+
+// WithSubscriberBuffer sets how many messages may be pending for a single subscriber.
+// A subscriber that falls further behind is disconnected.
+func WithSubscriberBuffer(n int) LogStreamerOption {
+	return func(ls *LogStreamer) {
+		if n > 0 {
+			ls.bufferSize = n
+		}
+	}
+}
+
+// WithWriteTimeout sets the deadline of each write to a subscriber that
+// supports one, 5s by default; 0 disables it. A write that misses it counts as
+// a failed connection, which is pruned like any other.
+func WithWriteTimeout(d time.Duration) LogStreamerOption {
+	return func(ls *LogStreamer) {
+		ls.writeTimeout = d
+	}
+}
+
+// WithMaxSubscribersPerJob caps how many subscribers one job's stream may have; 0 means no limit.
+func WithMaxSubscribersPerJob(n int) LogStreamerOption {
+	return func(ls *LogStreamer) {
+		ls.maxPerJob = n
+	}
+}
+
+// WithBatching sends subscribers a job's output at most every interval, as one
+// message, or sooner once maxBytes are pending (64 KiB if maxBytes is 0). It
+// trades a little latency for far fewer writes on chatty jobs. Zero interval,
+// the default, sends every write as it comes. The log sink is not batched.
+func WithBatching(interval time.Duration, maxBytes int) LogStreamerOption {
+	return func(ls *LogStreamer) {
+		ls.batchInterval = interval
+		ls.batchBytes = maxBytes
+		if ls.batchBytes <= 0 {
+			ls.batchBytes = defaultBatchBytes
+		}
+	}
+}
+
+// WithBacklog keeps the last lines of each running job's output, at most 1 MiB
+// of it, for SubscribeTail and Tail; 0, the default, keeps none.
+func WithBacklog(lines int) LogStreamerOption {
+	return func(ls *LogStreamer) {
+		ls.backlogLines = lines
+	}
 }
 
 // NewLogStreamer creates a new LogStreamer
-func NewLogStreamer() *LogStreamer {
-	return &LogStreamer{
-		subscribers: make(map[string][]*websocket.Conn),
+func NewLogStreamer(opts ...LogStreamerOption) *LogStreamer {
+	ls := &LogStreamer{
+		subscribers:  make(map[string][]*subscription),
+		bufferSize:   defaultSubscriberBuffer,
+		writeTimeout: defaultWriteTimeout,
+		batches:      make(map[string]*logBatch),
+		backlogs:     make(map[string]*logBacklog),
+		ended:        make(map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(ls)
 	}
+	return ls
+}
+
+// subscription owns the writer goroutine of one subscriber, so a slow reader
+// never blocks Broadcast or the other subscribers.
+type subscription struct {
+	conn       LogSubscriber
+	msgs       chan []byte
+	closeOnce  sync.Once
+	closeFrame []byte // sent after the last message, if set
+	dead       atomic.Bool
+	timeout    time.Duration
 }
 
+func newSubscription(conn LogSubscriber, size int, timeout time.Duration) *subscription {
+	s := &subscription{conn: conn, msgs: make(chan []byte, size), timeout: timeout}
+	go s.run()
+	return s
+}
+
+// run writes queued messages until the queue is closed, then closes the connection.
+func (s *subscription) run() {
+	defer s.conn.Close()
+	for msg := range s.msgs {
+		if err := s.write(msg); err != nil {
+			s.dead.Store(true)
+			return
+		}
+	}
+	if cw, ok := s.conn.(controlWriter); ok && s.closeFrame != nil {
+		_ = cw.WriteControl(websocket.CloseMessage, s.closeFrame, time.Now().Add(closeFrameTimeout))
+	}
+}
+
+// write sends msg within the subscription's timeout, if the connection takes a deadline.
+func (s *subscription) write(msg []byte) error {
+	if d, ok := s.conn.(writeDeadliner); ok && s.timeout > 0 {
+		if err := d.SetWriteDeadline(time.Now().Add(s.timeout)); err != nil {
+			return err
+		}
+	}
+	return s.conn.WriteMessage(websocket.TextMessage, msg)
+}
+
+// send queues msg without blocking. If the buffer is full the subscriber is
+// disconnected, and send reports true on the call that disconnected it.
+func (s *subscription) send(msg []byte) (dropped bool) {
+	if s.dead.Load() {
+		return false
+	}
+	select {
+	case s.msgs <- msg:
+		return false
+	default:
+		if !s.dead.CompareAndSwap(false, true) {
+			return false
+		}
+		// Closing the connection unblocks a writer stuck on it
+		s.conn.Close()
+		return true
+	}
+}
+
+// trySend queues msg if there is room and reports whether it did. Unlike send
+// it never disconnects the subscriber.
+func (s *subscription) trySend(msg []byte) bool {
+	if s.dead.Load() {
+		return false
+	}
+	select {
+	case s.msgs <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// finish lets the writer flush what is queued, send closeFrame if non-nil and
+// then close the connection. Callers must hold the streamer's write lock so no
+// send is in flight.
+func (s *subscription) finish(closeFrame []byte) {
+	s.closeOnce.Do(func() {
+		s.closeFrame = closeFrame
+		close(s.msgs)
+	})
+}
+
+// ErrNoLogSink is returned by Archive when no LogSink is configured
+var ErrNoLogSink = errors.New("log persistence not configured")
+
+// Archive returns the persisted log for a job
+func (ls *LogStreamer) Archive(jobID string) (io.ReadCloser, error) {
+	if ls.sink == nil {
+		return nil, ErrNoLogSink
+	}
+	return ls.sink.Open(jobID)
+}
+
+// Remove drops what the streamer holds for a finished job: its backlog and its
+// persisted log, if there is a LogSink.
+func (ls *LogStreamer) Remove(jobID string) error {
+	ls.backlogMu.Lock()
+	delete(ls.backlogs, jobID)
+	ls.backlogMu.Unlock()
+	if ls.sink == nil {
+		return nil
+	}
+	return ls.sink.Remove(jobID)
+}
+
+// ErrTooManySubscribers is returned by Subscribe when a job's stream is at its subscriber limit
+var ErrTooManySubscribers = errors.New("too many log subscribers for job")
+
+// ErrStreamEnded is returned by SubscribeTail when the job's stream has already closed
+var ErrStreamEnded = errors.New("log stream has ended")
+
 // Subscribe adds a new subscriber to a job's log stream
-func (ls *LogStreamer) Subscribe(jobID string, conn *websocket.Conn) {
+func (ls *LogStreamer) Subscribe(jobID string, conn LogSubscriber) error {
 	ls.mu.Lock()
 	defer ls.mu.Unlock()
-	ls.subscribers[jobID] = append(ls.subscribers[jobID], conn)
+	return ls.subscribe(jobID, conn, nil)
+}
+
+// SubscribeTail subscribes conn like Subscribe, first sending it the last lines
+// of the job's output kept by WithBacklog, as one message. Output is never
+// repeated or missed between the two. It returns ErrStreamEnded if the job's
+// stream has closed recently; older streams are not tracked.
+func (ls *LogStreamer) SubscribeTail(jobID string, conn LogSubscriber, lines int) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if _, ok := ls.ended[jobID]; ok {
+		return ErrStreamEnded
+	}
+	return ls.subscribe(jobID, conn, ls.tail(jobID, lines))
+}
+
+// subscribe adds conn with first, if any, queued ahead of the job's output.
+// Callers must hold the write lock.
+func (ls *LogStreamer) subscribe(jobID string, conn LogSubscriber, first []byte) error {
+	// Dead connections would otherwise hold slots until their handler unsubscribes
+	ls.pruneDead(jobID)
+	if ls.maxPerJob > 0 && len(ls.subscribers[jobID]) >= ls.maxPerJob {
+		return ErrTooManySubscribers
+	}
+	s := newSubscription(conn, ls.bufferSize, ls.writeTimeout)
+	if len(first) > 0 {
+		s.msgs <- first
+	}
+	ls.subscribers[jobID] = append(ls.subscribers[jobID], s)
+	LogSubscribers.Inc()
+	return nil
+}
+
+// Tail returns the last lines of a running job's output kept by WithBacklog.
+func (ls *LogStreamer) Tail(jobID string, lines int) []byte {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+	return ls.tail(jobID, lines)
+}
+
+func (ls *LogStreamer) tail(jobID string, lines int) []byte {
+	ls.backlogMu.Lock()
+	defer ls.backlogMu.Unlock()
+	if b := ls.backlogs[jobID]; b != nil {
+		return b.tail(lines)
+	}
+	return nil
 }
 
 // Unsubscribe removes a subscriber from a job's log stream
-func (ls *LogStreamer) Unsubscribe(jobID string, conn *websocket.Conn) {
+func (ls *LogStreamer) Unsubscribe(jobID string, conn LogSubscriber) {
 	ls.mu.Lock()
 	defer ls.mu.Unlock()
 	subscribers := ls.subscribers[jobID]
 	for i, s := range subscribers {
-		if s == conn {
+		if s.conn == conn {
+			s.finish(nil)
 			ls.subscribers[jobID] = append(subscribers[:i], subscribers[i+1:]...)
+			LogSubscribers.Dec()
 			break
 		}
 	}
 }
 
-// Broadcast sends a log message to all subscribers of a job
+// Broadcast queues a log message for all subscribers of a job without waiting
+// for them to write it. Subscribers whose buffer is full are disconnected. With
+// WithBatching the message may be held back and merged with the next ones.
 func (ls *LogStreamer) Broadcast(jobID string, message []byte) {
+	if ls.sink != nil {
+		if err := ls.sink.Write(jobID, message); err != nil {
+			slog.Warn("failed to persist log", "job_id", jobID, "error", err)
+		}
+	}
+
+	if ls.batchInterval <= 0 {
+		// Writers run after we return, so they need their own copy
+		ls.fanOut(jobID, append([]byte(nil), message...))
+		return
+	}
+
+	ls.batchMu.Lock()
+	defer ls.batchMu.Unlock()
+	b := ls.batches[jobID]
+	if b == nil {
+		b = &logBatch{}
+		ls.batches[jobID] = b
+		b.timer = time.AfterFunc(ls.batchInterval, func() { ls.flush(jobID) })
+	}
+	b.buf = append(b.buf, message...)
+	if len(b.buf) >= ls.batchBytes {
+		b.timer.Stop()
+		delete(ls.batches, jobID)
+		ls.fanOut(jobID, b.buf)
+	}
+}
+
+// flush sends the job's pending batch, if any, to its subscribers.
+func (ls *LogStreamer) flush(jobID string) {
+	ls.batchMu.Lock()
+	defer ls.batchMu.Unlock()
+	if b := ls.batches[jobID]; b != nil {
+		b.timer.Stop()
+		delete(ls.batches, jobID)
+		ls.fanOut(jobID, b.buf)
+	}
+}
+
+// fanOut queues msg, which the caller must not modify afterwards, for the job's
+// subscribers, and prunes any found dead.
+func (ls *LogStreamer) fanOut(jobID string, msg []byte) {
 	ls.mu.RLock()
-	defer ls.mu.RUnlock()
+	if ls.backlogLines > 0 {
+		ls.backlogMu.Lock()
+		b := ls.backlogs[jobID]
+		if b == nil {
+			b = &logBacklog{}
+			ls.backlogs[jobID] = b
+		}
+		b.write(msg, ls.backlogLines)
+		ls.backlogMu.Unlock()
+	}
+	dead := false
+	for _, s := range ls.subscribers[jobID] {
+		if s.send(msg) {
+			slog.Warn("disconnected slow log subscriber", "job_id", jobID)
+		}
+		dead = dead || s.dead.Load()
+	}
+	ls.mu.RUnlock()
+	if dead {
+		ls.mu.Lock()
+		ls.pruneDead(jobID)
+		ls.mu.Unlock()
+	}
+}
+
+// pruneDead drops the job's subscribers whose connection has failed or was cut
+// off for being too slow. Callers must hold the write lock.
+func (ls *LogStreamer) pruneDead(jobID string) {
 	subscribers := ls.subscribers[jobID]
-	for _, conn := range subscribers {
-		if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
-			// Handle error, e.g., remove the connection
+	live := subscribers[:0]
+	for _, s := range subscribers {
+		if s.dead.Load() {
+			s.finish(nil)
+			LogSubscribers.Dec()
+			continue
 		}
+		live = append(live, s)
+	}
+	if len(live) == 0 {
+		delete(ls.subscribers, jobID)
+		return
 	}
+	ls.subscribers[jobID] = live
 }
 
-// Close closes all connections for a job
+// Close closes all connections for a job once their pending messages are written
 func (ls *LogStreamer) Close(jobID string) {
+	ls.close(jobID, nil, nil)
+}
+
+// resultMessage is the last text message of a job's stream, sent after every
+// log line so clients can tell it apart from output by its "type".
+type resultMessage struct {
+	Type     string    `json:"type"`
+	Status   JobStatus `json:"status"`
+	ExitCode *int      `json:"exit_code,omitempty"`
+}
+
+// Close codes of a finished job's log stream, from the range RFC 6455 leaves to
+// applications. A completed job closes with 1000 (normal closure).
+const (
+	CloseJobFailed   = 4000
+	CloseJobCanceled = 4001
+)
+
+// closeCode returns the WebSocket close code for a job that ended in status.
+func closeCode(status JobStatus) int {
+	switch status {
+	case JobStatusCompleted:
+		return websocket.CloseNormalClosure
+	case JobStatusCanceled:
+		return CloseJobCanceled
+	default:
+		return CloseJobFailed
+	}
+}
+
+// closeReason is the JSON close-frame reason telling clients how the job ended.
+type closeReason struct {
+	Status   JobStatus `json:"status"`
+	ExitCode *int      `json:"exit_code,omitempty"`
+}
+
+// CloseWithStatus closes a job's connections like Close. Subscribers first get a
+// {"type": "result", "status": ..., "exit_code": ...} message, which is not
+// archived, and WebSocket streams then end with a close frame carrying the same
+// status as its reason: 1000 if the job completed, CloseJobFailed or
+// CloseJobCanceled otherwise.
+func (ls *LogStreamer) CloseWithStatus(jobID string, status JobStatus, exitCode *int) {
+	result, closeFrame := finalMessages(status, exitCode)
+	ls.close(jobID, result, closeFrame)
+}
+
+// finalMessages builds the result message and close frame ending the stream of
+// a job that finished in status.
+func finalMessages(status JobStatus, exitCode *int) (result, closeFrame []byte) {
+	result, _ = json.Marshal(resultMessage{Type: "result", Status: status, ExitCode: exitCode})
+	reason, _ := json.Marshal(closeReason{Status: status, ExitCode: exitCode})
+	return result, websocket.FormatCloseMessage(closeCode(status), string(reason))
+}
+
+// SendFinished replays a finished job's stream to conn: output, if any, then the
+// result message and close frame subscribers got when it ended. conn is closed.
+func SendFinished(conn LogSubscriber, output []byte, status JobStatus, exitCode *int) {
+	defer conn.Close()
+	result, closeFrame := finalMessages(status, exitCode)
+	if len(output) > 0 {
+		if err := conn.WriteMessage(websocket.TextMessage, output); err != nil {
+			return
+		}
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, result); err != nil {
+		return
+	}
+	if cw, ok := conn.(controlWriter); ok {
+		_ = cw.WriteControl(websocket.CloseMessage, closeFrame, time.Now().Add(closeFrameTimeout))
+	}
+}
+
+// close flushes pending output, sends last, if any, to every subscriber and
+// then ends their streams.
+func (ls *LogStreamer) close(jobID string, last, closeFrame []byte) {
+	ls.flush(jobID)
 	ls.mu.Lock()
 	defer ls.mu.Unlock()
-	subscribers := ls.subscribers[jobID]
-	for _, conn := range subscribers {
-		conn.Close()
+	for _, s := range ls.subscribers[jobID] {
+		if last != nil {
+			s.send(last)
+		}
+		s.finish(closeFrame)
 	}
+	LogSubscribers.Sub(float64(len(ls.subscribers[jobID])))
 	delete(ls.subscribers, jobID)
+	ls.backlogMu.Lock()
+	delete(ls.backlogs, jobID)
+	ls.backlogMu.Unlock()
+	ls.ended[jobID] = struct{}{}
+	time.AfterFunc(endedRetention, func() {
+		ls.mu.Lock()
+		delete(ls.ended, jobID)
+		ls.mu.Unlock()
+	})
+
+	if ls.sink != nil {
+		if err := ls.sink.Close(jobID); err != nil {
+			slog.Warn("failed to flush log", "job_id", jobID, "error", err)
+		}
+	}
 }
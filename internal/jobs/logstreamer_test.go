@@ -0,0 +1,359 @@
+package jobs
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// slowSubscriber blocks every write until it is closed.
+type slowSubscriber struct {
+	once   sync.Once
+	closed chan struct{}
+}
+
+func (s *slowSubscriber) WriteMessage(int, []byte) error {
+	<-s.closed
+	return errors.New("closed")
+}
+
+func (s *slowSubscriber) Close() error {
+	s.once.Do(func() { close(s.closed) })
+	return nil
+}
+
+type recordingSubscriber struct {
+	mu     sync.Mutex
+	msgs   []string
+	got    chan struct{}
+	once   sync.Once
+	closed chan struct{}
+}
+
+func (s *recordingSubscriber) WriteMessage(_ int, data []byte) error {
+	s.mu.Lock()
+	s.msgs = append(s.msgs, string(data))
+	s.mu.Unlock()
+	s.got <- struct{}{}
+	return nil
+}
+
+func (s *recordingSubscriber) Close() error {
+	s.once.Do(func() { close(s.closed) })
+	return nil
+}
+
+func TestLogStreamer_SlowSubscriberDoesNotBlockOthers(t *testing.T) {
+	const messages = 100
+	ls := NewLogStreamer(WithSubscriberBuffer(4))
+	slow := &slowSubscriber{closed: make(chan struct{})}
+	fast := &recordingSubscriber{got: make(chan struct{}, messages), closed: make(chan struct{})}
+	ls.Subscribe("job-1", slow)
+	ls.Subscribe("job-1", fast)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < messages; i++ {
+			ls.Broadcast("job-1", []byte{byte('a' + i%26)})
+			// Keep pace with the fast reader so only the slow one overflows
+			select {
+			case <-fast.got:
+			case <-time.After(time.Second):
+				return
+			}
+		}
+		ls.Close("job-1")
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("broadcast blocked on a slow subscriber")
+	}
+	select {
+	case <-slow.closed:
+	default:
+		t.Fatal("expected the slow subscriber to be disconnected")
+	}
+	select {
+	case <-fast.closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("fast subscriber was never closed")
+	}
+
+	fast.mu.Lock()
+	defer fast.mu.Unlock()
+	if len(fast.msgs) != messages {
+		t.Fatalf("fast subscriber got %d messages, want %d", len(fast.msgs), messages)
+	}
+	for i, m := range fast.msgs {
+		if want := string(rune('a' + i%26)); m != want {
+			t.Fatalf("message %d = %q, want %q", i, m, want)
+		}
+	}
+}
+
+func TestLogStreamer_MaxSubscribersPerJob(t *testing.T) {
+	ls := NewLogStreamer(WithMaxSubscribersPerJob(2))
+	before := testutil.ToFloat64(LogSubscribers)
+
+	a := &recordingSubscriber{got: make(chan struct{}, 1), closed: make(chan struct{})}
+	b := &recordingSubscriber{got: make(chan struct{}, 1), closed: make(chan struct{})}
+	c := &recordingSubscriber{got: make(chan struct{}, 1), closed: make(chan struct{})}
+	if err := ls.Subscribe("job-1", a); err != nil {
+		t.Fatalf("first subscribe failed: %v", err)
+	}
+	if err := ls.Subscribe("job-1", b); err != nil {
+		t.Fatalf("second subscribe failed: %v", err)
+	}
+	if err := ls.Subscribe("job-1", c); !errors.Is(err, ErrTooManySubscribers) {
+		t.Fatalf("expected ErrTooManySubscribers, got %v", err)
+	}
+	if err := ls.Subscribe("job-2", c); err != nil {
+		t.Fatalf("limit should be per job, got %v", err)
+	}
+	if got := testutil.ToFloat64(LogSubscribers) - before; got != 3 {
+		t.Fatalf("expected 3 open subscribers, gauge moved by %v", got)
+	}
+
+	ls.Unsubscribe("job-1", a)
+	if err := ls.Subscribe("job-1", c); err != nil {
+		t.Fatalf("expected room after unsubscribe, got %v", err)
+	}
+	ls.Close("job-1")
+	ls.Close("job-2")
+	if got := testutil.ToFloat64(LogSubscribers) - before; got != 0 {
+		t.Fatalf("expected gauge back to baseline, moved by %v", got)
+	}
+}
+
+// failingSubscriber fails every write, like a connection the client dropped.
+type failingSubscriber struct{}
+
+func (failingSubscriber) WriteMessage(int, []byte) error { return errors.New("broken pipe") }
+func (failingSubscriber) Close() error                   { return nil }
+
+func TestLogStreamer_SubscriberGaugeReturnsToZero(t *testing.T) {
+	ls := NewLogStreamer()
+	before := testutil.ToFloat64(LogSubscribers)
+	gauge := func() float64 { return testutil.ToFloat64(LogSubscribers) - before }
+
+	live := &recordingSubscriber{got: make(chan struct{}, 10), closed: make(chan struct{})}
+	left := &recordingSubscriber{got: make(chan struct{}, 10), closed: make(chan struct{})}
+	ls.Subscribe("job-1", live)
+	ls.Subscribe("job-1", left)
+	ls.Subscribe("job-1", failingSubscriber{})
+	if got := gauge(); got != 3 {
+		t.Fatalf("expected 3 open subscribers, gauge moved by %v", got)
+	}
+
+	ls.Unsubscribe("job-1", left)
+	// The failing connection is marked dead by its first write and pruned by a later broadcast
+	ls.Broadcast("job-1", []byte("a\n"))
+	<-live.got
+	deadline := time.Now().Add(2 * time.Second)
+	for gauge() != 1 && time.Now().Before(deadline) {
+		ls.Broadcast("job-1", []byte("b\n"))
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := gauge(); got != 1 {
+		t.Fatalf("expected the dead subscriber to be pruned, gauge moved by %v", got)
+	}
+
+	// Unsubscribing a pruned connection must not count it twice
+	ls.Unsubscribe("job-1", failingSubscriber{})
+	ls.Close("job-1")
+	if got := gauge(); got != 0 {
+		t.Fatalf("expected gauge back to baseline, moved by %v", got)
+	}
+}
+
+// stalledSubscriber never completes a write: like a connection to a peer that
+// vanished, each one blocks until the write deadline passes.
+type stalledSubscriber struct {
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+func (s *stalledSubscriber) SetWriteDeadline(t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deadline = t
+	return nil
+}
+
+func (s *stalledSubscriber) WriteMessage(int, []byte) error {
+	s.mu.Lock()
+	deadline := s.deadline
+	s.mu.Unlock()
+	if deadline.IsZero() {
+		select {} // no deadline, no way out
+	}
+	time.Sleep(time.Until(deadline))
+	return os.ErrDeadlineExceeded
+}
+
+func (s *stalledSubscriber) Close() error { return nil }
+
+func TestLogStreamer_WriteTimeoutPrunesStalledSubscriber(t *testing.T) {
+	ls := NewLogStreamer(WithWriteTimeout(20 * time.Millisecond))
+	stalled := &stalledSubscriber{}
+	live := &recordingSubscriber{got: make(chan struct{}, 100), closed: make(chan struct{})}
+	ls.Subscribe("job-1", stalled)
+	ls.Subscribe("job-1", live)
+
+	// The buffer never fills, so only the deadline can free the stalled writer
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		ls.Broadcast("job-1", []byte("a\n"))
+		<-live.got
+		ls.mu.RLock()
+		n := len(ls.subscribers["job-1"])
+		ls.mu.RUnlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stalled subscriber was never pruned, %d left", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	ls.Close("job-1")
+}
+
+func TestLogStreamer_BatchingMergesWritesAndFlushesOnClose(t *testing.T) {
+	ls := NewLogStreamer(WithBatching(time.Hour, 8))
+	sub := &recordingSubscriber{got: make(chan struct{}, 10), closed: make(chan struct{})}
+	ls.Subscribe("job-1", sub)
+
+	// The byte threshold flushes "abc\ndef\n" together; "gh\n" waits for the close
+	for _, line := range []string{"abc\n", "def\n", "gh\n"} {
+		ls.Broadcast("job-1", []byte(line))
+	}
+	ls.Close("job-1")
+	select {
+	case <-sub.closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscriber was never closed")
+	}
+
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if len(sub.msgs) != 2 || sub.msgs[0] != "abc\ndef\n" || sub.msgs[1] != "gh\n" {
+		t.Fatalf("messages = %q, want two batches", sub.msgs)
+	}
+}
+
+func TestLogStreamer_BatchingFlushesAfterInterval(t *testing.T) {
+	ls := NewLogStreamer(WithBatching(20*time.Millisecond, 0))
+	sub := &recordingSubscriber{got: make(chan struct{}, 10), closed: make(chan struct{})}
+	ls.Subscribe("job-1", sub)
+	defer ls.Close("job-1")
+
+	ls.Broadcast("job-1", []byte("a\n"))
+	ls.Broadcast("job-1", []byte("b\n"))
+	select {
+	case <-sub.got:
+	case <-time.After(2 * time.Second):
+		t.Fatal("batch was not flushed after the interval")
+	}
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if len(sub.msgs) != 1 || sub.msgs[0] != "a\nb\n" {
+		t.Fatalf("messages = %q, want one batch", sub.msgs)
+	}
+}
+
+// countingSubscriber counts the messages written to it.
+func TestLogStreamer_SubscribeTailReplaysBacklog(t *testing.T) {
+	ls := NewLogStreamer(WithBacklog(3))
+	ls.Broadcast("job-1", []byte("a\nb\nc\nd\npart"))
+
+	sub := &recordingSubscriber{got: make(chan struct{}, 2), closed: make(chan struct{})}
+	if err := ls.SubscribeTail("job-1", sub, 2); err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+	ls.Broadcast("job-1", []byte("ial\n"))
+	<-sub.got
+	<-sub.got
+	sub.mu.Lock()
+	got := strings.Join(sub.msgs, "|")
+	sub.mu.Unlock()
+	if got != "d\npart|ial\n" {
+		t.Fatalf("expected the last two lines then live output, got %q", got)
+	}
+
+	// Only the last three lines are kept
+	if tail := string(ls.Tail("job-1", 10)); tail != "c\nd\npartial\n" {
+		t.Fatalf("expected the backlog to hold 3 lines, got %q", tail)
+	}
+
+	ls.Close("job-1")
+	<-sub.closed
+	if tail := ls.Tail("job-1", 10); tail != nil {
+		t.Fatalf("expected the backlog to go with the stream, got %q", tail)
+	}
+	if err := ls.SubscribeTail("job-1", sub, 2); !errors.Is(err, ErrStreamEnded) {
+		t.Fatalf("expected ErrStreamEnded, got %v", err)
+	}
+}
+
+func TestLogBacklog_LongLineKeepsItsEnd(t *testing.T) {
+	var b logBacklog
+	b.write([]byte("first\n"), 10)
+	b.write([]byte(strings.Repeat("x", maxBacklogBytes+10)), 10)
+	if b.bytes != maxBacklogBytes || len(b.lines) != 0 || len(b.tail(5)) != maxBacklogBytes {
+		t.Fatalf("expected only the last %d bytes of the line, got %d bytes in %d lines", maxBacklogBytes, b.bytes, len(b.lines))
+	}
+}
+
+type countingSubscriber struct {
+	msgs atomic.Int64
+}
+
+func (s *countingSubscriber) WriteMessage(int, []byte) error {
+	s.msgs.Add(1)
+	return nil
+}
+
+func (s *countingSubscriber) Close() error { return nil }
+
+// BenchmarkLogStreamer_Broadcast streams a chatty job's output lines to ten
+// subscribers, with and without batching; msgs/op is the messages each
+// subscriber was sent per line.
+func BenchmarkLogStreamer_Broadcast(b *testing.B) {
+	line := []byte(strings.Repeat("x", 79) + "\n")
+	for _, bc := range []struct {
+		name string
+		opts []LogStreamerOption
+	}{
+		{"immediate", nil},
+		{"batched-50ms", []LogStreamerOption{WithBatching(50*time.Millisecond, 0)}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			ls := NewLogStreamer(append(bc.opts, WithSubscriberBuffer(1<<20))...)
+			subs := make([]*countingSubscriber, 10)
+			for i := range subs {
+				subs[i] = &countingSubscriber{}
+				ls.Subscribe("job-1", subs[i])
+			}
+			b.SetBytes(int64(len(line)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ls.Broadcast("job-1", line)
+			}
+			ls.Close("job-1")
+			b.StopTimer()
+			// Close only queues the close; give the writers a moment to drain
+			time.Sleep(10 * time.Millisecond)
+			b.ReportMetric(float64(subs[0].msgs.Load())/float64(b.N), "msgs/op")
+		})
+	}
+}
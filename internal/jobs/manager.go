@@ -2,17 +2,44 @@ package jobs
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
 	"log/slog"
+	"runtime/debug"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/paulgrammer/childprocess/internal/executor"
 	"github.com/paulgrammer/childprocess/internal/webhook"
 )
 
+// ErrJobNotFound is returned when a job ID is not present in the store.
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrManagerStopped is returned when submitting to a stopped manager.
+var ErrManagerStopped = errors.New("manager stopped")
+
+// ErrInvalidRequest wraps errors caused by a job spec that can never run.
+var ErrInvalidRequest = errors.New("invalid job request")
+
+// ErrQueueFull is returned when every queue slot is taken by a job waiting for a worker.
+var ErrQueueFull = errors.New("job queue is full")
+
+// ErrTooManyJobs is returned when WithMaxJobs unfinished jobs, queued or running, already exist.
+var ErrTooManyJobs = errors.New("too many unfinished jobs")
+
+// defaultQueueSize is the number of jobs that may wait for a worker.
+const defaultQueueSize = 1024
+
+// defaultWebhookConcurrency is the number of webhook deliveries allowed in flight at once.
+const defaultWebhookConcurrency = 8
+
 type Manager struct {
 	concurrency int
 	jobsChan    chan string
@@ -22,30 +49,242 @@ type Manager struct {
 	sender      webhook.Sender
 	runner      executor.Runner
 	streamer    *LogStreamer
+	events      *eventBus
+
+	queueSize          int
+	maxJobs            int
+	defaultTimeout     time.Duration
+	maxTimeout         time.Duration
+	queued             *queueIndex
+	runs               *jobRuns
+	pause              *pauseGate
+	allowEmptyCommand  bool
+	rejectControlChars bool
+	argLimits          ArgLimits
+	metadataTemplating bool
+	templates          *TemplateRegistry
+	preExecHooks       []PreExecHook
+	uploader           OutputUploader
+	uploadWG           sync.WaitGroup
+	webhookConcurrency int
+	webhookOutput      webhookOutputPolicy
+	webhookQueues      []chan delivery
+	webhookWG          sync.WaitGroup
+	webhookMu          sync.RWMutex
+	webhookClosed      bool
+
+	// stuck is the watchdog for jobs left in progress too long
+	stuck stuckWatch
+
+	// jobsTotal, if set, counts queued and finished jobs by promoted metadata
+	jobsTotal *JobsTotal
+
+	// traces holds the submit span context of each traced job until it runs
+	traces sync.Map
+
+	// Totals over every run that reached the runner, for Stats
+	runsFinished atomic.Int64
+	runTimeTotal atomic.Int64 // nanoseconds
+}
+
+type ManagerOption func(*Manager)
+
+// WithDefaultCommandConfigured lets jobs omit the command because the runner has a default.
+func WithDefaultCommandConfigured() ManagerOption {
+	return func(m *Manager) {
+		m.allowEmptyCommand = true
+	}
+}
+
+// WithRejectControlChars rejects jobs whose command or args contain control
+// characters other than tab. Null bytes are rejected regardless.
+func WithRejectControlChars() ManagerOption {
+	return func(m *Manager) {
+		m.rejectControlChars = true
+	}
+}
+
+// WithArgLimits replaces the default caps on the number and combined size of
+// a job's args.
+func WithArgLimits(l ArgLimits) ManagerOption {
+	return func(m *Manager) {
+		m.argLimits = l
+	}
+}
+
+// WithMetadataTemplating expands {{.Metadata.key}} placeholders in a job's
+// command and args from its metadata when it is submitted.
+func WithMetadataTemplating() ManagerOption {
+	return func(m *Manager) {
+		m.metadataTemplating = true
+	}
+}
+
+// WithTemplates lets jobs reference the registry's command templates by name.
+func WithTemplates(r *TemplateRegistry) ManagerOption {
+	return func(m *Manager) {
+		m.templates = r
+	}
+}
+
+// WithQueueSize sets how many jobs may wait for a worker before Submit fails with ErrQueueFull.
+func WithQueueSize(n int) ManagerOption {
+	return func(m *Manager) {
+		m.queueSize = n
+	}
 }
 
-func NewManager(poolSize int, store Store, sender webhook.Sender, runner executor.Runner, streamer *LogStreamer) (*Manager, error) {
+// WithMaxJobs caps the jobs that may be queued or running at once; Submit fails
+// with ErrTooManyJobs beyond it. Unlike WithQueueSize it counts running jobs
+// too. 0, the default, means no limit.
+func WithMaxJobs(n int) ManagerOption {
+	return func(m *Manager) {
+		m.maxJobs = n
+	}
+}
+
+// WithDefaultTimeout is the timeout of jobs submitted without TimeoutSeconds.
+func WithDefaultTimeout(d time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.defaultTimeout = d
+	}
+}
+
+// WithMaxTimeout caps every job's timeout, including jobs that asked for none
+// or for longer.
+func WithMaxTimeout(d time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.maxTimeout = d
+	}
+}
+
+// effectiveTimeout picks the timeout a job requesting requested seconds runs
+// under, in whole seconds; 0 means none.
+func (m *Manager) effectiveTimeout(requested int) int {
+	timeout := time.Duration(requested) * time.Second
+	if timeout == 0 {
+		timeout = m.defaultTimeout
+	}
+	if m.maxTimeout > 0 && (timeout == 0 || timeout > m.maxTimeout) {
+		timeout = m.maxTimeout
+	}
+	return int(timeout / time.Second)
+}
+
+// WithWebhookConcurrency bounds the number of webhook deliveries in flight at once.
+func WithWebhookConcurrency(n int) ManagerOption {
+	return func(m *Manager) {
+		m.webhookConcurrency = n
+	}
+}
+
+// WithoutWebhookOutput leaves stdout and stderr out of every webhook, even for
+// jobs that set IncludeOutputInWebhook. Exit code and duration are still sent.
+func WithoutWebhookOutput() ManagerOption {
+	return func(m *Manager) {
+		m.webhookOutput.exclude = true
+	}
+}
+
+// WithWebhookOutputLimit truncates the stdout and stderr embedded in webhooks to
+// n bytes each, marking the job Truncated; 0 sends them as stored.
+func WithWebhookOutputLimit(n int) ManagerOption {
+	return func(m *Manager) {
+		m.webhookOutput.maxBytes = n
+	}
+}
+
+// WithWebhookMaxPayload keeps webhook bodies to n bytes of JSON by truncating
+// the output they embed, setting OutputTruncated; 0 means no limit.
+func WithWebhookMaxPayload(n int) ManagerOption {
+	return func(m *Manager) {
+		m.webhookOutput.maxPayloadBytes = n
+	}
+}
+
+// webhookOutputPolicy trims the output of webhook payloads; event streams are
+// not affected.
+type webhookOutputPolicy struct {
+	exclude         bool
+	maxBytes        int
+	maxPayloadBytes int
+}
+
+// delivery is a webhook event waiting to be sent.
+type delivery struct {
+	ctx   context.Context
+	url   string
+	event webhook.Event
+}
+
+func NewManager(poolSize int, store Store, sender webhook.Sender, runner executor.Runner, streamer *LogStreamer, opts ...ManagerOption) (*Manager, error) {
 	if poolSize <= 0 {
 		return nil, errors.New("pool size must be > 0")
 	}
 
 	m := &Manager{
-		concurrency: poolSize,
-		jobsChan:    make(chan string, 1024),
-		store:       store,
-		sender:      sender,
-		runner:      runner,
-		streamer:    streamer,
+		concurrency:        poolSize,
+		queueSize:          defaultQueueSize,
+		queued:             newQueueIndex(),
+		runs:               newJobRuns(),
+		pause:              newPauseGate(),
+		store:              store,
+		sender:             sender,
+		runner:             runner,
+		streamer:           streamer,
+		events:             newEventBus(),
+		webhookConcurrency: defaultWebhookConcurrency,
+	}
+	for _, opt := range opts {
+		opt(m)
 	}
+	if m.queueSize <= 0 {
+		return nil, errors.New("queue size must be > 0")
+	}
+	if m.webhookConcurrency <= 0 {
+		return nil, errors.New("webhook concurrency must be > 0")
+	}
+	m.jobsChan = make(chan string, m.queueSize)
+
+	// A persistent store may already hold jobs, which Delete will decrement
+	if counts, err := store.CountByStatus(); err == nil {
+		for _, n := range counts {
+			JobsActive.Add(float64(n))
+		}
+	}
+
+	// Each job's events are pinned to one delivery worker so they arrive in order
+	m.webhookQueues = make([]chan delivery, m.webhookConcurrency)
+	for i := range m.webhookQueues {
+		q := make(chan delivery, 1024)
+		m.webhookQueues[i] = q
+		m.webhookWG.Add(1)
+		go func() {
+			defer m.webhookWG.Done()
+			for d := range q {
+				m.deliver(d)
+			}
+		}()
+	}
+
 	for i := 0; i < m.concurrency; i++ {
 		m.wg.Add(1)
 		go func() {
 			defer m.wg.Done()
 			for id := range m.jobsChan {
-				m.execute(id)
+				// Hold the job while paused; it stays indexed, so it can still be
+				// canceled or flushed
+				m.pause.wait()
+				// Flushed jobs stay in the channel but are no longer indexed
+				if m.queued.remove(id) {
+					m.executeRecovered(id)
+				}
 			}
 		}()
 	}
+	if m.stuck.enabled() {
+		m.startWatchdog()
+	}
 	return m, nil
 }
 
@@ -53,12 +292,174 @@ func (m *Manager) Stop() {
 	if m.stopped.Swap(true) {
 		return
 	}
+	m.stopWatchdog()
+	m.pause.release()
 	close(m.jobsChan)
 	m.wg.Wait()
+	m.uploadWG.Wait()
+
+	// Flush pending webhook deliveries
+	m.webhookMu.Lock()
+	m.webhookClosed = true
+	for _, q := range m.webhookQueues {
+		close(q)
+	}
+	m.webhookMu.Unlock()
+	m.webhookWG.Wait()
+}
+
+// Health summarizes whether the manager can accept new work.
+type Health struct {
+	Ready         bool   `json:"ready"`
+	Stopped       bool   `json:"stopped"`
+	Paused        bool   `json:"paused"`
+	QueueDepth    int    `json:"queue_depth"`
+	QueueCapacity int    `json:"queue_capacity"`
+	Workers       int    `json:"workers"`
+	Error         string `json:"error,omitempty"`
+}
+
+// Pinger is implemented by stores backed by an external service that can be health-checked.
+type Pinger interface {
+	Ping() error
+}
+
+// Health reports readiness: the manager is running, the queue has room and the store is reachable.
+func (m *Manager) Health() Health {
+	h := Health{
+		Stopped:       m.stopped.Load(),
+		Paused:        m.Paused(),
+		QueueDepth:    len(m.jobsChan),
+		QueueCapacity: cap(m.jobsChan),
+		Workers:       m.concurrency,
+	}
+	switch {
+	case h.Stopped:
+		h.Error = "manager stopped"
+	case h.QueueDepth >= h.QueueCapacity:
+		h.Error = "queue full"
+	default:
+		if p, ok := m.store.(Pinger); ok {
+			if err := p.Ping(); err != nil {
+				h.Error = "store unreachable: " + err.Error()
+			}
+		}
+	}
+	h.Ready = h.Error == ""
+	return h
 }
 
 func (m *Manager) Submit(ctx context.Context, req CreateJobRequest) (string, error) {
-	id := uuid.NewString()
+	return m.submit(ctx, req, "")
+}
+
+// Retry queues a new job with the same spec as a prior job, regardless of the
+// prior job's status. The new job is linked to the original via RetryOf.
+func (m *Manager) Retry(ctx context.Context, id string) (string, error) {
+	prev, ok := m.store.Get(id)
+	if !ok {
+		return "", ErrJobNotFound
+	}
+	req := CreateJobRequest{
+		WorkingDir: prev.WorkingDir,
+		WebhookURL: prev.WebhookURL,
+		RunAsUser:  prev.RunAsUser,
+		RunAsGroup: prev.RunAsGroup,
+
+		IncludeOutputInWebhook: prev.IncludeOutputInWebhook,
+		Nice:                   prev.Nice,
+		Tags:                   append([]string(nil), prev.Tags...),
+		CreateWorkingDir:       prev.CreateWorkingDir,
+		CleanupWorkingDir:      prev.CleanupWorkingDir,
+		MaxQueueWaitSeconds:    prev.MaxQueueWaitSeconds,
+		TimeoutSeconds:         prev.TimeoutSeconds,
+		Sensitive:              prev.Sensitive,
+		RedactOutput:           prev.RedactOutput,
+		WebhookEvents:          append([]string(nil), prev.WebhookEvents...),
+		OutputEncoding:         prev.OutputEncoding,
+		Setup:                  prev.Setup.clone(),
+	}
+	// Template jobs are resolved again, so they pick up template changes
+	if prev.Template != "" {
+		req.Template = prev.Template
+		req.Params = copyStringMap(prev.Params)
+	} else {
+		req.Command = prev.Command
+		req.Args = append([]string(nil), prev.Args...)
+	}
+	req.Metadata = copyStringMap(prev.Metadata)
+	return m.submit(ctx, req, prev.ID)
+}
+
+func copyStringMap(src map[string]string) map[string]string {
+	if src == nil {
+		return nil
+	}
+	dst := make(map[string]string, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+func (m *Manager) submit(ctx context.Context, req CreateJobRequest, retryOf string) (string, error) {
+	if err := req.ValidateWithLimits(m.allowEmptyCommand, m.argLimits); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+	if err := executor.ValidateRunAs(req.RunAsUser, req.RunAsGroup); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	// Retries reuse the command and args as expanded the first time
+	if m.metadataTemplating && req.Template == "" && retryOf == "" {
+		command, args, err := expandMetadata(req.Command, req.Args, req.Metadata)
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+		}
+		req.Command, req.Args = command, args
+	}
+	if req.Template != "" {
+		if m.templates == nil {
+			return "", fmt.Errorf("%w: %w", ErrInvalidRequest, FieldErrors{"template": "templates are not configured"})
+		}
+		command, args, err := m.templates.Resolve(req.Template, req.Params)
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+		}
+		req.Command, req.Args = command, args
+	}
+	// Expansion can grow the args past what was checked above
+	if req.Template != "" || m.metadataTemplating {
+		if msg := m.argLimits.check(req.Args); msg != "" {
+			return "", fmt.Errorf("%w: %w", ErrInvalidRequest, FieldErrors{"args": msg})
+		}
+	}
+	if m.rejectControlChars {
+		if err := checkControlChars(req.Command, req.Args); err != nil {
+			return "", fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+		}
+		if req.Setup != nil {
+			if err := checkControlChars(req.Setup.Command, req.Setup.Args); err != nil {
+				return "", fmt.Errorf("%w: %w", ErrInvalidRequest, FieldErrors{"setup": "must not contain control characters"})
+			}
+		}
+	}
+
+	// Reject up front rather than leave the caller blocked behind the queue. Racing
+	// submitters can still fill the last slot, in which case the send below waits.
+	if len(m.jobsChan) >= cap(m.jobsChan) {
+		return "", ErrQueueFull
+	}
+	if !m.runs.reserve(m.maxJobs) {
+		return "", ErrTooManyJobs
+	}
+
+	id := req.ID
+	if id == "" {
+		id = uuid.NewString()
+	}
+	ctx, span := m.startSubmitSpan(ctx, id)
+	defer span.End()
+	now := time.Now().UTC()
 	job := &Job{
 		ID:         id,
 		Command:    req.Command,
@@ -67,23 +468,126 @@ func (m *Manager) Submit(ctx context.Context, req CreateJobRequest) (string, err
 		WebhookURL: req.WebhookURL,
 		Metadata:   req.Metadata,
 		Status:     JobStatusQueued,
-		CreatedAt:  time.Now().UTC(),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		RetryOf:    retryOf,
+		RunAsUser:  req.RunAsUser,
+		RunAsGroup: req.RunAsGroup,
+
+		IncludeOutputInWebhook: req.IncludeOutputInWebhook,
+		Template:               req.Template,
+		Params:                 req.Params,
+		Nice:                   req.Nice,
+		Tags:                   req.Tags,
+		CreateWorkingDir:       req.CreateWorkingDir,
+		CleanupWorkingDir:      req.CleanupWorkingDir,
+		MaxQueueWaitSeconds:    req.MaxQueueWaitSeconds,
+		TimeoutSeconds:         req.TimeoutSeconds,
+		Sensitive:              req.Sensitive,
+		RedactOutput:           req.RedactOutput,
+		WebhookEvents:          req.WebhookEvents,
+		OutputEncoding:         req.OutputEncoding,
+		Setup:                  req.Setup,
+
+		EffectiveTimeoutSeconds: m.effectiveTimeout(req.TimeoutSeconds),
 	}
 	if err := m.store.Create(job); err != nil {
+		m.runs.unreserve()
 		return "", err
 	}
 	JobsQueuedTotal.Inc()
 	JobsActive.Inc()
-	// Notify queued
-	defer m.notify(ctx, *job)
 	if m.stopped.Load() {
-		return "", errors.New("manager stopped")
+		m.runs.unreserve()
+		return "", ErrManagerStopped
 	}
-	// Enqueue; may block if queue is full
+	m.runs.track(id)
+	// Notify queued before enqueueing so it is delivered ahead of in_progress
+	m.notify(ctx, *job)
+	// Enqueue; only blocks if another submit took the last slot
+	m.queued.add(id)
 	m.jobsChan <- id
 	return id, nil
 }
 
+// QueuedIDs returns the IDs of jobs waiting for a worker, oldest first.
+func (m *Manager) QueuedIDs() []string {
+	return m.queued.ids()
+}
+
+// FlushQueue fails every job still waiting for a worker and returns their IDs.
+func (m *Manager) FlushQueue(ctx context.Context) []string {
+	ids := m.queued.drain()
+	// Free channel capacity; workers skip any flushed IDs they still receive.
+	// IDs enqueued while draining are flushed too rather than lost.
+	for drained := false; !drained; {
+		select {
+		case id, ok := <-m.jobsChan:
+			if !ok {
+				drained = true
+			} else if m.queued.remove(id) {
+				ids = append(ids, id)
+			}
+		default:
+			drained = true
+		}
+	}
+
+	for _, id := range ids {
+		job, ok := m.transition(id, func(j *Job) {
+			now := time.Now().UTC()
+			j.Status = JobStatusFailed
+			j.Error = "flushed"
+			j.CompletedAt = &now
+		})
+		if !ok {
+			continue
+		}
+		m.notify(ctx, *job)
+		JobsFailedTotal.Inc()
+		m.runs.finish(id)
+	}
+	return ids
+}
+
+// Stats is an aggregate view of the jobs the manager knows about.
+type Stats struct {
+	Queued        int     `json:"queued"`
+	InProgress    int     `json:"in_progress"`
+	Completed     int     `json:"completed"`
+	Failed        int     `json:"failed"`
+	Canceled      int     `json:"canceled"`
+	AvgDurationMs float64 `json:"avg_duration_ms"`
+	QueueDepth    int     `json:"queue_depth"`
+
+	// Stuck lists the in_progress jobs the watchdog found running past their
+	// limit in its last scan; see WithStuckDetection
+	Stuck []string `json:"stuck,omitempty"`
+}
+
+// Stats counts jobs by status from the store and adds the average run time and
+// queue depth from the manager's own counters.
+func (m *Manager) Stats() (Stats, error) {
+	counts, err := m.store.CountByStatus()
+	if err != nil {
+		return Stats{}, err
+	}
+	s := Stats{
+		Queued:     counts[JobStatusQueued],
+		InProgress: counts[JobStatusInProgress],
+		Completed:  counts[JobStatusCompleted],
+		Failed:     counts[JobStatusFailed],
+		Canceled:   counts[JobStatusCanceled],
+		QueueDepth: m.queued.len(),
+		Stuck:      m.stuck.stuckIDs(),
+	}
+	if n := m.runsFinished.Load(); n > 0 {
+		avg := time.Duration(m.runTimeTotal.Load() / n)
+		s.AvgDurationMs = float64(avg) / float64(time.Millisecond)
+	}
+	return s, nil
+}
+
 func (m *Manager) Get(id string) (Job, bool) {
 	j, ok := m.store.Get(id)
 	if !ok {
@@ -92,74 +596,501 @@ func (m *Manager) Get(id string) (Job, bool) {
 	return *j, true
 }
 
-func (m *Manager) execute(id string) {
-	ctx := context.Background()
+// ErrJobNotFinished is returned by Delete for a job that is still queued or running.
+var ErrJobNotFinished = errors.New("job has not finished")
+
+// Delete removes a finished job's record and its persisted log, after waiting
+// for the worker that ran it to let go of it. Queued and running jobs are left
+// alone with ErrJobNotFinished; cancel them instead.
+func (m *Manager) Delete(ctx context.Context, id string) error {
 	job, ok := m.store.Get(id)
 	if !ok {
-		slog.Warn("job not found", "job_id", id)
+		return ErrJobNotFound
+	}
+	if !job.Status.Terminal() {
+		return ErrJobNotFinished
+	}
+	// The final status is stored before the worker flushes logs and uploads output
+	select {
+	case <-m.runs.wait(id):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err := m.store.Delete(id); err != nil {
+		return err
+	}
+	JobsActive.Dec()
+	if err := m.streamer.Remove(id); err != nil {
+		slog.Warn("failed to remove job log", "job_id", id, "error", err)
+	}
+	return nil
+}
+
+// errQueueWaitExceeded is recorded on jobs that waited past MaxQueueWaitSeconds.
+var errQueueWaitExceeded = errors.New("queue wait exceeded")
+
+// ErrJobPanicked is the cause of jobs whose execution panicked, such as in a
+// custom Runner.
+var ErrJobPanicked = errors.New("job panicked")
+
+// executeRecovered runs the job, failing it rather than the worker if execute
+// panics. The run is finished last, so waiters see the job's final status.
+func (m *Manager) executeRecovered(id string) {
+	defer m.runs.finish(id)
+	defer func() {
+		if r := recover(); r != nil {
+			m.failPanicked(id, r, debug.Stack())
+		}
+	}()
+	m.execute(id)
+}
+
+// failPanicked records the job as failed after execute panicked with r, unless
+// it had already finished.
+func (m *Manager) failPanicked(id string, r any, stack []byte) {
+	slog.Error("job execution panicked", "job_id", id, "panic", fmt.Sprint(r), "stack", string(stack))
+	JobsPanickedTotal.Inc()
+	cause := fmt.Errorf("%w: %v", ErrJobPanicked, r)
+	var wasRunning bool
+	job, ok := m.transition(id, func(j *Job) {
+		wasRunning = j.Status == JobStatusInProgress
+		now := time.Now().UTC()
+		j.PID = 0
+		j.Status = JobStatusFailed
+		j.Error = cause.Error()
+		j.CompletedAt = &now
+	})
+	if !ok {
 		return
 	}
-	now := time.Now().UTC()
-	job.Status = JobStatusInProgress
-	job.StartedAt = &now
-	_ = m.store.Update(job)
+	if wasRunning {
+		JobsInProgress.Dec()
+	}
+	JobsFailedTotal.Inc()
+	m.notify(context.Background(), *job)
+	m.streamer.Broadcast(id, []byte("Job failed: "+cause.Error()+"\n"))
+	m.streamer.CloseWithStatus(id, job.Status, job.ExitCode)
+}
+
+func (m *Manager) execute(id string) {
+	ctx, span := m.startRunSpan(context.Background(), id)
+	var result *executor.ExecutionResult
+	if m.expireQueued(ctx, id) || m.rejectByHooks(ctx, id) {
+		span.End()
+		return
+	}
+	job, ok := m.transition(id, func(j *Job) {
+		now := time.Now().UTC()
+		j.Status = JobStatusInProgress
+		j.StartedAt = &now
+	})
+	if !ok {
+		slog.Warn("job not found or already finished", "job_id", id)
+		span.End()
+		return
+	}
+	ctx = m.runs.start(ctx, id, time.Duration(job.EffectiveTimeoutSeconds)*time.Second)
+	defer func() { endRunSpan(span, job, result) }()
 	m.notify(ctx, *job)
 	JobsInProgress.Inc()
 
-	// Streamer
+	// Streamer; subscribers are told the final status when the stream ends. A job
+	// still in progress here was failed elsewhere, by the watchdog or after a
+	// panic, which also ends its stream.
 	m.streamer.Broadcast(job.ID, []byte("Job started...\n"))
-	defer m.streamer.Close(job.ID)
+	defer func() {
+		if job.Status.Terminal() {
+			m.streamer.CloseWithStatus(job.ID, job.Status, job.ExitCode)
+		}
+	}()
 
 	// Create a writer that broadcasts to the streamer
 	writer := &logStreamWriter{streamer: m.streamer, jobID: job.ID}
 
-	result, err := m.runner.Run(ctx, job.ID, job.Command, job.Args, job.WorkingDir, writer, writer)
+	runOpts := []executor.RunOption{
+		executor.WithRunAs(job.RunAsUser, job.RunAsGroup),
+		executor.WithNice(job.Nice),
+		// Called before the runner waits on the process, so it always lands ahead of
+		// the final transition that clears it
+		executor.WithOnStart(func(pid int) {
+			m.transition(id, func(j *Job) { j.PID = pid })
+		}),
+	}
+	if job.CreateWorkingDir {
+		runOpts = append(runOpts, executor.WithCreateWorkingDir(job.CleanupWorkingDir))
+	}
+	if job.Sensitive || job.RedactOutput {
+		runOpts = append(runOpts, executor.WithSensitive())
+	}
+	if job.OutputEncoding != "" {
+		runOpts = append(runOpts, executor.WithOutputEncoding(job.OutputEncoding))
+	}
+	runStart := time.Now()
+	var err error
+	if job.Setup != nil {
+		result, err = m.runSetup(ctx, job, writer, runOpts)
+	}
+	if err == nil {
+		stdout, stderr, flush := decodeStreams(writer, writer, job.OutputEncoding)
+		result, err = m.runner.Run(ctx, job.ID, job.Command, job.Args, job.WorkingDir, stdout, stderr, runOpts...)
+		flush()
+	}
+	m.runTimeTotal.Add(int64(time.Since(runStart)))
+	m.runsFinished.Add(1)
+	observeUsage(result)
 	if err != nil {
-		job.Status = JobStatusFailed
-		job.Error = err.Error()
-		_ = m.store.Update(job)
-		m.notify(ctx, *job)
+		final, ok := m.transition(id, func(j *Job) {
+			// A non-zero exit still carries the captured output
+			if result != nil {
+				j.recordResult(result)
+			}
+			now := time.Now().UTC()
+			j.PID = 0
+			j.Status = JobStatusFailed
+			j.Error = err.Error()
+			j.CompletedAt = &now
+			if ctx.Err() != nil {
+				j.Status = JobStatusCanceled
+				j.Error = context.Cause(ctx).Error()
+				// Running out of time is a failure of the job, not a cancellation
+				if errors.Is(context.Cause(ctx), ErrJobTimeout) {
+					j.Status = JobStatusFailed
+				}
+			}
+		})
+		// Not ok means the watchdog already failed the job as stuck
+		if !ok {
+			return
+		}
 		JobsInProgress.Dec()
-		JobsFailedTotal.Inc()
+		job = final
+		m.notify(ctx, *job)
+		m.uploadOutput(job, result)
+		if job.Status == JobStatusCanceled {
+			JobsCanceledTotal.Inc()
+		} else {
+			JobsFailedTotal.Inc()
+		}
 		m.streamer.Broadcast(job.ID, []byte("Job failed: "+err.Error()+"\n"))
 		return
 	}
 
-	// Update job with results
-	job.ExitCode = &result.ExitCode
-	job.Stdout = &result.Stdout
-	job.Stderr = &result.Stderr
-
 	slog.Info("job execution completed",
 		"job_id", job.ID,
 		"exit_code", result.ExitCode,
-		"stdout", result.Stdout,
-		"stderr", result.Stderr,
+		"stdout_bytes", result.StdoutBytes,
+		"stderr_bytes", result.StderrBytes,
 		"duration", result.Duration.String(),
 		"error", result.Error,
 	)
 
-	done := time.Now().UTC()
-	job.Status = JobStatusCompleted
-	job.CompletedAt = &done
-	_ = m.store.Update(job)
-	m.notify(ctx, *job)
+	final, ok := m.transition(id, func(j *Job) {
+		done := time.Now().UTC()
+		j.recordResult(result)
+		j.PID = 0
+		j.Status = JobStatusCompleted
+		j.CompletedAt = &done
+	})
+	if !ok {
+		return
+	}
 	JobsInProgress.Dec()
+	job = final
+	m.notify(ctx, *job)
+	m.uploadOutput(job, result)
 	JobsCompletedTotal.Inc()
 }
 
+// expireQueued fails the job instead of running it if it has waited longer than
+// its MaxQueueWaitSeconds since it was created, and reports whether it did.
+func (m *Manager) expireQueued(ctx context.Context, id string) bool {
+	job, ok := m.store.Get(id)
+	if !ok || job.MaxQueueWaitSeconds <= 0 {
+		return false
+	}
+	if time.Since(job.CreatedAt) <= time.Duration(job.MaxQueueWaitSeconds)*time.Second {
+		return false
+	}
+	expired, ok := m.transition(id, func(j *Job) {
+		now := time.Now().UTC()
+		j.Status = JobStatusFailed
+		j.Error = errQueueWaitExceeded.Error()
+		j.CompletedAt = &now
+	})
+	if !ok {
+		// Canceled or otherwise finished while waiting, so there is nothing to run
+		return true
+	}
+	slog.Warn("job waited too long in the queue, not running it", "job_id", id, "max_queue_wait_seconds", job.MaxQueueWaitSeconds)
+	m.notify(ctx, *expired)
+	JobsExpiredInQueueTotal.Inc()
+	JobsFailedTotal.Inc()
+	return true
+}
+
+// transition applies change to the stored job and stamps UpdatedAt, unless the
+// job has already reached a terminal status. It re-reads and retries if another
+// update lands first, and reports false if the job is missing or already finished.
+func (m *Manager) transition(id string, change func(*Job)) (*Job, bool) {
+	for {
+		job, ok := m.store.Get(id)
+		if !ok || job.Status.Terminal() {
+			return nil, false
+		}
+		change(job)
+		job.UpdatedAt = time.Now().UTC()
+		err := m.store.UpdateWithVersion(job, job.Version)
+		if errors.Is(err, ErrConcurrentModification) {
+			continue
+		}
+		if err != nil {
+			slog.Warn("failed to update job", "job_id", id, "error", err)
+		}
+		return job, true
+	}
+}
+
+// update applies change to the stored job like transition, but also to
+// finished jobs, for details that arrive after the job ends.
+func (m *Manager) update(id string, change func(*Job)) {
+	for {
+		job, ok := m.store.Get(id)
+		if !ok {
+			return
+		}
+		change(job)
+		job.UpdatedAt = time.Now().UTC()
+		err := m.store.UpdateWithVersion(job, job.Version)
+		if errors.Is(err, ErrConcurrentModification) {
+			continue
+		}
+		if err != nil {
+			slog.Warn("failed to update job", "job_id", id, "error", err)
+		}
+		return
+	}
+}
+
+// recordResult copies the exit code, output and output sizes of a run into the
+// job. Jobs with RedactOutput keep only the sizes.
+func (j *Job) recordResult(result *executor.ExecutionResult) {
+	j.ExitCode = &result.ExitCode
+	if !j.RedactOutput {
+		j.Stdout = &result.Stdout
+		j.Stderr = &result.Stderr
+	}
+	j.StdoutBytes = result.StdoutBytes
+	j.StderrBytes = result.StderrBytes
+	j.Truncated = result.Truncated
+	j.StdoutCapturedBytes = result.StdoutCapturedBytes
+	j.StderrCapturedBytes = result.StderrCapturedBytes
+	j.UserTimeMs = result.UserTime.Milliseconds()
+	j.SysTimeMs = result.SysTime.Milliseconds()
+	j.MaxRSSKB = result.MaxRSSKB
+	j.DurationMs = result.Duration.Milliseconds()
+}
+
+// notify publishes a status event to the job's event subscribers and queues it
+// for asynchronous webhook delivery, if the job's WebhookEvents include it. Deliveries outlive the caller's context, so
+// a finished HTTP request does not cancel them.
 func (m *Manager) notify(ctx context.Context, job Job) {
-	if job.WebhookURL == "" {
+	event := jobEvent(job)
+	m.events.publish(event, job.Status.Terminal())
+	if m.jobsTotal != nil && (job.Status == JobStatusQueued || job.Status.Terminal()) {
+		m.jobsTotal.observe(job)
+	}
+	if job.WebhookURL == "" || !job.wantsWebhook(job.Status) {
+		return
+	}
+	d := delivery{
+		ctx:   context.WithoutCancel(ctx),
+		url:   job.WebhookURL,
+		event: m.webhookOutput.apply(event, job),
+	}
+
+	m.webhookMu.RLock()
+	defer m.webhookMu.RUnlock()
+	if m.webhookClosed {
+		slog.Warn("dropping webhook after manager stopped", "job_id", job.ID, "status", job.Status)
 		return
 	}
-	_ = m.sender.Notify(ctx, job.WebhookURL, webhook.Event{
+	// Blocks only if this worker's queue is full, which applies back-pressure
+	m.webhookQueues[webhookShard(job.ID, len(m.webhookQueues))] <- d
+}
+
+// jobEvent describes the job's current status. Finished jobs carry their exit
+// code and duration; output is only embedded in completed/failed events of jobs
+// that asked for it and is already bounded by the executor's output caps.
+func jobEvent(job Job) webhook.Event {
+	if !job.IncludeOutputInWebhook || !job.Status.Terminal() {
+		job.Stdout, job.Stderr = nil, nil
+	}
+	return webhook.Event{
 		JobID:     job.ID,
 		Data:      job,
 		Status:    string(job.Status),
 		Error:     job.Error,
 		Timestamp: time.Now().UTC(),
 		Metadata:  job.Metadata,
-	})
+	}
+}
+
+// apply returns event with its output trimmed to the policy.
+func (p webhookOutputPolicy) apply(event webhook.Event, job Job) webhook.Event {
+	if job.Stdout == nil && job.Stderr == nil || !job.IncludeOutputInWebhook || !job.Status.Terminal() {
+		return event
+	}
+	if p.exclude {
+		job.Stdout, job.Stderr = nil, nil
+		event.Data = job
+		return event
+	}
+	if p.maxBytes > 0 {
+		var cutOut, cutErr bool
+		job.Stdout, cutOut = truncateOutput(job.Stdout, p.maxBytes)
+		job.Stderr, cutErr = truncateOutput(job.Stderr, p.maxBytes)
+		if cutOut || cutErr {
+			job.Truncated, event.OutputTruncated = true, true
+		}
+	}
+	event.Data = job
+	if p.maxPayloadBytes > 0 {
+		return p.fitPayload(event, job)
+	}
+	return event
+}
+
+// fitPayload truncates the job's output so event marshals to at most
+// maxPayloadBytes: whatever room the event leaves with empty output is shared
+// between stdout and stderr, measured as JSON. An event too large without any
+// output is sent as is.
+func (p webhookOutputPolicy) fitPayload(event webhook.Event, job Job) webhook.Event {
+	if body, err := json.Marshal(event); err != nil || len(body) <= p.maxPayloadBytes {
+		return event
+	}
+	trimmed := job
+	trimmed.Stdout, _ = truncateOutput(job.Stdout, 0)
+	trimmed.Stderr, _ = truncateOutput(job.Stderr, 0)
+	trimmed.Truncated, event.OutputTruncated = true, true
+	event.Data = trimmed
+	body, err := json.Marshal(event)
+	if err != nil {
+		return event
+	}
+	room := p.maxPayloadBytes - len(body)
+	if room <= 0 {
+		slog.Warn("webhook payload exceeds the size limit without output", "job_id", job.ID, "bytes", len(body), "limit", p.maxPayloadBytes)
+		return event
+	}
+	keepOut, keepErr := splitOutputBudget(jsonStringLen(job.Stdout), jsonStringLen(job.Stderr), room)
+	trimmed.Stdout = cutJSONString(job.Stdout, keepOut)
+	trimmed.Stderr = cutJSONString(job.Stderr, keepErr)
+	event.Data = trimmed
+	return event
+}
+
+// jsonStringLen returns roughly how long s is once escaped by encoding/json,
+// never less.
+func jsonStringLen(s *string) int {
+	encoded, _ := jsonStringPrefix(s, -1)
+	return encoded
+}
+
+// cutJSONString cuts s to the longest prefix that encodes to at most n bytes
+// of JSON string content.
+func cutJSONString(s *string, n int) *string {
+	if s == nil {
+		return nil
+	}
+	_, end := jsonStringPrefix(s, n)
+	cut := (*s)[:end]
+	return &cut
+}
+
+// jsonStringPrefix walks s rune by rune, sizing each as encoding/json escapes
+// it at most, until the encoded length would pass n (never, when n < 0). It
+// returns the encoded length and the byte length of that prefix.
+func jsonStringPrefix(s *string, n int) (encoded, end int) {
+	if s == nil {
+		return 0, 0
+	}
+	for end < len(*s) {
+		r, size := utf8.DecodeRuneInString((*s)[end:])
+		w := size
+		switch {
+		case r == utf8.RuneError && size == 1, r < 0x20 && r != '\n' && r != '\r' && r != '\t',
+			r == '<', r == '>', r == '&', r == '\u2028', r == '\u2029':
+			w = 6
+		case r < 0x20, r == '"', r == '\\':
+			w = 2
+		}
+		if n >= 0 && encoded+w > n {
+			break
+		}
+		encoded += w
+		end += size
+	}
+	return encoded, end
+}
+
+// splitOutputBudget shares budget bytes between stdout and stderr of the given
+// lengths, halving it unless one of them needs less than its half.
+func splitOutputBudget(stdout, stderr, budget int) (int, int) {
+	if budget <= 0 {
+		return 0, 0
+	}
+	half := budget / 2
+	switch {
+	case stdout <= half:
+		return stdout, budget - stdout
+	case stderr <= half:
+		return budget - stderr, stderr
+	default:
+		return half, budget - half
+	}
+}
+
+// truncateOutput cuts s to at most n bytes without splitting a UTF-8 sequence,
+// reporting whether anything was cut.
+func truncateOutput(s *string, n int) (*string, bool) {
+	if s == nil || len(*s) <= n {
+		return s, false
+	}
+	i := n
+	for i > 0 && i > n-utf8.UTFMax && !utf8.RuneStart((*s)[i]) {
+		i--
+	}
+	cut := (*s)[:i]
+	return &cut, true
+}
+
+func (m *Manager) deliver(d delivery) {
+	WebhookInflight.Inc()
+	defer WebhookInflight.Dec()
+	if err := m.sender.Notify(d.ctx, d.url, d.event); err != nil {
+		slog.Warn("webhook delivery failed", "job_id", d.event.JobID, "status", d.event.Status, "error", err)
+	}
+}
+
+func webhookShard(jobID string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(jobID))
+	return int(h.Sum32() % uint32(n))
+}
+
+// decodeStreams returns the writers a run's stdout and stderr are streamed
+// through. For a job declaring a charset they decode it, each on its own, so
+// the stream carries UTF-8; flush passes on any character held back at the end.
+func decodeStreams(stdout, stderr io.Writer, encoding string) (io.Writer, io.Writer, func()) {
+	out := executor.DecodingWriter(stdout, encoding)
+	if out == nil {
+		return stdout, stderr, func() {}
+	}
+	errs := executor.DecodingWriter(stderr, encoding)
+	return out, errs, func() {
+		out.Close()
+		errs.Close()
+	}
 }
 
 type logStreamWriter struct {
@@ -0,0 +1,991 @@
+package jobs
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/paulgrammer/childprocess/internal/executor"
+	"github.com/paulgrammer/childprocess/internal/webhook"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fakeRunner struct{}
+
+func (fakeRunner) Run(ctx context.Context, jobID string, command string, args []string, workingDir string, stdout, stderr io.Writer, opts ...executor.RunOption) (*executor.ExecutionResult, error) {
+	return &executor.ExecutionResult{JobID: jobID, Stdout: "ok\n"}, nil
+}
+
+type nopSender struct{}
+
+func (nopSender) Notify(ctx context.Context, url string, event webhook.Event) error { return nil }
+
+func newTestManager(t *testing.T, runner executor.Runner) *Manager {
+	t.Helper()
+	m, err := NewManager(1, NewInMemoryStore(), nopSender{}, runner, NewLogStreamer())
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(m.Stop)
+	return m
+}
+
+func waitForStatus(t *testing.T, m *Manager, id string, want JobStatus) Job {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if j, ok := m.Get(id); ok && j.Status == want {
+			return j
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	j, _ := m.Get(id)
+	t.Fatalf("job %s did not reach %s, last status %q", id, want, j.Status)
+	return Job{}
+}
+
+func TestManager_RetryCompletedJob(t *testing.T) {
+	m := newTestManager(t, fakeRunner{})
+	ctx := context.Background()
+
+	id, err := m.Submit(ctx, CreateJobRequest{Command: "echo", Args: []string{"hi"}, Metadata: map[string]string{"k": "v"}})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	waitForStatus(t, m, id, JobStatusCompleted)
+
+	retryID, err := m.Retry(ctx, id)
+	if err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+	if retryID == id {
+		t.Fatalf("expected a distinct job id for the retry")
+	}
+	retried := waitForStatus(t, m, retryID, JobStatusCompleted)
+	if retried.Command != "echo" || len(retried.Args) != 1 || retried.Args[0] != "hi" {
+		t.Fatalf("retry did not copy the command spec: %+v", retried)
+	}
+	if retried.RetryOf != id {
+		t.Fatalf("expected retry_of %q, got %q", id, retried.RetryOf)
+	}
+	if retried.Metadata["k"] != "v" {
+		t.Fatalf("expected metadata to be copied, got %v", retried.Metadata)
+	}
+
+	if _, err := m.Retry(ctx, "missing"); err != ErrJobNotFound {
+		t.Fatalf("expected ErrJobNotFound, got %v", err)
+	}
+}
+
+func TestManager_HealthReflectsStop(t *testing.T) {
+	m := newTestManager(t, fakeRunner{})
+
+	h := m.Health()
+	if !h.Ready || h.Workers != 1 || h.QueueCapacity == 0 {
+		t.Fatalf("expected a ready manager, got %+v", h)
+	}
+
+	m.Stop()
+	if h := m.Health(); h.Ready || !h.Stopped {
+		t.Fatalf("expected a stopped manager to be not ready, got %+v", h)
+	}
+}
+
+type countingSender struct {
+	mu        sync.Mutex
+	inflight  int
+	max       int
+	delivered int
+}
+
+func (s *countingSender) Notify(ctx context.Context, url string, event webhook.Event) error {
+	s.mu.Lock()
+	s.inflight++
+	if s.inflight > s.max {
+		s.max = s.inflight
+	}
+	s.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	s.mu.Lock()
+	s.inflight--
+	s.delivered++
+	s.mu.Unlock()
+	return nil
+}
+
+func TestManager_WebhookConcurrencyLimit(t *testing.T) {
+	const limit = 2
+	sender := &countingSender{}
+	m, err := NewManager(4, NewInMemoryStore(), sender, fakeRunner{}, NewLogStreamer(), WithWebhookConcurrency(limit))
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+
+	const n = 20
+	for i := 0; i < n; i++ {
+		if _, err := m.Submit(context.Background(), CreateJobRequest{Command: "echo", WebhookURL: "http://example.invalid"}); err != nil {
+			t.Fatalf("submit failed: %v", err)
+		}
+	}
+	m.Stop()
+
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	if sender.max > limit {
+		t.Fatalf("expected at most %d concurrent deliveries, saw %d", limit, sender.max)
+	}
+	// queued, in_progress and completed per job
+	if sender.delivered != 3*n {
+		t.Fatalf("expected %d deliveries, got %d", 3*n, sender.delivered)
+	}
+}
+
+// blockingRunner holds every job until release is closed.
+type blockingRunner struct {
+	started chan string
+	release chan struct{}
+}
+
+func newBlockingRunner() *blockingRunner {
+	return &blockingRunner{started: make(chan string, 16), release: make(chan struct{})}
+}
+
+func (b *blockingRunner) Run(ctx context.Context, jobID string, command string, args []string, workingDir string, stdout, stderr io.Writer, opts ...executor.RunOption) (*executor.ExecutionResult, error) {
+	b.started <- jobID
+	<-b.release
+	return &executor.ExecutionResult{JobID: jobID}, nil
+}
+
+func TestManager_QueueEnumerationAndFlush(t *testing.T) {
+	runner := newBlockingRunner()
+	m := newTestManager(t, runner)
+	ctx := context.Background()
+
+	running, err := m.Submit(ctx, CreateJobRequest{Command: "sleep"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	<-runner.started
+
+	var queued []string
+	for i := 0; i < 3; i++ {
+		id, err := m.Submit(ctx, CreateJobRequest{Command: "sleep"})
+		if err != nil {
+			t.Fatalf("submit failed: %v", err)
+		}
+		queued = append(queued, id)
+	}
+
+	if got := m.QueuedIDs(); !reflect.DeepEqual(got, queued) {
+		t.Fatalf("QueuedIDs = %v, want %v", got, queued)
+	}
+
+	flushed := m.FlushQueue(ctx)
+	if !reflect.DeepEqual(flushed, queued) {
+		t.Fatalf("FlushQueue = %v, want %v", flushed, queued)
+	}
+	if got := m.QueuedIDs(); len(got) != 0 {
+		t.Fatalf("expected empty queue after flush, got %v", got)
+	}
+	for _, id := range queued {
+		j, _ := m.Get(id)
+		if j.Status != JobStatusFailed || j.Error != "flushed" {
+			t.Fatalf("flushed job %s: status %q error %q", id, j.Status, j.Error)
+		}
+	}
+
+	close(runner.release)
+	waitForStatus(t, m, running, JobStatusCompleted)
+	select {
+	case id := <-runner.started:
+		t.Fatalf("flushed job %s was executed", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestManager_Stats(t *testing.T) {
+	runner := newBlockingRunner()
+	m := newTestManager(t, runner)
+	ctx := context.Background()
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		id, err := m.Submit(ctx, CreateJobRequest{Command: "sleep"})
+		if err != nil {
+			t.Fatalf("submit failed: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	<-runner.started
+	waitForStatus(t, m, ids[0], JobStatusInProgress)
+
+	s, err := m.Stats()
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if want := (Stats{Queued: 2, InProgress: 1, QueueDepth: 2}); !reflect.DeepEqual(s, want) {
+		t.Fatalf("Stats = %+v, want %+v", s, want)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	close(runner.release)
+	for _, id := range ids {
+		waitForStatus(t, m, id, JobStatusCompleted)
+	}
+	s, _ = m.Stats()
+	if s.Completed != 3 || s.Queued != 0 || s.InProgress != 0 || s.QueueDepth != 0 {
+		t.Fatalf("expected 3 completed and nothing pending, got %+v", s)
+	}
+	if s.AvgDurationMs <= 0 {
+		t.Fatalf("expected a positive average duration, got %v", s.AvgDurationMs)
+	}
+}
+
+type recordingSender struct {
+	mu     sync.Mutex
+	events []webhook.Event
+}
+
+func (s *recordingSender) Notify(ctx context.Context, url string, event webhook.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *recordingSender) byStatus() map[string]Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]Job, len(s.events))
+	for _, ev := range s.events {
+		out[ev.Status] = ev.Data.(Job)
+	}
+	return out
+}
+
+func TestManager_IncludeOutputInWebhook(t *testing.T) {
+	sender := &recordingSender{}
+	m, err := NewManager(1, NewInMemoryStore(), sender, fakeRunner{}, NewLogStreamer())
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+
+	ctx := context.Background()
+	withOutput, err := m.Submit(ctx, CreateJobRequest{Command: "echo", WebhookURL: "http://hook.example/a", IncludeOutputInWebhook: true})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	waitForStatus(t, m, withOutput, JobStatusCompleted)
+	m.Stop() // drains pending webhook deliveries
+
+	events := sender.byStatus()
+	if j := events[string(JobStatusQueued)]; j.Stdout != nil || j.ExitCode != nil {
+		t.Fatalf("expected no output on the queued event, got %+v", j)
+	}
+	done := events[string(JobStatusCompleted)]
+	if done.Stdout == nil || *done.Stdout != "ok\n" || done.ExitCode == nil || *done.ExitCode != 0 {
+		t.Fatalf("expected output on the completed event, got %+v", done)
+	}
+}
+
+func TestManager_WebhookOmitsOutputByDefault(t *testing.T) {
+	sender := &recordingSender{}
+	m, err := NewManager(1, NewInMemoryStore(), sender, fakeRunner{}, NewLogStreamer())
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+
+	id, err := m.Submit(context.Background(), CreateJobRequest{Command: "echo", WebhookURL: "http://hook.example/b"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	waitForStatus(t, m, id, JobStatusCompleted)
+	m.Stop()
+
+	if j := sender.byStatus()[string(JobStatusCompleted)]; j.ID != id || j.Stdout != nil || j.ExitCode == nil {
+		t.Fatalf("expected completed event with exit code but without output, got %+v", j)
+	}
+}
+
+func TestManager_WebhookEventsFilter(t *testing.T) {
+	sender := &recordingSender{}
+	m, err := NewManager(1, NewInMemoryStore(), sender, fakeRunner{}, NewLogStreamer())
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	ctx := context.Background()
+
+	id, err := m.Submit(ctx, CreateJobRequest{Command: "echo", WebhookURL: "http://hook.example/e", WebhookEvents: []string{"completed", "failed"}})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	waitForStatus(t, m, id, JobStatusCompleted)
+	m.Stop()
+
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	if len(sender.events) != 1 || sender.events[0].Status != string(JobStatusCompleted) {
+		t.Fatalf("expected only the completed webhook, got %+v", sender.events)
+	}
+
+	var fe FieldErrors
+	if err := (CreateJobRequest{Command: "echo", WebhookEvents: []string{"completed", "started"}}).Validate(false); !errors.As(err, &fe) || fe["webhook_events"] == "" {
+		t.Fatalf("expected an unknown event to be rejected, got %v", err)
+	}
+}
+
+func TestManager_WebhookOutputPolicy(t *testing.T) {
+	result := &executor.ExecutionResult{Stdout: "héllo world\n", Stderr: "warn\n", StdoutBytes: 13, Duration: 1500 * time.Millisecond}
+	run := func(opts ...ManagerOption) (webhookJob, storedJob Job) {
+		sender := &recordingSender{}
+		m, err := NewManager(1, NewInMemoryStore(), sender, optsRunner{got: make(chan executor.RunOptions, 1), result: result}, NewLogStreamer(), opts...)
+		if err != nil {
+			t.Fatalf("failed to create manager: %v", err)
+		}
+		id, err := m.Submit(context.Background(), CreateJobRequest{Command: "echo", WebhookURL: "http://hook.example/d", IncludeOutputInWebhook: true})
+		if err != nil {
+			t.Fatalf("submit failed: %v", err)
+		}
+		storedJob = waitForStatus(t, m, id, JobStatusCompleted)
+		m.Stop()
+		return sender.byStatus()[string(JobStatusCompleted)], storedJob
+	}
+
+	// Cut on a rune boundary: "hé" is 3 bytes, so a 2-byte cap keeps only "h"
+	j, stored := run(WithWebhookOutputLimit(2))
+	if j.Stdout == nil || *j.Stdout != "h" || j.Stderr == nil || *j.Stderr != "wa" || !j.Truncated {
+		t.Fatalf("expected truncated output, got %+v", j)
+	}
+	if j.ExitCode == nil || j.DurationMs != 1500 {
+		t.Fatalf("expected exit code and duration, got %+v", j)
+	}
+	if *stored.Stdout != result.Stdout || stored.Truncated {
+		t.Fatalf("expected the stored job to keep its output, got %+v", stored)
+	}
+
+	j, _ = run(WithoutWebhookOutput(), WithWebhookOutputLimit(2))
+	if j.Stdout != nil || j.Stderr != nil || j.ExitCode == nil || j.DurationMs != 1500 {
+		t.Fatalf("expected exit code and duration without output, got %+v", j)
+	}
+}
+
+func TestManager_WebhookMaxPayload(t *testing.T) {
+	// Newlines and angle brackets take more than a byte each once JSON-escaped
+	stdout := strings.Repeat("<line é>\n", 20000)
+	sender := &recordingSender{}
+	runner := optsRunner{got: make(chan executor.RunOptions, 1), result: &executor.ExecutionResult{Stdout: stdout, Stderr: "warn\n"}}
+	m, err := NewManager(1, NewInMemoryStore(), sender, runner, NewLogStreamer(), WithWebhookMaxPayload(4096))
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+
+	id, err := m.Submit(context.Background(), CreateJobRequest{Command: "echo", WebhookURL: "http://hook.example/e", IncludeOutputInWebhook: true})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	stored := waitForStatus(t, m, id, JobStatusCompleted)
+	m.Stop()
+
+	var event webhook.Event
+	for _, ev := range sender.events {
+		if ev.Status == string(JobStatusCompleted) {
+			event = ev
+		}
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if len(body) > 4096 || len(body) < 4000 || !event.OutputTruncated {
+		t.Fatalf("expected a flagged payload of nearly 4096 bytes, got %d bytes, output_truncated %v", len(body), event.OutputTruncated)
+	}
+	j := event.Data.(Job)
+	if j.Stdout == nil || *j.Stdout == "" || !strings.HasPrefix(stdout, *j.Stdout) || j.Stderr == nil || *j.Stderr != "warn\n" || !j.Truncated {
+		t.Fatalf("expected the head of stdout and all of the short stderr, got %+v", j)
+	}
+	if *stored.Stdout != stdout || stored.Truncated {
+		t.Fatal("expected the stored job to keep its output")
+	}
+}
+
+func TestManager_RedactOutputKeepsOnlySizes(t *testing.T) {
+	sender := &recordingSender{}
+	runner := optsRunner{got: make(chan executor.RunOptions, 1), result: &executor.ExecutionResult{Stdout: "secret\n", StdoutBytes: 7}}
+	m, err := NewManager(1, NewInMemoryStore(), sender, runner, NewLogStreamer())
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+
+	id, err := m.Submit(context.Background(), CreateJobRequest{Command: "echo", WebhookURL: "http://hook.example/c", IncludeOutputInWebhook: true, RedactOutput: true})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	if o := <-runner.got; !o.Sensitive {
+		t.Fatal("expected a redacted job to run as sensitive")
+	}
+	j := waitForStatus(t, m, id, JobStatusCompleted)
+	m.Stop()
+
+	if j.Stdout != nil || j.StdoutBytes != 7 {
+		t.Fatalf("expected stored job with size but no output, got stdout %v, %d bytes", j.Stdout, j.StdoutBytes)
+	}
+	if done := sender.byStatus()[string(JobStatusCompleted)]; done.Stdout != nil || done.ExitCode == nil {
+		t.Fatalf("expected completed event with exit code but no output, got %+v", done)
+	}
+}
+
+// optsRunner records the RunOptions each job was started with.
+type optsRunner struct {
+	got    chan executor.RunOptions
+	result *executor.ExecutionResult // returned if set
+}
+
+func (r optsRunner) Run(ctx context.Context, jobID string, command string, args []string, workingDir string, stdout, stderr io.Writer, opts ...executor.RunOption) (*executor.ExecutionResult, error) {
+	var o executor.RunOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	r.got <- o
+	if r.result != nil {
+		return r.result, nil
+	}
+	return &executor.ExecutionResult{JobID: jobID}, nil
+}
+
+func TestManager_PassesNiceToRunner(t *testing.T) {
+	runner := optsRunner{got: make(chan executor.RunOptions, 1)}
+	m := newTestManager(t, runner)
+
+	id, err := m.Submit(context.Background(), CreateJobRequest{Command: "echo", Nice: 10})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	select {
+	case o := <-runner.got:
+		if o.Nice != 10 {
+			t.Fatalf("expected nice 10, got %d", o.Nice)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("runner was not called")
+	}
+	if j := waitForStatus(t, m, id, JobStatusCompleted); j.Nice != 10 {
+		t.Fatalf("expected job to record nice 10, got %d", j.Nice)
+	}
+}
+
+func TestManager_UpdatedAtFollowsTransitions(t *testing.T) {
+	runner := newBlockingRunner()
+	m := newTestManager(t, runner)
+
+	id, err := m.Submit(context.Background(), CreateJobRequest{Command: "sleep"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	<-runner.started
+	running := waitForStatus(t, m, id, JobStatusInProgress)
+	if running.UpdatedAt.Before(*running.StartedAt) {
+		t.Fatalf("UpdatedAt %v is before StartedAt %v", running.UpdatedAt, *running.StartedAt)
+	}
+
+	close(runner.release)
+	done := waitForStatus(t, m, id, JobStatusCompleted)
+	if !done.UpdatedAt.After(running.UpdatedAt) || done.UpdatedAt.Before(*done.CompletedAt) {
+		t.Fatalf("UpdatedAt %v did not advance past %v to completion at %v", done.UpdatedAt, running.UpdatedAt, *done.CompletedAt)
+	}
+}
+
+// pidRunner reports a fake PID and then holds the job until release is closed.
+type pidRunner struct {
+	*blockingRunner
+}
+
+func (r pidRunner) Run(ctx context.Context, jobID string, command string, args []string, workingDir string, stdout, stderr io.Writer, opts ...executor.RunOption) (*executor.ExecutionResult, error) {
+	var o executor.RunOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.OnStart(4242)
+	return r.blockingRunner.Run(ctx, jobID, command, args, workingDir, stdout, stderr)
+}
+
+func TestManager_RecordsPIDWhileRunning(t *testing.T) {
+	runner := pidRunner{newBlockingRunner()}
+	m := newTestManager(t, runner)
+
+	id, err := m.Submit(context.Background(), CreateJobRequest{Command: "sleep"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	<-runner.started
+	if j, _ := m.Get(id); j.PID != 4242 {
+		t.Fatalf("expected pid 4242 while running, got %d", j.PID)
+	}
+
+	close(runner.release)
+	if j := waitForStatus(t, m, id, JobStatusCompleted); j.PID != 0 {
+		t.Fatalf("expected pid cleared after completion, got %d", j.PID)
+	}
+}
+
+func TestManager_SubmitRejectsWhenQueueFull(t *testing.T) {
+	runner := newBlockingRunner()
+	m, err := NewManager(1, NewInMemoryStore(), nopSender{}, runner, NewLogStreamer(), WithQueueSize(1))
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	defer m.Stop()
+	defer close(runner.release)
+	ctx := context.Background()
+
+	if _, err := m.Submit(ctx, CreateJobRequest{Command: "sleep"}); err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	<-runner.started
+	if _, err := m.Submit(ctx, CreateJobRequest{Command: "sleep"}); err != nil {
+		t.Fatalf("submit into the last slot failed: %v", err)
+	}
+	if _, err := m.Submit(ctx, CreateJobRequest{Command: "sleep"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	if s, _ := m.Stats(); s.Queued+s.InProgress != 2 {
+		t.Fatalf("expected the rejected job not to be stored, got %+v", s)
+	}
+}
+
+func TestManager_DeleteFinishedJob(t *testing.T) {
+	sink, err := NewFileLogSink(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	runner := newBlockingRunner()
+	m, err := NewManager(1, NewInMemoryStore(), nopSender{}, runner, NewLogStreamer(WithLogSink(sink)))
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	defer m.Stop()
+	ctx := context.Background()
+
+	id, err := m.Submit(ctx, CreateJobRequest{Command: "sleep"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	<-runner.started
+	if err := m.Delete(ctx, id); !errors.Is(err, ErrJobNotFinished) {
+		t.Fatalf("expected ErrJobNotFinished for a running job, got %v", err)
+	}
+	close(runner.release)
+	waitForStatus(t, m, id, JobStatusCompleted)
+
+	active := testutil.ToFloat64(JobsActive)
+	if err := m.Delete(ctx, id); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, ok := m.Get(id); ok {
+		t.Fatal("expected the job to be gone")
+	}
+	if _, err := sink.Open(id); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected the log to be removed, got %v", err)
+	}
+	if got := testutil.ToFloat64(JobsActive); got != active-1 {
+		t.Fatalf("expected jobs_active to drop to %v, got %v", active-1, got)
+	}
+	if err := m.Delete(ctx, id); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected ErrJobNotFound, got %v", err)
+	}
+}
+
+func TestManager_OutputEncodingLatin1(t *testing.T) {
+	sink, err := NewFileLogSink(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	runner := executor.NewExecRunner(executor.WithExecutorConfig(&executor.ExecutorConfig{CaptureOutput: true, StreamOutput: true}))
+	m, err := NewManager(1, NewInMemoryStore(), nopSender{}, runner, NewLogStreamer(WithLogSink(sink)))
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	defer m.Stop()
+
+	// "café" and "naïve" in ISO-8859-1
+	script := `printf 'caf\351\n'; printf 'na\357ve\n' >&2`
+	id, err := m.Submit(context.Background(), CreateJobRequest{Command: "sh", Args: []string{"-c", script}, OutputEncoding: "latin1"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	job := waitForStatus(t, m, id, JobStatusCompleted)
+	if job.Stdout == nil || *job.Stdout != "café\n" || job.Stderr == nil || *job.Stderr != "naïve\n" {
+		t.Fatalf("expected output decoded to UTF-8, got %+v", job)
+	}
+	archive, err := sink.Open(id)
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	defer archive.Close()
+	logged, _ := io.ReadAll(archive)
+	if !strings.Contains(string(logged), "café\n") || !utf8.Valid(logged) {
+		t.Fatalf("expected the streamed output decoded too, got %q", logged)
+	}
+
+	id, err = m.Submit(context.Background(), CreateJobRequest{Command: "sh", Args: []string{"-c", `printf 'caf\351'`}, OutputEncoding: "raw"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	job = waitForStatus(t, m, id, JobStatusCompleted)
+	if job.Stdout == nil || *job.Stdout != base64.StdEncoding.EncodeToString([]byte("caf\351")) {
+		t.Fatalf("expected raw output stored as base64, got %+v", job)
+	}
+}
+
+func TestManager_ArgLimits(t *testing.T) {
+	m, err := NewManager(1, NewInMemoryStore(), nopSender{}, fakeRunner{}, NewLogStreamer(), WithArgLimits(ArgLimits{Count: 2, TotalBytes: 8}))
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	defer m.Stop()
+	ctx := context.Background()
+
+	if _, err := m.Submit(ctx, CreateJobRequest{Command: "echo", Args: []string{"1234", "5678"}}); err != nil {
+		t.Fatalf("expected args within the limits to be accepted, got %v", err)
+	}
+	var fe FieldErrors
+	for _, args := range [][]string{{"a", "b", "c"}, {"12345", "6789"}} {
+		_, err := m.Submit(ctx, CreateJobRequest{Command: "echo", Args: args})
+		if !errors.Is(err, ErrInvalidRequest) || !errors.As(err, &fe) || fe["args"] == "" {
+			t.Errorf("args %q: expected an args error, got %v", args, err)
+		}
+	}
+}
+
+func TestManager_SubmitRejectsBeyondMaxJobs(t *testing.T) {
+	runner := newBlockingRunner()
+	m, err := NewManager(1, NewInMemoryStore(), nopSender{}, runner, NewLogStreamer(), WithMaxJobs(2))
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	defer m.Stop()
+	defer close(runner.release)
+	ctx := context.Background()
+
+	if _, err := m.Submit(ctx, CreateJobRequest{ID: "running", Command: "sleep"}); err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	<-runner.started
+	queued, err := m.Submit(ctx, CreateJobRequest{Command: "sleep"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	// The running job counts against the cap, though the queue has room
+	if _, err := m.Submit(ctx, CreateJobRequest{Command: "sleep"}); !errors.Is(err, ErrTooManyJobs) {
+		t.Fatalf("expected ErrTooManyJobs, got %v", err)
+	}
+
+	// Finishing a job frees its slot; a rejected duplicate does not take one
+	if err := m.Cancel(ctx, queued); err != nil {
+		t.Fatalf("cancel failed: %v", err)
+	}
+	if _, err := m.Submit(ctx, CreateJobRequest{ID: "running", Command: "sleep"}); !errors.Is(err, ErrJobExists) {
+		t.Fatalf("expected ErrJobExists, got %v", err)
+	}
+	if _, err := m.Submit(ctx, CreateJobRequest{Command: "sleep"}); err != nil {
+		t.Fatalf("expected a freed slot to be reusable, got %v", err)
+	}
+}
+
+func TestManager_ExpiresJobsThatWaitedTooLong(t *testing.T) {
+	runner := newCtxRunner()
+	m := newTestManager(t, runner)
+	ctx := context.Background()
+
+	// Saturate the only worker so the next job has to wait
+	if _, err := m.Submit(ctx, CreateJobRequest{Command: "sleep"}); err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	<-runner.started
+	id, err := m.Submit(ctx, CreateJobRequest{Command: "true", MaxQueueWaitSeconds: 1})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	// Age the job past its wait instead of sleeping through it
+	job, _ := m.store.Get(id)
+	job.CreatedAt = job.CreatedAt.Add(-2 * time.Second)
+	if err := m.store.Update(job); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	before := testutil.ToFloat64(JobsExpiredInQueueTotal)
+	close(runner.release)
+	got := waitForStatus(t, m, id, JobStatusFailed)
+	if got.Error != "queue wait exceeded" || got.StartedAt != nil {
+		t.Fatalf("expected the job to fail without starting, got %+v", got)
+	}
+	if n := testutil.ToFloat64(JobsExpiredInQueueTotal) - before; n != 1 {
+		t.Fatalf("jobs_expired_in_queue_total advanced by %v, want 1", n)
+	}
+	select {
+	case started := <-runner.started:
+		t.Fatalf("expired job %s reached the runner", started)
+	default:
+	}
+}
+
+func TestManager_PauseHoldsQueuedJobsUntilResume(t *testing.T) {
+	m, err := NewManager(2, NewInMemoryStore(), nopSender{}, fakeRunner{}, NewLogStreamer())
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(m.Stop)
+	ctx := context.Background()
+
+	m.Pause()
+	if !m.Paused() || !m.Health().Paused {
+		t.Fatal("expected the pool to report paused")
+	}
+	var ids []string
+	for i := 0; i < 3; i++ {
+		id, err := m.Submit(ctx, CreateJobRequest{Command: "echo"})
+		if err != nil {
+			t.Fatalf("submit while paused failed: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	time.Sleep(50 * time.Millisecond)
+	for _, id := range ids {
+		if job, _ := m.Get(id); job.Status != JobStatusQueued {
+			t.Fatalf("job %s ran while paused: %s", id, job.Status)
+		}
+	}
+
+	m.Resume()
+	for _, id := range ids {
+		waitForStatus(t, m, id, JobStatusCompleted)
+	}
+}
+
+func TestManager_StopReleasesPausedPool(t *testing.T) {
+	m := newTestManager(t, fakeRunner{})
+	m.Pause()
+	if _, err := m.Submit(context.Background(), CreateJobRequest{Command: "echo"}); err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		m.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stop hung on a paused pool")
+	}
+}
+
+type commandRunner struct {
+	mu   sync.Mutex
+	seen []string
+}
+
+func (r *commandRunner) Run(ctx context.Context, jobID string, command string, args []string, workingDir string, stdout, stderr io.Writer, opts ...executor.RunOption) (*executor.ExecutionResult, error) {
+	r.mu.Lock()
+	r.seen = append(r.seen, command)
+	r.mu.Unlock()
+	return &executor.ExecutionResult{JobID: jobID}, nil
+}
+
+func TestManager_PreExecHookRejectsJobs(t *testing.T) {
+	runner := &commandRunner{}
+	var checked []string
+	logHook := func(ctx context.Context, job *Job) error {
+		checked = append(checked, job.Command)
+		return nil
+	}
+	denyRm := func(ctx context.Context, job *Job) error {
+		if job.Command == "rm" {
+			return errors.New("policy denied: rm is not allowed")
+		}
+		return nil
+	}
+	m, err := NewManager(1, NewInMemoryStore(), nopSender{}, runner, NewLogStreamer(), WithPreExecHook(logHook), WithPreExecHook(denyRm))
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(m.Stop)
+	ctx := context.Background()
+
+	denied, err := m.Submit(ctx, CreateJobRequest{Command: "rm", Args: []string{"-rf", "/tmp/x"}})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	got := waitForStatus(t, m, denied, JobStatusFailed)
+	if got.Error != "policy denied: rm is not allowed" || got.StartedAt != nil || got.CompletedAt == nil {
+		t.Fatalf("expected the job to fail without starting, got %+v", got)
+	}
+
+	allowed, err := m.Submit(ctx, CreateJobRequest{Command: "echo"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	waitForStatus(t, m, allowed, JobStatusCompleted)
+
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	if !reflect.DeepEqual(runner.seen, []string{"echo"}) {
+		t.Fatalf("expected only echo to reach the runner, got %v", runner.seen)
+	}
+	// The hook ahead of the denying one still saw every job, in order
+	if !reflect.DeepEqual(checked, []string{"rm", "echo"}) {
+		t.Fatalf("expected the first hook to check every job, got %v", checked)
+	}
+}
+
+type fakeUploader struct {
+	mu   sync.Mutex
+	got  map[string]string
+	fail error
+}
+
+func (u *fakeUploader) Upload(ctx context.Context, jobID, stdout, stderr string) (string, error) {
+	if u.fail != nil {
+		return "", u.fail
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.got[jobID] = stdout
+	return "s3://bucket/" + jobID + ".json", nil
+}
+
+func TestManager_UploadsOutputAfterJobEnds(t *testing.T) {
+	uploader := &fakeUploader{got: make(map[string]string)}
+	m, err := NewManager(1, NewInMemoryStore(), nopSender{}, fakeRunner{}, NewLogStreamer(), WithOutputUploader(uploader))
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	ctx := context.Background()
+	id, err := m.Submit(ctx, CreateJobRequest{Command: "echo"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	redacted, err := m.Submit(ctx, CreateJobRequest{Command: "echo", RedactOutput: true})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	waitForStatus(t, m, redacted, JobStatusCompleted)
+	// Stop waits for uploads in flight
+	m.Stop()
+
+	job, _ := m.Get(id)
+	if job.Status != JobStatusCompleted || job.OutputURL != "s3://bucket/"+id+".json" || job.OutputUploadError != "" {
+		t.Fatalf("expected the output URL on the completed job, got %+v", job)
+	}
+	uploader.mu.Lock()
+	defer uploader.mu.Unlock()
+	if uploader.got[id] != "ok\n" {
+		t.Fatalf("uploaded stdout = %q, want %q", uploader.got[id], "ok\n")
+	}
+	if _, ok := uploader.got[redacted]; ok {
+		t.Fatalf("expected redact_output jobs not to be uploaded")
+	}
+}
+
+func TestManager_FailedUploadIsRecordedNotFatal(t *testing.T) {
+	uploader := &fakeUploader{fail: errors.New("access denied")}
+	m, err := NewManager(1, NewInMemoryStore(), nopSender{}, fakeRunner{}, NewLogStreamer(), WithOutputUploader(uploader))
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	before := testutil.ToFloat64(OutputUploadsFailedTotal)
+	id, err := m.Submit(context.Background(), CreateJobRequest{Command: "echo"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	waitForStatus(t, m, id, JobStatusCompleted)
+	m.Stop()
+
+	job, _ := m.Get(id)
+	if job.Status != JobStatusCompleted || job.OutputUploadError != "access denied" || job.OutputURL != "" {
+		t.Fatalf("expected a completed job with the upload error, got %+v", job)
+	}
+	if n := testutil.ToFloat64(OutputUploadsFailedTotal) - before; n != 1 {
+		t.Fatalf("jobs_output_uploads_failed_total advanced by %v, want 1", n)
+	}
+}
+
+func TestManager_RejectControlChars(t *testing.T) {
+	m, err := NewManager(1, NewInMemoryStore(), nopSender{}, fakeRunner{}, NewLogStreamer(), WithRejectControlChars())
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(m.Stop)
+	ctx := context.Background()
+
+	if _, err := m.Submit(ctx, CreateJobRequest{Command: "echo", Args: []string{"a b", "c\td"}}); err != nil {
+		t.Fatalf("expected spaces and tabs to be accepted, got %v", err)
+	}
+	var fe FieldErrors
+	_, err = m.Submit(ctx, CreateJobRequest{Command: "echo", Args: []string{"line one\nline two"}})
+	if !errors.Is(err, ErrInvalidRequest) || !errors.As(err, &fe) || fe["args"] == "" {
+		t.Fatalf("expected a control character error, got %v", err)
+	}
+
+	// Without the option only null bytes are refused
+	if _, err := newTestManager(t, fakeRunner{}).Submit(ctx, CreateJobRequest{Command: "echo", Args: []string{"a\nb"}}); err != nil {
+		t.Fatalf("expected newlines to be accepted by default, got %v", err)
+	}
+}
+
+// panickingRunner dereferences a nil result for the command "panic", like a
+// buggy custom Runner, and otherwise succeeds.
+type panickingRunner struct{}
+
+func (panickingRunner) Run(ctx context.Context, jobID string, command string, args []string, workingDir string, stdout, stderr io.Writer, opts ...executor.RunOption) (*executor.ExecutionResult, error) {
+	if command == "panic" {
+		var result *executor.ExecutionResult
+		return &executor.ExecutionResult{JobID: result.JobID}, nil
+	}
+	return &executor.ExecutionResult{JobID: jobID}, nil
+}
+
+func TestManager_PanickingRunnerFailsJobAndKeepsWorker(t *testing.T) {
+	m := newTestManager(t, panickingRunner{})
+	panickedBefore := testutil.ToFloat64(JobsPanickedTotal)
+	inProgressBefore := testutil.ToFloat64(JobsInProgress)
+
+	id, err := m.Submit(context.Background(), CreateJobRequest{Command: "panic"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	select {
+	case <-m.runs.wait(id):
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the run to be released")
+	}
+	// Waiters are released only once the job is marked failed
+	j, _ := m.Get(id)
+	if j.Status != JobStatusFailed || !strings.HasPrefix(j.Error, ErrJobPanicked.Error()) || !strings.Contains(j.Error, "nil pointer") {
+		t.Fatalf("expected a panic failure, got %+v", j)
+	}
+	if got := testutil.ToFloat64(JobsPanickedTotal); got != panickedBefore+1 {
+		t.Fatalf("expected jobs_panicked_total to grow by 1, got %v", got-panickedBefore)
+	}
+	if got := testutil.ToFloat64(JobsInProgress); got != inProgressBefore {
+		t.Fatalf("expected jobs_in_progress back to %v, got %v", inProgressBefore, got)
+	}
+
+	// The pool's only worker survived to run the next job
+	next, err := m.Submit(context.Background(), CreateJobRequest{Command: "echo"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	waitForStatus(t, m, next, JobStatusCompleted)
+}
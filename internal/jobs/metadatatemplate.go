@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// metadataPlaceholder matches {{.Metadata.key}} in a job's command and args.
+var metadataPlaceholder = regexp.MustCompile(`\{\{\s*\.Metadata\.([A-Za-z0-9_]+)\s*\}\}`)
+
+// expandMetadata fills {{.Metadata.key}} placeholders in the command and args
+// from the request's metadata. This is plain substitution, not a template
+// engine: any other {{ action, missing keys and unterminated placeholders are
+// field errors. Expanded values are passed to the command as they are, never
+// through a shell, but they may not contain control characters and a
+// placeholder leading an argument must not produce an option.
+func expandMetadata(command string, args []string, metadata map[string]string) (string, []string, error) {
+	fe := FieldErrors{}
+
+	expanded, err := expandMetadataValue(command, metadata)
+	if err != nil {
+		fe["command"] = err.Error()
+	}
+	out := make([]string, len(args))
+	for i, a := range args {
+		v, err := expandMetadataValue(a, metadata)
+		switch {
+		case err != nil:
+			fe[fmt.Sprintf("args[%d]", i)] = err.Error()
+		case strings.HasPrefix(strings.TrimSpace(a), "{{") && strings.HasPrefix(v, "-"):
+			fe[fmt.Sprintf("args[%d]", i)] = "must not expand to an option starting with '-'"
+		}
+		out[i] = v
+	}
+	if len(fe) > 0 {
+		return "", nil, fe
+	}
+	return expanded, out, nil
+}
+
+// expandMetadataValue substitutes metadata into s, giving up as soon as the
+// result would pass MaxArgLength.
+func expandMetadataValue(s string, metadata map[string]string) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	var b strings.Builder
+	write := func(part string) error {
+		if b.Len()+len(part) > MaxArgLength {
+			return fmt.Errorf("expands to more than %d bytes", MaxArgLength)
+		}
+		b.WriteString(part)
+		return nil
+	}
+	last := 0
+	for _, m := range metadataPlaceholder.FindAllStringSubmatchIndex(s, -1) {
+		literal := s[last:m[0]]
+		if strings.Contains(literal, "{{") {
+			return "", fmt.Errorf("invalid template: only {{.Metadata.key}} placeholders are supported")
+		}
+		key := s[m[2]:m[3]]
+		value, ok := metadata[key]
+		if !ok {
+			return "", fmt.Errorf("cannot expand template: metadata has no key %q", key)
+		}
+		if err := write(literal); err != nil {
+			return "", err
+		}
+		if err := write(value); err != nil {
+			return "", err
+		}
+		last = m[1]
+	}
+	if strings.Contains(s[last:], "{{") {
+		return "", fmt.Errorf("invalid template: only {{.Metadata.key}} placeholders are supported")
+	}
+	if err := write(s[last:]); err != nil {
+		return "", err
+	}
+	v := b.String()
+	if strings.ContainsRune(v, 0) || hasControlChar(v) {
+		return "", fmt.Errorf("expands to control characters")
+	}
+	return v, nil
+}
@@ -0,0 +1,131 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultHashBuckets is how many values a metadata label without an allowlist can take.
+const defaultHashBuckets = 16
+
+// otherLabelValue stands for every value missing from a label's allowlist.
+const otherLabelValue = "other"
+
+// MetadataLabels promotes job metadata keys to labels of the jobs_total
+// counter. Every distinct combination of label values is a time series of its
+// own, so values are bounded: a key with an allowlist in Values keeps the
+// values listed and reports any other as "other", and a key without one has
+// its values hashed into HashBuckets buckets (16 by default), reported as
+// "hash-0" and so on. A job without the key reports an empty value.
+type MetadataLabels struct {
+	Keys        []string
+	Values      map[string][]string
+	HashBuckets int
+}
+
+// labelNamePattern is the Prometheus label name syntax.
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Validate checks that every key is a usable, distinct label name and that
+// allowlists are only given for promoted keys.
+func (l MetadataLabels) Validate() error {
+	var problems []error
+	for i, key := range l.Keys {
+		switch {
+		case !labelNamePattern.MatchString(key) || strings.HasPrefix(key, "__"):
+			problems = append(problems, fmt.Errorf("%q is not a valid label name", key))
+		case key == "status":
+			problems = append(problems, errors.New(`"status" is already a label of jobs_total`))
+		case slices.Contains(l.Keys[:i], key):
+			problems = append(problems, fmt.Errorf("%q is listed twice", key))
+		}
+	}
+	for key := range l.Values {
+		if !slices.Contains(l.Keys, key) {
+			problems = append(problems, fmt.Errorf("values are given for %q, which is not a promoted key", key))
+		}
+	}
+	if l.HashBuckets < 0 {
+		problems = append(problems, fmt.Errorf("hash buckets must be >= 0, got %d", l.HashBuckets))
+	}
+	return errors.Join(problems...)
+}
+
+// JobsTotal counts jobs by status and promoted metadata, once when they are
+// queued and once when they finish. It is a prometheus.Collector for the
+// caller to register, and is handed to the manager with WithJobsTotal.
+type JobsTotal struct {
+	keys    []string
+	allowed map[string]map[string]bool
+	buckets uint32
+	vec     *prometheus.CounterVec
+}
+
+// NewJobsTotal creates the jobs_total counter with a status label and one
+// label per key of l. The keys must be valid Prometheus label names other
+// than status.
+func NewJobsTotal(l MetadataLabels) *JobsTotal {
+	c := &JobsTotal{
+		keys:    append([]string(nil), l.Keys...),
+		allowed: make(map[string]map[string]bool, len(l.Values)),
+		buckets: defaultHashBuckets,
+	}
+	if l.HashBuckets > 0 {
+		c.buckets = uint32(l.HashBuckets)
+	}
+	for key, values := range l.Values {
+		c.allowed[key] = make(map[string]bool, len(values))
+		for _, v := range values {
+			c.allowed[key][v] = true
+		}
+	}
+	c.vec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "jobs_total",
+		Help: "Jobs queued and finished, by status and the job metadata promoted to labels",
+	}, append([]string{"status"}, c.keys...))
+	return c
+}
+
+func (c *JobsTotal) Describe(ch chan<- *prometheus.Desc) { c.vec.Describe(ch) }
+
+func (c *JobsTotal) Collect(ch chan<- prometheus.Metric) { c.vec.Collect(ch) }
+
+// observe counts job under its current status.
+func (c *JobsTotal) observe(job Job) {
+	values := make([]string, 0, len(c.keys)+1)
+	values = append(values, string(job.Status))
+	for _, key := range c.keys {
+		values = append(values, c.labelValue(key, job.Metadata))
+	}
+	c.vec.WithLabelValues(values...).Inc()
+}
+
+// labelValue bounds the job's value for key to its allowlist or a hash bucket.
+func (c *JobsTotal) labelValue(key string, metadata map[string]string) string {
+	v, ok := metadata[key]
+	if !ok {
+		return ""
+	}
+	if allowed, ok := c.allowed[key]; ok {
+		if allowed[v] {
+			return v
+		}
+		return otherLabelValue
+	}
+	h := fnv.New32a()
+	h.Write([]byte(v))
+	return fmt.Sprintf("hash-%d", h.Sum32()%c.buckets)
+}
+
+// WithJobsTotal counts every job the manager queues and finishes in c.
+func WithJobsTotal(c *JobsTotal) ManagerOption {
+	return func(m *Manager) {
+		m.jobsTotal = c
+	}
+}
@@ -0,0 +1,95 @@
+package jobs
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestJobsTotal_LabelValues(t *testing.T) {
+	c := NewJobsTotal(MetadataLabels{
+		Keys:        []string{"tenant", "team"},
+		Values:      map[string][]string{"tenant": {"acme", "globex"}},
+		HashBuckets: 4,
+	})
+	if got := c.labelValue("tenant", map[string]string{"tenant": "acme"}); got != "acme" {
+		t.Errorf("allowlisted value = %q, want acme", got)
+	}
+	if got := c.labelValue("tenant", map[string]string{"tenant": "initech"}); got != otherLabelValue {
+		t.Errorf("unlisted value = %q, want %q", got, otherLabelValue)
+	}
+	if got := c.labelValue("tenant", nil); got != "" {
+		t.Errorf("missing key = %q, want empty", got)
+	}
+	seen := map[string]bool{}
+	for _, team := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+		got := c.labelValue("team", map[string]string{"team": team})
+		if got != c.labelValue("team", map[string]string{"team": team}) {
+			t.Fatalf("hashing %q is not stable", team)
+		}
+		seen[got] = true
+	}
+	if len(seen) > 4 {
+		t.Fatalf("expected at most 4 hash buckets, got %v", seen)
+	}
+	for v := range seen {
+		if !strings.HasPrefix(v, "hash-") {
+			t.Fatalf("unexpected hashed value %q", v)
+		}
+	}
+}
+
+func TestMetadataLabels_Validate(t *testing.T) {
+	valid := MetadataLabels{Keys: []string{"tenant", "t"}, Values: map[string][]string{"tenant": {"acme"}}}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("expected valid labels, got %v", err)
+	}
+	for name, l := range map[string]MetadataLabels{
+		"invalid name":   {Keys: []string{"team-name"}},
+		"reserved name":  {Keys: []string{"__tenant"}},
+		"status":         {Keys: []string{"status"}},
+		"duplicate":      {Keys: []string{"tenant", "tenant"}},
+		"unpromoted key": {Keys: []string{"tenant"}, Values: map[string][]string{"team": {"infra"}}},
+		"hash buckets":   {Keys: []string{"tenant"}, HashBuckets: -1},
+	} {
+		if err := l.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestManager_JobsTotalCountsQueuedAndFinished(t *testing.T) {
+	c := NewJobsTotal(MetadataLabels{Keys: []string{"tenant"}, Values: map[string][]string{"tenant": {"acme"}}})
+	m, err := NewManager(1, NewInMemoryStore(), nopSender{}, fakeRunner{}, NewLogStreamer(), WithJobsTotal(c))
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(m.Stop)
+
+	for _, tenant := range []string{"acme", "acme", "initech"} {
+		id, err := m.Submit(context.Background(), CreateJobRequest{Command: "echo", Metadata: map[string]string{"tenant": tenant}})
+		if err != nil {
+			t.Fatalf("submit failed: %v", err)
+		}
+		waitForStatus(t, m, id, JobStatusCompleted)
+	}
+	// Stop waits for the workers, so every completion has been counted
+	m.Stop()
+
+	for _, tt := range []struct {
+		status, tenant string
+		want           float64
+	}{
+		{"queued", "acme", 2},
+		{"completed", "acme", 2},
+		{"queued", otherLabelValue, 1},
+		{"completed", otherLabelValue, 1},
+		{"in_progress", "acme", 0},
+	} {
+		if got := testutil.ToFloat64(c.vec.WithLabelValues(tt.status, tt.tenant)); got != tt.want {
+			t.Errorf("jobs_total{status=%q,tenant=%q} = %v, want %v", tt.status, tt.tenant, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package jobs
 
 import (
+	"github.com/paulgrammer/childprocess/internal/executor"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -21,12 +22,69 @@ var (
 		Name: "jobs_failed_total",
 		Help: "Total number of jobs failed",
 	})
+	JobsCanceledTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "jobs_canceled_total",
+		Help: "Total number of jobs canceled before finishing",
+	})
+	JobsExpiredInQueueTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "jobs_expired_in_queue_total",
+		Help: "Total number of jobs failed for waiting longer than their max_queue_wait_seconds",
+	})
 	JobsActive = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "jobs_active",
 		Help: "Number of jobs known to the system (not GC'd)",
 	})
+	LogSubscribers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "jobs_log_subscribers",
+		Help: "Number of open log stream subscribers across all jobs",
+	})
+	WebhookInflight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "webhook_inflight",
+		Help: "Number of webhook deliveries currently being sent",
+	})
+	OutputUploadsFailedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "jobs_output_uploads_failed_total",
+		Help: "Job outputs that could not be uploaded by the configured OutputUploader",
+	})
+	EventsDroppedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "jobs_events_dropped_total",
+		Help: "Job status events not delivered to a firehose subscriber that fell behind",
+	})
+	JobsStuck = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "jobs_stuck",
+		Help: "Jobs in progress past the watchdog's limit at its last scan",
+	})
+	JobsStuckFailedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "jobs_stuck_failed_total",
+		Help: "Total number of jobs the watchdog failed for being stuck in progress",
+	})
+	JobsPanickedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "jobs_panicked_total",
+		Help: "Total number of jobs failed because their execution panicked",
+	})
+	JobCPUSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "jobs_cpu_seconds",
+		Help:    "CPU time used by finished jobs, where the platform reports it",
+		Buckets: prometheus.ExponentialBuckets(0.01, 4, 10),
+	}, []string{"mode"})
+	JobMaxRSSBytes = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "jobs_max_rss_bytes",
+		Help:    "Peak resident memory of finished jobs, where the platform reports it",
+		Buckets: prometheus.ExponentialBuckets(1<<20, 4, 10),
+	})
 )
 
+// observeUsage records a run's resource usage. Runs on platforms that report
+// none, or that never started, are skipped rather than counted as zero.
+func observeUsage(result *executor.ExecutionResult) {
+	if result == nil || result.MaxRSSKB == 0 {
+		return
+	}
+	JobCPUSeconds.WithLabelValues("user").Observe(result.UserTime.Seconds())
+	JobCPUSeconds.WithLabelValues("system").Observe(result.SysTime.Seconds())
+	JobMaxRSSBytes.Observe(float64(result.MaxRSSKB * 1024))
+}
+
 func init() {
-	prometheus.MustRegister(JobsQueuedTotal, JobsInProgress, JobsCompletedTotal, JobsFailedTotal, JobsActive)
+	prometheus.MustRegister(JobsQueuedTotal, JobsInProgress, JobsCompletedTotal, JobsFailedTotal, JobsCanceledTotal, JobsExpiredInQueueTotal, JobsActive, LogSubscribers, WebhookInflight, OutputUploadsFailedTotal, EventsDroppedTotal, JobsStuck, JobsStuckFailedTotal, JobsPanickedTotal, JobCPUSeconds, JobMaxRSSBytes)
 }
@@ -0,0 +1,65 @@
+package jobs
+
+import "sync"
+
+// pauseGate holds workers back while the pool is paused. Submissions are not
+// affected, so jobs keep queueing until Resume.
+type pauseGate struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	paused bool
+	open   bool // set on Stop, releasing waiters for good
+}
+
+func newPauseGate() *pauseGate {
+	g := &pauseGate{}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+// wait blocks while the gate is paused.
+func (g *pauseGate) wait() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for g.paused && !g.open {
+		g.cond.Wait()
+	}
+}
+
+func (g *pauseGate) set(paused bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.paused = paused
+	g.cond.Broadcast()
+}
+
+// release lets every waiter through from now on, paused or not.
+func (g *pauseGate) release() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.open = true
+	g.cond.Broadcast()
+}
+
+func (g *pauseGate) isPaused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+// Pause stops workers from starting queued jobs. Running jobs finish normally
+// and new submissions are still accepted, so the queue backs up until Resume.
+// Stop releases a paused pool so it can drain.
+func (m *Manager) Pause() {
+	m.pause.set(true)
+}
+
+// Resume lets workers pick up queued jobs again after Pause.
+func (m *Manager) Resume() {
+	m.pause.set(false)
+}
+
+// Paused reports whether the worker pool is paused.
+func (m *Manager) Paused() bool {
+	return m.pause.isPaused()
+}
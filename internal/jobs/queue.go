@@ -0,0 +1,66 @@
+package jobs
+
+import (
+	"container/list"
+	"sync"
+)
+
+// queueIndex tracks the IDs waiting in the manager's job channel, in
+// submission order, so the queue can be enumerated and flushed.
+type queueIndex struct {
+	mu    sync.Mutex
+	order *list.List
+	elems map[string]*list.Element
+}
+
+func newQueueIndex() *queueIndex {
+	return &queueIndex{order: list.New(), elems: make(map[string]*list.Element)}
+}
+
+func (q *queueIndex) add(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.elems[id] = q.order.PushBack(id)
+}
+
+// remove reports whether id was still queued.
+func (q *queueIndex) remove(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	e, ok := q.elems[id]
+	if !ok {
+		return false
+	}
+	q.order.Remove(e)
+	delete(q.elems, id)
+	return true
+}
+
+func (q *queueIndex) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.order.Len()
+}
+
+func (q *queueIndex) ids() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]string, 0, q.order.Len())
+	for e := q.order.Front(); e != nil; e = e.Next() {
+		out = append(out, e.Value.(string))
+	}
+	return out
+}
+
+// drain removes and returns every queued ID.
+func (q *queueIndex) drain() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]string, 0, q.order.Len())
+	for e := q.order.Front(); e != nil; e = e.Next() {
+		out = append(out, e.Value.(string))
+	}
+	q.order.Init()
+	q.elems = make(map[string]*list.Element)
+	return out
+}
@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/paulgrammer/childprocess/internal/executor"
+)
+
+// ErrSetupFailed wraps the error of a setup step that failed, in which case
+// the job's command was not run.
+var ErrSetupFailed = errors.New("setup failed")
+
+// setupLabel starts every line a setup step streams.
+const setupLabel = "[setup] "
+
+// SetupStep is a command run before a job's own, in the same working
+// directory and under the same user, timeout and output settings.
+type SetupStep struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+func (s *SetupStep) clone() *SetupStep {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	c.Args = slices.Clone(s.Args)
+	return &c
+}
+
+// validate checks the step like the job's own command and args.
+func (s *SetupStep) validate(fe FieldErrors, limits ArgLimits) {
+	switch {
+	case s.Command == "":
+		fe["setup"] = "command must not be empty"
+	case strings.ContainsRune(s.Command, 0):
+		fe["setup"] = "command must not contain null bytes"
+	default:
+		if msg := limits.check(s.Args); msg != "" {
+			fe["setup"] = "args " + msg
+		}
+	}
+}
+
+// runSetup runs the job's setup step with the options of its main command,
+// streaming its output with every line labeled setupLabel. An error means the
+// step failed and is wrapped in ErrSetupFailed; the result still carries the
+// step's output.
+func (m *Manager) runSetup(ctx context.Context, job *Job, w io.Writer, opts []executor.RunOption) (*executor.ExecutionResult, error) {
+	m.streamer.Broadcast(job.ID, []byte("Running setup...\n"))
+	if job.CreateWorkingDir {
+		// Leave a directory created here for the main command, which cleans it up
+		opts = append(slices.Clip(opts), executor.WithCreateWorkingDir(false))
+	}
+	stdout, stderr, flush := decodeStreams(&phaseWriter{w: w, label: setupLabel}, &phaseWriter{w: w, label: setupLabel}, job.OutputEncoding)
+	result, err := m.runner.Run(ctx, job.ID, job.Setup.Command, job.Setup.Args, job.WorkingDir, stdout, stderr, opts...)
+	flush()
+	if err != nil {
+		return result, fmt.Errorf("%w: %w", ErrSetupFailed, err)
+	}
+	m.streamer.Broadcast(job.ID, []byte("Setup finished, running command...\n"))
+	return result, nil
+}
+
+// phaseWriter prefixes every line written through it with label.
+type phaseWriter struct {
+	w       io.Writer
+	label   string
+	midLine bool
+}
+
+func (p *phaseWriter) Write(b []byte) (int, error) {
+	n := len(b)
+	out := make([]byte, 0, n+len(p.label))
+	for len(b) > 0 {
+		if !p.midLine {
+			out = append(out, p.label...)
+		}
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			out = append(out, b...)
+			p.midLine = true
+			break
+		}
+		out = append(out, b[:i+1]...)
+		b = b[i+1:]
+		p.midLine = false
+	}
+	if _, err := p.w.Write(out); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,122 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/paulgrammer/childprocess/internal/executor"
+)
+
+// newSetupManager runs real commands and archives their stream in the returned sink.
+func newSetupManager(t *testing.T) (*Manager, *FileLogSink) {
+	t.Helper()
+	sink, err := NewFileLogSink(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	runner := executor.NewExecRunner(executor.WithExecutorConfig(&executor.ExecutorConfig{CaptureOutput: true, StreamOutput: true}))
+	m, err := NewManager(1, NewInMemoryStore(), nopSender{}, runner, NewLogStreamer(WithLogSink(sink)))
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(m.Stop)
+	return m, sink
+}
+
+func readArchive(t *testing.T, sink *FileLogSink, id string) string {
+	t.Helper()
+	rc, err := sink.Open(id)
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	defer rc.Close()
+	b, _ := io.ReadAll(rc)
+	return string(b)
+}
+
+func TestManager_SetupRunsBeforeCommand(t *testing.T) {
+	m, sink := newSetupManager(t)
+	dir := t.TempDir()
+
+	id, err := m.Submit(context.Background(), CreateJobRequest{
+		Command:    "cat",
+		Args:       []string{"marker"},
+		WorkingDir: dir,
+		Setup:      &SetupStep{Command: "sh", Args: []string{"-c", "echo preparing; echo prepared > marker"}},
+	})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	job := waitForStatus(t, m, id, JobStatusCompleted)
+	if job.Stdout == nil || *job.Stdout != "prepared\n" {
+		t.Fatalf("expected the command to see the setup's file, got %+v", job)
+	}
+	logged := readArchive(t, sink, id)
+	if !strings.Contains(logged, "[setup] preparing\n") || !strings.Contains(logged, "\nprepared\n") {
+		t.Fatalf("expected both phases in the stream, setup labeled, got %q", logged)
+	}
+}
+
+func TestManager_FailedSetupSkipsCommand(t *testing.T) {
+	m, sink := newSetupManager(t)
+	dir := t.TempDir()
+
+	id, err := m.Submit(context.Background(), CreateJobRequest{
+		Command:    "touch",
+		Args:       []string{"ran"},
+		WorkingDir: dir,
+		Setup:      &SetupStep{Command: "sh", Args: []string{"-c", "echo no credentials >&2; exit 3"}},
+	})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	job := waitForStatus(t, m, id, JobStatusFailed)
+	if !strings.HasPrefix(job.Error, ErrSetupFailed.Error()) {
+		t.Fatalf("expected a setup failure, got %q", job.Error)
+	}
+	if job.ExitCode == nil || *job.ExitCode != 3 || job.Stderr == nil || *job.Stderr != "no credentials\n" {
+		t.Fatalf("expected the setup's exit code and output, got %+v", job)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ran")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected the command to be skipped, stat says %v", err)
+	}
+	if logged := readArchive(t, sink, id); !strings.Contains(logged, "[setup] no credentials\n") {
+		t.Fatalf("expected the setup's output in the stream, got %q", logged)
+	}
+}
+
+func TestPhaseWriter_LabelsLinesSplitAcrossWrites(t *testing.T) {
+	var buf bytes.Buffer
+	w := &phaseWriter{w: &buf, label: setupLabel}
+	for _, s := range []string{"one\ntw", "o\n", "", "three"} {
+		if n, err := w.Write([]byte(s)); err != nil || n != len(s) {
+			t.Fatalf("write %q: n=%d err=%v", s, n, err)
+		}
+	}
+	if want := "[setup] one\n[setup] two\n[setup] three"; buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestCreateJobRequest_ValidateSetup(t *testing.T) {
+	valid := CreateJobRequest{Command: "make", Setup: &SetupStep{Command: "mkdir", Args: []string{"-p", "out"}}}
+	if err := valid.Validate(false); err != nil {
+		t.Fatalf("expected valid setup, got %v", err)
+	}
+	for name, req := range map[string]CreateJobRequest{
+		"empty command": {Command: "make", Setup: &SetupStep{}},
+		"null byte":     {Command: "make", Setup: &SetupStep{Command: "mk\x00dir"}},
+		"scratch dir":   {Command: "make", CreateWorkingDir: true, Setup: &SetupStep{Command: "mkdir"}},
+	} {
+		var fe FieldErrors
+		if err := req.Validate(false); !errors.As(err, &fe) || fe["setup"] == "" {
+			t.Errorf("%s: expected a setup error, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package jobs
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+// sqliteMigrations are applied in order on open; PRAGMA user_version records
+// how many have run. Append new steps, never edit old ones.
+var sqliteMigrations = []string{
+	`CREATE TABLE jobs (
+		id         TEXT PRIMARY KEY,
+		status     TEXT    NOT NULL,
+		created_at INTEGER NOT NULL,
+		version    INTEGER NOT NULL,
+		job        TEXT    NOT NULL
+	);
+	CREATE INDEX jobs_status ON jobs (status);
+	CREATE INDEX jobs_created_at ON jobs (created_at);`,
+}
+
+// SQLiteStore keeps jobs in a SQLite database file, so they survive restarts on
+// a single node. Each job is stored as a JSON document, args and metadata
+// included, next to the columns that are queried: status and created_at are
+// indexed, and version backs UpdateWithVersion.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// ErrInterruptedByRestart is recorded on jobs a SQLiteStore finds queued or in
+// progress when it is opened: the process that owned them is gone, so they
+// would otherwise never finish.
+var ErrInterruptedByRestart = errors.New("interrupted by restart")
+
+// NewSQLiteStore opens or creates the database at path, migrates it and fails
+// the jobs left unfinished by the previous process with ErrInterruptedByRestart.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite store: %w", err)
+	}
+	// SQLite allows one writer at a time; a single connection queues writers
+	// here instead of failing them with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+	s := &SQLiteStore{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := s.failInterrupted(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// failInterrupted marks every queued or in_progress job failed. No manager
+// tracks them after a restart, so they could not be canceled, deleted or
+// watched for being stuck. No webhook is sent for them.
+func (s *SQLiteStore) failInterrupted() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	rows, err := tx.Query(`SELECT job FROM jobs WHERE status IN (?, ?)`, JobStatusQueued, JobStatusInProgress)
+	if err != nil {
+		return fmt.Errorf("failed to find interrupted jobs: %w", err)
+	}
+	var interrupted []Job
+	for rows.Next() {
+		var doc []byte
+		if err := rows.Scan(&doc); err != nil {
+			rows.Close()
+			return err
+		}
+		var job Job
+		if err := json.Unmarshal(doc, &job); err != nil {
+			rows.Close()
+			return err
+		}
+		interrupted = append(interrupted, job)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, job := range interrupted {
+		job.PID = 0
+		job.Status = JobStatusFailed
+		job.Error = ErrInterruptedByRestart.Error()
+		job.CompletedAt = &now
+		job.UpdatedAt = now
+		job.Version++
+		doc, err := json.Marshal(&job)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE jobs SET status = ?, version = ?, job = ? WHERE id = ?`, job.Status, job.Version, doc, job.ID); err != nil {
+			return fmt.Errorf("failed to fail interrupted job %s: %w", job.ID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if len(interrupted) > 0 {
+		slog.Warn("failed jobs interrupted by restart", "count", len(interrupted))
+	}
+	return nil
+}
+
+func (s *SQLiteStore) migrate() error {
+	if _, err := s.db.Exec(`PRAGMA journal_mode = WAL`); err != nil {
+		return fmt.Errorf("failed to enable WAL: %w", err)
+	}
+	var applied int
+	if err := s.db.QueryRow(`PRAGMA user_version`).Scan(&applied); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	for i := applied; i < len(sqliteMigrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d failed: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d failed: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %d failed: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Close closes the database.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Ping checks the database is usable, for readiness.
+func (s *SQLiteStore) Ping() error {
+	return s.db.Ping()
+}
+
+func (s *SQLiteStore) Create(job *Job) error {
+	job.Version = 1
+	if job.UpdatedAt.IsZero() {
+		job.UpdatedAt = job.CreatedAt
+	}
+	doc, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Exec(`INSERT INTO jobs (id, status, created_at, version, job) VALUES (?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING`,
+		job.ID, job.Status, job.CreatedAt.UnixNano(), job.Version, doc)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrJobExists
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Update(job *Job) error {
+	for {
+		current, ok := s.Get(job.ID)
+		if !ok {
+			return s.Create(job)
+		}
+		if err := s.UpdateWithVersion(job, current.Version); !errors.Is(err, ErrConcurrentModification) {
+			return err
+		}
+	}
+}
+
+func (s *SQLiteStore) UpdateWithVersion(job *Job, expectedVersion int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var doc []byte
+	err = tx.QueryRow(`SELECT job FROM jobs WHERE id = ?`, job.ID).Scan(&doc)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrJobNotFound
+	}
+	if err != nil {
+		return err
+	}
+	var current Job
+	if err := json.Unmarshal(doc, &current); err != nil {
+		return err
+	}
+	if current.Version != expectedVersion {
+		return ErrConcurrentModification
+	}
+
+	stored := *job
+	stored.Version = expectedVersion + 1
+	// Callers should stamp UpdatedAt themselves; this only catches updates that forgot
+	if !stored.UpdatedAt.After(current.UpdatedAt) {
+		stored.UpdatedAt = time.Now().UTC()
+	}
+	if doc, err = json.Marshal(&stored); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE jobs SET status = ?, version = ?, job = ? WHERE id = ? AND version = ?`,
+		stored.Status, stored.Version, doc, job.ID, expectedVersion); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	job.Version = stored.Version
+	job.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+func (s *SQLiteStore) Get(id string) (*Job, bool) {
+	var doc []byte
+	err := s.db.QueryRow(`SELECT job FROM jobs WHERE id = ?`, id).Scan(&doc)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false
+	}
+	if err != nil {
+		slog.Warn("failed to read job", "job_id", id, "error", err)
+		return nil, false
+	}
+	var job Job
+	if err := json.Unmarshal(doc, &job); err != nil {
+		slog.Warn("failed to decode job", "job_id", id, "error", err)
+		return nil, false
+	}
+	return &job, true
+}
+
+func (s *SQLiteStore) Delete(id string) error {
+	res, err := s.db.Exec(`DELETE FROM jobs WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) CountByStatus() (map[JobStatus]int, error) {
+	rows, err := s.db.Query(`SELECT status, COUNT(*) FROM jobs GROUP BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[JobStatus]int)
+	for rows.Next() {
+		var status JobStatus
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+	return counts, rows.Err()
+}
+
+func (s *SQLiteStore) ListByTag(tag string) ([]*Job, error) {
+	rows, err := s.db.Query(`SELECT job FROM jobs
+		WHERE EXISTS (SELECT 1 FROM json_each(jobs.job, '$.tags') WHERE value = ?)
+		ORDER BY created_at`, tag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*Job
+	for rows.Next() {
+		var doc []byte
+		if err := rows.Scan(&doc); err != nil {
+			return nil, err
+		}
+		var job Job
+		if err := json.Unmarshal(doc, &job); err != nil {
+			return nil, err
+		}
+		out = append(out, &job)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,153 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestSQLiteStore(t *testing.T, path string) *SQLiteStore {
+	t.Helper()
+	s, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLiteStore_SurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	s := newTestSQLiteStore(t, path)
+
+	created := time.Now().UTC().Truncate(time.Millisecond)
+	exit := 3
+	job := &Job{
+		ID:        "a",
+		Command:   "echo",
+		Args:      []string{"hello", "world"},
+		Metadata:  map[string]string{"team": "infra"},
+		Tags:      []string{"deploy"},
+		Status:    JobStatusQueued,
+		CreatedAt: created,
+	}
+	if err := s.Create(job); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	job.Status = JobStatusFailed
+	job.ExitCode = &exit
+	if err := s.Update(job); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := s.Create(&Job{ID: "b", Status: JobStatusQueued, CreatedAt: created}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	s.Close()
+
+	// Migrations must be a no-op on an existing database
+	s = newTestSQLiteStore(t, path)
+	got, ok := s.Get("a")
+	if !ok {
+		t.Fatal("job lost on reopen")
+	}
+	if got.Status != JobStatusFailed || got.Version != 2 || got.ExitCode == nil || *got.ExitCode != 3 {
+		t.Fatalf("got %+v", got)
+	}
+	if len(got.Args) != 2 || got.Args[1] != "world" || got.Metadata["team"] != "infra" || !got.CreatedAt.Equal(created) {
+		t.Fatalf("args, metadata or created_at did not round-trip: %+v", got)
+	}
+
+	counts, err := s.CountByStatus()
+	// b was still queued, so reopening failed it
+	if err != nil || counts[JobStatusFailed] != 2 || counts[JobStatusQueued] != 0 {
+		t.Fatalf("counts = %v, %v", counts, err)
+	}
+	tagged, err := s.ListByTag("deploy")
+	if err != nil || len(tagged) != 1 || tagged[0].ID != "a" {
+		t.Fatalf("ListByTag = %v, %v", tagged, err)
+	}
+}
+
+func TestSQLiteStore_FailsJobsInterruptedByRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	s := newTestSQLiteStore(t, path)
+	started := time.Now().UTC()
+	for _, job := range []*Job{
+		{ID: "queued", Status: JobStatusQueued, CreatedAt: started},
+		{ID: "running", Status: JobStatusInProgress, CreatedAt: started, StartedAt: &started, PID: 4242},
+		{ID: "done", Status: JobStatusCompleted, CreatedAt: started, CompletedAt: &started},
+	} {
+		if err := s.Create(job); err != nil {
+			t.Fatalf("create %s: %v", job.ID, err)
+		}
+	}
+	// The process dies without closing the database
+	s = newTestSQLiteStore(t, path)
+
+	for _, id := range []string{"queued", "running"} {
+		got, ok := s.Get(id)
+		if !ok || got.Status != JobStatusFailed || got.Error != ErrInterruptedByRestart.Error() || got.CompletedAt == nil || got.PID != 0 || got.Version != 2 {
+			t.Fatalf("expected %s failed as interrupted, got %+v", id, got)
+		}
+	}
+	if got, _ := s.Get("done"); got.Status != JobStatusCompleted || got.Version != 1 {
+		t.Fatalf("expected the finished job untouched, got %+v", got)
+	}
+
+	// A manager over the reopened store can delete them like any finished job
+	m, err := NewManager(1, s, nopSender{}, fakeRunner{}, NewLogStreamer())
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(m.Stop)
+	if err := m.Delete(context.Background(), "running"); err != nil {
+		t.Fatalf("expected the interrupted job to be deletable, got %v", err)
+	}
+	if _, ok := s.Get("running"); ok {
+		t.Fatal("expected the job to be gone")
+	}
+}
+
+func TestSQLiteStore_UpdateWithVersion(t *testing.T) {
+	s := newTestSQLiteStore(t, filepath.Join(t.TempDir(), "jobs.db"))
+	if err := s.Create(&Job{ID: "a", Status: JobStatusQueued}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	first, _ := s.Get("a")
+	second, _ := s.Get("a")
+	first.Status = JobStatusCanceled
+	if err := s.UpdateWithVersion(first, first.Version); err != nil || first.Version != 2 {
+		t.Fatalf("first update: %v (version %d)", err, first.Version)
+	}
+	second.Status = JobStatusCompleted
+	if err := s.UpdateWithVersion(second, second.Version); !errors.Is(err, ErrConcurrentModification) {
+		t.Fatalf("expected ErrConcurrentModification, got %v", err)
+	}
+	if err := s.UpdateWithVersion(&Job{ID: "missing"}, 1); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected ErrJobNotFound, got %v", err)
+	}
+	if _, ok := s.Get("missing"); ok {
+		t.Fatal("expected a missing job to be reported as such")
+	}
+}
+
+func TestSQLiteStore_RunsJobsThroughManager(t *testing.T) {
+	s := newTestSQLiteStore(t, filepath.Join(t.TempDir(), "jobs.db"))
+	m, err := NewManager(2, s, nopSender{}, fakeRunner{}, NewLogStreamer())
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(m.Stop)
+
+	id, err := m.Submit(t.Context(), CreateJobRequest{Command: "echo"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	job := waitForStatus(t, m, id, JobStatusCompleted)
+	if job.Stdout == nil || *job.Stdout != "ok\n" {
+		t.Fatalf("expected the runner output to be stored, got %+v", job)
+	}
+}
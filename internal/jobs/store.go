@@ -1,15 +1,37 @@
 package jobs
 
 import (
+    "errors"
+    "slices"
     "sync"
+    "time"
 )
 
+// ErrConcurrentModification is returned by UpdateWithVersion when the stored job
+// changed since it was read.
+var ErrConcurrentModification = errors.New("job was modified concurrently")
+
+// ErrJobExists is returned by Create when a job with the same ID is already stored.
+var ErrJobExists = errors.New("job id already exists")
+
 type Store interface {
+    // Create saves a new job, or returns ErrJobExists if its ID is taken
     Create(job *Job) error
     Update(job *Job) error
+    // UpdateWithVersion saves job only if the stored copy is still at expectedVersion,
+    // otherwise it returns ErrConcurrentModification. On success job.Version is advanced.
+    UpdateWithVersion(job *Job, expectedVersion int64) error
     Get(id string) (*Job, bool)
+    // CountByStatus returns the number of jobs in each status without materializing them
+    CountByStatus() (map[JobStatus]int, error)
+    // ListByTag returns every job carrying tag
+    ListByTag(tag string) ([]*Job, error)
+    // Delete removes the job, or returns ErrJobNotFound if there is none
+    Delete(id string) error
 }
 
+// InMemoryStore keeps its own copy of each job, so callers must save changes
+// back through Update or UpdateWithVersion.
 type InMemoryStore struct {
     data sync.Map
 }
@@ -19,20 +41,84 @@ func NewInMemoryStore() *InMemoryStore {
 }
 
 func (s *InMemoryStore) Create(job *Job) error {
-    s.data.Store(job.ID, job)
+    job.Version = 1
+    if job.UpdatedAt.IsZero() {
+        job.UpdatedAt = job.CreatedAt
+    }
+    stored := *job
+    if _, loaded := s.data.LoadOrStore(job.ID, &stored); loaded {
+        return ErrJobExists
+    }
     return nil
 }
 
 func (s *InMemoryStore) Update(job *Job) error {
-    s.data.Store(job.ID, job)
+    for {
+        v, ok := s.data.Load(job.ID)
+        if !ok {
+            return s.Create(job)
+        }
+        if err := s.UpdateWithVersion(job, v.(*Job).Version); !errors.Is(err, ErrConcurrentModification) {
+            return err
+        }
+    }
+}
+
+func (s *InMemoryStore) UpdateWithVersion(job *Job, expectedVersion int64) error {
+    v, ok := s.data.Load(job.ID)
+    if !ok {
+        return ErrJobNotFound
+    }
+    current := v.(*Job)
+    if current.Version != expectedVersion {
+        return ErrConcurrentModification
+    }
+    stored := *job
+    stored.Version = expectedVersion + 1
+    // Callers should stamp UpdatedAt themselves; this only catches updates that forgot
+    if !stored.UpdatedAt.After(current.UpdatedAt) {
+        stored.UpdatedAt = time.Now().UTC()
+    }
+    if !s.data.CompareAndSwap(job.ID, current, &stored) {
+        return ErrConcurrentModification
+    }
+    job.Version = stored.Version
+    job.UpdatedAt = stored.UpdatedAt
     return nil
 }
 
 func (s *InMemoryStore) Get(id string) (*Job, bool) {
     if v, ok := s.data.Load(id); ok {
-        return v.(*Job), true
+        job := *v.(*Job)
+        return &job, true
     }
     return nil, false
 }
 
+func (s *InMemoryStore) Delete(id string) error {
+    if _, ok := s.data.LoadAndDelete(id); !ok {
+        return ErrJobNotFound
+    }
+    return nil
+}
+
+func (s *InMemoryStore) CountByStatus() (map[JobStatus]int, error) {
+    counts := make(map[JobStatus]int)
+    s.data.Range(func(_, v any) bool {
+        counts[v.(*Job).Status]++
+        return true
+    })
+    return counts, nil
+}
 
+func (s *InMemoryStore) ListByTag(tag string) ([]*Job, error) {
+    var out []*Job
+    s.data.Range(func(_, v any) bool {
+        job := *v.(*Job)
+        if slices.Contains(job.Tags, tag) {
+            out = append(out, &job)
+        }
+        return true
+    })
+    return out, nil
+}
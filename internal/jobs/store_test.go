@@ -0,0 +1,129 @@
+package jobs
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInMemoryStore_UpdateWithVersion(t *testing.T) {
+	s := NewInMemoryStore()
+	if err := s.Create(&Job{ID: "a", Status: JobStatusQueued}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	first, _ := s.Get("a")
+	second, _ := s.Get("a")
+
+	first.Status = JobStatusCanceled
+	if err := s.UpdateWithVersion(first, first.Version); err != nil {
+		t.Fatalf("first update: %v", err)
+	}
+	if first.Version != 2 {
+		t.Fatalf("expected version 2 after update, got %d", first.Version)
+	}
+
+	// second was read before first was saved, so it must not clobber it
+	second.Status = JobStatusCompleted
+	if err := s.UpdateWithVersion(second, second.Version); !errors.Is(err, ErrConcurrentModification) {
+		t.Fatalf("expected ErrConcurrentModification, got %v", err)
+	}
+	if got, _ := s.Get("a"); got.Status != JobStatusCanceled || got.Version != 2 {
+		t.Fatalf("expected canceled at version 2, got %s at %d", got.Status, got.Version)
+	}
+
+	if err := s.UpdateWithVersion(&Job{ID: "missing"}, 1); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected ErrJobNotFound, got %v", err)
+	}
+}
+
+func TestInMemoryStore_GetReturnsCopy(t *testing.T) {
+	s := NewInMemoryStore()
+	_ = s.Create(&Job{ID: "a", Status: JobStatusQueued})
+
+	j, _ := s.Get("a")
+	j.Status = JobStatusFailed
+	if got, _ := s.Get("a"); got.Status != JobStatusQueued {
+		t.Fatalf("expected unsaved change to stay local, got %s", got.Status)
+	}
+}
+
+func TestInMemoryStore_StampsUpdatedAt(t *testing.T) {
+	s := NewInMemoryStore()
+	created := time.Now().UTC().Add(-time.Minute)
+	_ = s.Create(&Job{ID: "a", Status: JobStatusQueued, CreatedAt: created})
+
+	j, _ := s.Get("a")
+	if !j.UpdatedAt.Equal(created) {
+		t.Fatalf("expected UpdatedAt to start at CreatedAt, got %v", j.UpdatedAt)
+	}
+
+	// The caller forgot to advance UpdatedAt, so the store does
+	j.Status = JobStatusInProgress
+	if err := s.Update(j); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got, _ := s.Get("a"); !got.UpdatedAt.After(created) || !got.UpdatedAt.Equal(j.UpdatedAt) {
+		t.Fatalf("expected UpdatedAt to advance past %v, got %v (caller sees %v)", created, got.UpdatedAt, j.UpdatedAt)
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	for _, backend := range []string{"", BackendMemory} {
+		store, err := NewStore(StoreConfig{Backend: backend})
+		if err != nil || store == nil {
+			t.Fatalf("backend %q: expected a store, got %v", backend, err)
+		}
+	}
+	if _, err := NewStore(StoreConfig{Backend: BackendSQLite}); err == nil {
+		t.Fatal("expected the sqlite backend to require a path")
+	}
+	store, err := NewStore(StoreConfig{Backend: BackendSQLite, Path: filepath.Join(t.TempDir(), "jobs.db")})
+	if err != nil {
+		t.Fatalf("sqlite backend: %v", err)
+	}
+	store.(*SQLiteStore).Close()
+	if _, err := NewStore(StoreConfig{Backend: "etcd"}); !errors.Is(err, ErrUnknownStoreBackend) {
+		t.Fatalf("expected ErrUnknownStoreBackend, got %v", err)
+	}
+}
+
+func TestStores_CreateRejectsTakenID(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewInMemoryStore(),
+		"sqlite": newTestSQLiteStore(t, filepath.Join(t.TempDir(), "jobs.db")),
+	}
+	for name, s := range stores {
+		if err := s.Create(&Job{ID: "build-42", Command: "make"}); err != nil {
+			t.Fatalf("%s: create: %v", name, err)
+		}
+		if err := s.Create(&Job{ID: "build-42", Command: "rm"}); !errors.Is(err, ErrJobExists) {
+			t.Fatalf("%s: expected ErrJobExists, got %v", name, err)
+		}
+		if j, _ := s.Get("build-42"); j.Command != "make" {
+			t.Fatalf("%s: duplicate overwrote the stored job: %+v", name, j)
+		}
+	}
+}
+
+func TestStores_Delete(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewInMemoryStore(),
+		"sqlite": newTestSQLiteStore(t, filepath.Join(t.TempDir(), "jobs.db")),
+	}
+	for name, s := range stores {
+		if err := s.Create(&Job{ID: "build-42", Command: "make"}); err != nil {
+			t.Fatalf("%s: create: %v", name, err)
+		}
+		if err := s.Delete("build-42"); err != nil {
+			t.Fatalf("%s: delete: %v", name, err)
+		}
+		if _, ok := s.Get("build-42"); ok {
+			t.Fatalf("%s: expected the job to be gone", name)
+		}
+		if err := s.Delete("build-42"); !errors.Is(err, ErrJobNotFound) {
+			t.Fatalf("%s: expected ErrJobNotFound, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package jobs
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// placeholder matches {{name}} in template arguments.
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+// Template is a named command whose arguments may contain {{param}} placeholders.
+// The command itself is fixed; only arguments are parameterized.
+type Template struct {
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
+}
+
+// params returns the placeholder names used by the template, sorted.
+func (t Template) params() []string {
+	seen := map[string]bool{}
+	for _, a := range t.Args {
+		for _, m := range placeholder.FindAllStringSubmatch(a, -1) {
+			seen[m[1]] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for n := range seen {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TemplateRegistry holds the command templates jobs may reference by name.
+type TemplateRegistry struct {
+	templates map[string]Template
+}
+
+// NewTemplateRegistry validates the templates and returns a registry for them.
+func NewTemplateRegistry(templates map[string]Template) (*TemplateRegistry, error) {
+	for name, t := range templates {
+		if name == "" {
+			return nil, fmt.Errorf("template name must not be empty")
+		}
+		if strings.TrimSpace(t.Command) == "" {
+			return nil, fmt.Errorf("template %q: command must not be empty", name)
+		}
+		if strings.Contains(t.Command, "{{") {
+			return nil, fmt.Errorf("template %q: command must not contain placeholders", name)
+		}
+	}
+	return &TemplateRegistry{templates: templates}, nil
+}
+
+// LoadTemplates reads a YAML or JSON file mapping template names to templates.
+func LoadTemplates(path string) (*TemplateRegistry, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates file: %w", err)
+	}
+	var templates map[string]Template
+	if err := yaml.Unmarshal(raw, &templates); err != nil {
+		return nil, fmt.Errorf("failed to parse templates file %s: %w", path, err)
+	}
+	return NewTemplateRegistry(templates)
+}
+
+// Resolve substitutes params into the named template. Unknown templates, missing
+// or unused params, and values that would turn into a leading option flag are
+// reported as FieldErrors.
+func (r *TemplateRegistry) Resolve(name string, params map[string]string) (string, []string, error) {
+	t, ok := r.templates[name]
+	if !ok {
+		return "", nil, FieldErrors{"template": fmt.Sprintf("unknown template %q", name)}
+	}
+
+	fe := FieldErrors{}
+	wanted := map[string]bool{}
+	for _, p := range t.params() {
+		wanted[p] = true
+		if _, ok := params[p]; !ok {
+			fe["params."+p] = "is required"
+		}
+	}
+	for p := range params {
+		if !wanted[p] {
+			fe["params."+p] = "is not used by the template"
+		}
+	}
+	if len(fe) > 0 {
+		return "", nil, fe
+	}
+
+	args := make([]string, len(t.Args))
+	for i, a := range t.Args {
+		args[i] = placeholder.ReplaceAllStringFunc(a, func(m string) string {
+			return params[placeholder.FindStringSubmatch(m)[1]]
+		})
+		// A param at the start of an argument must not smuggle in an option
+		if loc := placeholder.FindStringIndex(a); loc != nil && loc[0] == 0 && strings.HasPrefix(args[i], "-") {
+			fe["params."+placeholder.FindStringSubmatch(a)[1]] = "must not start with '-'"
+		}
+	}
+	if len(fe) > 0 {
+		return "", nil, fe
+	}
+	return t.Command, args, nil
+}
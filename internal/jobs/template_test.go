@@ -0,0 +1,202 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testTemplates(t *testing.T) *TemplateRegistry {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "templates.yaml")
+	const file = `
+backup:
+  command: pg_dump
+  args: ["--dbname", "{{db}}", "--file", "/backups/{{ db }}-{{stamp}}.sql"]
+uptime:
+  command: uptime
+`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatalf("failed to write templates: %v", err)
+	}
+	r, err := LoadTemplates(path)
+	if err != nil {
+		t.Fatalf("failed to load templates: %v", err)
+	}
+	return r
+}
+
+func TestTemplateRegistry_Resolve(t *testing.T) {
+	r := testTemplates(t)
+
+	command, args, err := r.Resolve("backup", map[string]string{"db": "orders", "stamp": "2024"})
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	want := []string{"--dbname", "orders", "--file", "/backups/orders-2024.sql"}
+	if command != "pg_dump" || len(args) != len(want) {
+		t.Fatalf("got %s %v, want pg_dump %v", command, args, want)
+	}
+	for i := range want {
+		if args[i] != want[i] {
+			t.Fatalf("arg %d = %q, want %q", i, args[i], want[i])
+		}
+	}
+
+	if command, args, err := r.Resolve("uptime", nil); err != nil || command != "uptime" || len(args) != 0 {
+		t.Fatalf("expected parameterless template to resolve, got %s %v %v", command, args, err)
+	}
+}
+
+func TestTemplateRegistry_ResolveErrors(t *testing.T) {
+	r := testTemplates(t)
+	cases := []struct {
+		name     string
+		template string
+		params   map[string]string
+		field    string
+	}{
+		{"unknown template", "restore", nil, "template"},
+		{"missing param", "backup", map[string]string{"db": "orders"}, "params.stamp"},
+		{"unused param", "uptime", map[string]string{"db": "orders"}, "params.db"},
+		{"option injection", "backup", map[string]string{"db": "--help", "stamp": "1"}, "params.db"},
+	}
+	for _, tc := range cases {
+		_, _, err := r.Resolve(tc.template, tc.params)
+		var fe FieldErrors
+		if !errors.As(err, &fe) || fe[tc.field] == "" {
+			t.Errorf("%s: expected an error for %s, got %v", tc.name, tc.field, err)
+		}
+	}
+}
+
+func TestNewTemplateRegistry_RejectsInvalidTemplates(t *testing.T) {
+	if _, err := NewTemplateRegistry(map[string]Template{"empty": {}}); err == nil {
+		t.Fatal("expected an error for a template without a command")
+	}
+	if _, err := NewTemplateRegistry(map[string]Template{"dyn": {Command: "{{bin}}"}}); err == nil {
+		t.Fatal("expected an error for a placeholder in the command")
+	}
+}
+
+func TestManager_SubmitTemplate(t *testing.T) {
+	m, err := NewManager(1, NewInMemoryStore(), nopSender{}, fakeRunner{}, NewLogStreamer(), WithTemplates(testTemplates(t)))
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(m.Stop)
+	ctx := context.Background()
+
+	id, err := m.Submit(ctx, CreateJobRequest{Template: "backup", Params: map[string]string{"db": "orders", "stamp": "1"}})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	job := waitForStatus(t, m, id, JobStatusCompleted)
+	if job.Command != "pg_dump" || len(job.Args) != 4 || job.Args[1] != "orders" || job.Template != "backup" {
+		t.Fatalf("template was not resolved into the job: %+v", job)
+	}
+
+	retryID, err := m.Retry(ctx, id)
+	if err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+	if retried := waitForStatus(t, m, retryID, JobStatusCompleted); retried.Command != "pg_dump" || retried.Template != "backup" {
+		t.Fatalf("retry did not keep the template: %+v", retried)
+	}
+
+	_, err = m.Submit(ctx, CreateJobRequest{Template: "backup", Params: map[string]string{"db": "orders"}})
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("expected ErrInvalidRequest for a missing param, got %v", err)
+	}
+	_, err = m.Submit(ctx, CreateJobRequest{Template: "uptime", Command: "rm"})
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("expected ErrInvalidRequest when mixing template and command, got %v", err)
+	}
+}
+
+func TestManager_TemplatesNotConfigured(t *testing.T) {
+	m := newTestManager(t, fakeRunner{})
+	_, err := m.Submit(context.Background(), CreateJobRequest{Template: "backup"})
+	var fe FieldErrors
+	if !errors.Is(err, ErrInvalidRequest) || !errors.As(err, &fe) || fe["template"] == "" {
+		t.Fatalf("expected a template field error, got %v", err)
+	}
+}
+
+func TestManager_MetadataTemplating(t *testing.T) {
+	m, err := NewManager(1, NewInMemoryStore(), nopSender{}, fakeRunner{}, NewLogStreamer(), WithMetadataTemplating())
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(m.Stop)
+	ctx := context.Background()
+
+	id, err := m.Submit(ctx, CreateJobRequest{
+		Command:  "pg_dump",
+		Args:     []string{"--dbname", "{{.Metadata.db}}", "--file=/backups/{{.Metadata.db}} {{.Metadata.day}}.sql"},
+		Metadata: map[string]string{"db": "orders; rm -rf /", "day": "mon"},
+	})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	job := waitForStatus(t, m, id, JobStatusCompleted)
+	want := []string{"--dbname", "orders; rm -rf /", "--file=/backups/orders; rm -rf / mon.sql"}
+	if !slices.Equal(job.Args, want) {
+		t.Fatalf("args = %q, want %q", job.Args, want)
+	}
+
+	for _, req := range []CreateJobRequest{
+		{Command: "echo", Args: []string{"{{.Metadata.missing}}"}, Metadata: map[string]string{"db": "x"}},
+		{Command: "echo", Args: []string{"{{.Unknown}}"}},
+		{Command: "echo", Args: []string{"{{.Metadata.db"}},
+		{Command: "{{.Metadata.cmd}}"},
+		{Command: "echo", Args: []string{"{{.Metadata.flag}}"}, Metadata: map[string]string{"flag": "--delete"}},
+		{Command: "echo", Args: []string{"{{.Metadata.text}}"}, Metadata: map[string]string{"text": "a\nb"}},
+	} {
+		if _, err := m.Submit(ctx, req); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("%+v: expected ErrInvalidRequest, got %v", req, err)
+		}
+	}
+
+	// Without the option placeholders are passed through untouched
+	plain := newTestManager(t, fakeRunner{})
+	id, err = plain.Submit(ctx, CreateJobRequest{Command: "echo", Args: []string{"{{.Metadata.db}}"}})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	if job := waitForStatus(t, plain, id, JobStatusCompleted); job.Args[0] != "{{.Metadata.db}}" {
+		t.Fatalf("expected the placeholder kept, got %q", job.Args)
+	}
+}
+
+func TestExpandMetadata_RejectsTemplateActions(t *testing.T) {
+	metadata := make(map[string]string, MaxMetadataEntries)
+	for i := range MaxMetadataEntries {
+		metadata[fmt.Sprintf("k%d", i)] = "x"
+	}
+	// Under a template engine each nested range multiplies the output by the
+	// number of entries
+	nested := "{{range .Metadata}}{{range $.Metadata}}{{range $.Metadata}}{{range $.Metadata}}{{.}}{{end}}{{end}}{{end}}{{end}}"
+	start := time.Now()
+	_, _, err := expandMetadata("echo", []string{nested}, metadata)
+	var fe FieldErrors
+	if !errors.As(err, &fe) || !strings.Contains(fe["args[0]"], "only {{.Metadata.key}}") {
+		t.Fatalf("expected the range actions to be rejected, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("rejecting the payload took %s", elapsed)
+	}
+
+	// Plain substitution is still capped while it builds the result
+	metadata["big"] = strings.Repeat("x", MaxMetadataValueBytes)
+	long := strings.Repeat("{{.Metadata.big}}", MaxArgLength/MaxMetadataValueBytes+1)
+	if _, _, err := expandMetadata("echo", []string{long}, metadata); !errors.As(err, &fe) || !strings.Contains(fe["args[0]"], "more than") {
+		t.Fatalf("expected the expansion to be capped, got %v", err)
+	}
+}
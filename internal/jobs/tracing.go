@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"context"
+
+	"github.com/paulgrammer/childprocess/internal/executor"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer comes from the global provider, so spans are no-ops until the program
+// installs one with otel.SetTracerProvider.
+var tracer = otel.Tracer("github.com/paulgrammer/childprocess/internal/jobs")
+
+// startSubmitSpan starts the span of a job's submission and remembers its
+// context, so the run and its webhooks join the same trace.
+func (m *Manager) startSubmitSpan(ctx context.Context, id string) (context.Context, trace.Span) {
+	ctx, span := tracer.Start(ctx, "job.submit", trace.WithAttributes(attribute.String("job.id", id)))
+	if sc := span.SpanContext(); sc.IsValid() {
+		m.traces.Store(id, sc)
+	}
+	return ctx, span
+}
+
+// startRunSpan starts the span of a job's run as a child of its submission,
+// if that was traced.
+func (m *Manager) startRunSpan(ctx context.Context, id string) (context.Context, trace.Span) {
+	if sc, ok := m.traces.LoadAndDelete(id); ok {
+		ctx = trace.ContextWithSpanContext(ctx, sc.(trace.SpanContext))
+	}
+	return tracer.Start(ctx, "job.run", trace.WithAttributes(attribute.String("job.id", id)))
+}
+
+// endRunSpan records how the job ended and ends its run span.
+func endRunSpan(span trace.Span, job *Job, result *executor.ExecutionResult) {
+	span.SetAttributes(attribute.String("job.status", string(job.Status)))
+	if job.ExitCode != nil {
+		span.SetAttributes(attribute.Int("job.exit_code", *job.ExitCode))
+	}
+	if result != nil {
+		span.SetAttributes(attribute.Int64("job.duration_ms", result.Duration.Milliseconds()))
+	}
+	if job.Status != JobStatusCompleted {
+		span.SetStatus(codes.Error, job.Error)
+	}
+	span.End()
+}
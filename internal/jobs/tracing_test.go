@@ -0,0 +1,77 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/paulgrammer/childprocess/internal/webhook"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceSender records the span context each webhook was delivered under.
+type traceSender struct {
+	mu    sync.Mutex
+	spans map[string]trace.SpanContext
+}
+
+func (s *traceSender) Notify(ctx context.Context, url string, event webhook.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spans[event.Status] = trace.SpanContextFromContext(ctx)
+	return nil
+}
+
+func TestManager_TracesJobFromSubmitToWebhook(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	sender := &traceSender{spans: make(map[string]trace.SpanContext)}
+	m, err := NewManager(1, NewInMemoryStore(), sender, fakeRunner{}, NewLogStreamer())
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+
+	// A remote parent, as the HTTP API extracts from a traceparent header
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	id, err := m.Submit(trace.ContextWithSpanContext(context.Background(), parent), CreateJobRequest{Command: "echo", WebhookURL: "http://hook.example/t"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	waitForStatus(t, m, id, JobStatusCompleted)
+	m.Stop()
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	submit, run := spans["job.submit"], spans["job.run"]
+	if submit == nil || run == nil {
+		t.Fatalf("expected job.submit and job.run spans, got %v", spans)
+	}
+	if submit.Parent().SpanID() != parent.SpanID() || run.Parent().SpanID() != submit.SpanContext().SpanID() {
+		t.Fatal("expected parent -> job.submit -> job.run")
+	}
+	attrs := attribute.NewSet(run.Attributes()...)
+	if v, _ := attrs.Value("job.status"); v.AsString() != "completed" {
+		t.Fatalf("job.status = %q", v.AsString())
+	}
+	if v, ok := attrs.Value("job.exit_code"); !ok || v.AsInt64() != 0 {
+		t.Fatalf("job.exit_code = %v", v)
+	}
+
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	if sc := sender.spans[string(JobStatusCompleted)]; sc.TraceID() != parent.TraceID() {
+		t.Fatalf("completed webhook sent outside the job's trace: %v", sc.TraceID())
+	}
+}
@@ -1,6 +1,7 @@
 package jobs
 
 import (
+	"slices"
 	"time"
 )
 
@@ -11,14 +12,63 @@ const (
 	JobStatusInProgress JobStatus = "in_progress"
 	JobStatusCompleted  JobStatus = "completed"
 	JobStatusFailed     JobStatus = "failed"
+	JobStatusCanceled   JobStatus = "canceled"
 )
 
+// JobStatuses lists every status a job can be in.
+var JobStatuses = []JobStatus{JobStatusQueued, JobStatusInProgress, JobStatusCompleted, JobStatusFailed, JobStatusCanceled}
+
+// Terminal reports whether a job in this status will not change again.
+func (s JobStatus) Terminal() bool {
+	return s == JobStatusCompleted || s == JobStatusFailed || s == JobStatusCanceled
+}
+
 type CreateJobRequest struct {
+	// ID, if set, is used as the job's ID instead of a generated one; see MaxIDLength
+	ID         string            `json:"id,omitempty"`
 	Command    string            `json:"command"`
 	Args       []string          `json:"args,omitempty"`
 	WorkingDir string            `json:"working_dir,omitempty"`
 	WebhookURL string            `json:"webhook_url"`
 	Metadata   map[string]string `json:"metadata,omitempty"`
+	RunAsUser  string            `json:"run_as_user,omitempty"`
+	RunAsGroup string            `json:"run_as_group,omitempty"`
+	// IncludeOutputInWebhook embeds stdout, stderr and exit_code in the completed/failed webhook events
+	IncludeOutputInWebhook bool `json:"include_output_in_webhook,omitempty"`
+	// Template names a configured command template to run instead of Command and Args
+	Template string            `json:"template,omitempty"`
+	Params   map[string]string `json:"params,omitempty"`
+	// Nice lowers (positive) or raises (negative) the process's CPU priority, -20..19 (Unix only)
+	Nice int `json:"nice,omitempty"`
+	// Tags group jobs for bulk operations such as CancelByTag
+	Tags []string `json:"tags,omitempty"`
+	// CreateWorkingDir creates WorkingDir if it is missing, or a scratch directory
+	// if it is empty. CleanupWorkingDir removes a directory created this way afterwards.
+	CreateWorkingDir  bool `json:"create_working_dir,omitempty"`
+	CleanupWorkingDir bool `json:"cleanup_working_dir,omitempty"`
+	// MaxQueueWaitSeconds fails the job with "queue wait exceeded" instead of
+	// running it if no worker picks it up within that many seconds; 0 waits forever
+	MaxQueueWaitSeconds int `json:"max_queue_wait_seconds,omitempty"`
+	// TimeoutSeconds bounds how long the job may run once started; when it runs
+	// out the job fails with "job timed out". 0 means no limit
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+	// Sensitive keeps the job's args and output out of the server logs.
+	// RedactOutput also leaves stdout and stderr out of the stored job and its
+	// webhooks, and implies Sensitive
+	Sensitive    bool `json:"sensitive,omitempty"`
+	RedactOutput bool `json:"redact_output,omitempty"`
+	// WebhookEvents limits webhook deliveries to these statuses, e.g.
+	// ["completed", "failed"]; empty delivers every status change
+	WebhookEvents []string `json:"webhook_events,omitempty"`
+	// OutputEncoding declares what the command writes: an IANA charset such as
+	// "windows-1252" or "latin1", converted to UTF-8 when stored and streamed,
+	// "utf-8", whose invalid bytes are replaced with U+FFFD, or "raw" to store
+	// stdout and stderr base64 encoded. Empty uses the server's OUTPUT_CHARSET
+	OutputEncoding string `json:"output_encoding,omitempty"`
+	// Setup runs first, in the same working directory; if it fails the command
+	// is skipped and the job fails with the setup's output. Its streamed lines
+	// are prefixed with "[setup] "
+	Setup *SetupStep `json:"setup,omitempty"`
 }
 
 type Job struct {
@@ -36,4 +86,69 @@ type Job struct {
 	CreatedAt   time.Time         `json:"created_at"`
 	StartedAt   *time.Time        `json:"started_at,omitempty"`
 	CompletedAt *time.Time        `json:"completed_at,omitempty"`
+	RetryOf     string            `json:"retry_of,omitempty"`
+	RunAsUser   string            `json:"run_as_user,omitempty"`
+	RunAsGroup  string            `json:"run_as_group,omitempty"`
+
+	IncludeOutputInWebhook bool              `json:"include_output_in_webhook,omitempty"`
+	Template               string            `json:"template,omitempty"`
+	Params                 map[string]string `json:"params,omitempty"`
+
+	// StdoutBytes and StderrBytes are the full output sizes; Stdout and Stderr may be truncated
+	StdoutBytes int  `json:"stdout_bytes,omitempty"`
+	StderrBytes int  `json:"stderr_bytes,omitempty"`
+	Truncated   bool `json:"truncated,omitempty"`
+	// StdoutCapturedBytes and StderrCapturedBytes are the sizes of Stdout and
+	// Stderr as stored, after truncation; with RedactOutput they are kept without the output
+	StdoutCapturedBytes int `json:"stdout_captured_bytes,omitempty"`
+	StderrCapturedBytes int `json:"stderr_captured_bytes,omitempty"`
+
+	Nice int `json:"nice,omitempty"`
+
+	// Version is advanced by the store on every update, for optimistic concurrency
+	Version int64 `json:"version"`
+	// UpdatedAt is when the job last changed
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Tags []string `json:"tags,omitempty"`
+	// PID is the OS process ID while the command is running; cleared once it finishes
+	PID int `json:"pid,omitempty"`
+
+	CreateWorkingDir  bool `json:"create_working_dir,omitempty"`
+	CleanupWorkingDir bool `json:"cleanup_working_dir,omitempty"`
+
+	// UserTimeMs, SysTimeMs and MaxRSSKB are the resources the finished command
+	// used, where the platform reports them
+	UserTimeMs int64 `json:"user_time_ms,omitempty"`
+	SysTimeMs  int64 `json:"sys_time_ms,omitempty"`
+	MaxRSSKB   int64 `json:"max_rss_kb,omitempty"`
+	// DurationMs is how long the command itself ran, from spawn to exit
+	DurationMs int64 `json:"duration_ms,omitempty"`
+
+	// OutputURL is where the output was uploaded, with WithOutputUploader;
+	// OutputUploadError says why the upload failed instead
+	OutputURL         string `json:"output_url,omitempty"`
+	OutputUploadError string `json:"output_upload_error,omitempty"`
+
+	MaxQueueWaitSeconds int `json:"max_queue_wait_seconds,omitempty"`
+	TimeoutSeconds      int `json:"timeout_seconds,omitempty"`
+	// EffectiveTimeoutSeconds is the timeout the job runs under: TimeoutSeconds,
+	// or the manager's default if that is 0, capped at its maximum; 0 means none
+	EffectiveTimeoutSeconds int `json:"effective_timeout_seconds,omitempty"`
+
+	Sensitive    bool `json:"sensitive,omitempty"`
+	RedactOutput bool `json:"redact_output,omitempty"`
+
+	WebhookEvents []string `json:"webhook_events,omitempty"`
+
+	// OutputEncoding is the encoding the job declared; with "raw", Stdout and
+	// Stderr hold base64
+	OutputEncoding string `json:"output_encoding,omitempty"`
+
+	Setup *SetupStep `json:"setup,omitempty"`
+}
+
+// wantsWebhook reports whether the job's webhook should hear about status.
+func (j *Job) wantsWebhook(status JobStatus) bool {
+	return len(j.WebhookEvents) == 0 || slices.Contains(j.WebhookEvents, string(status))
 }
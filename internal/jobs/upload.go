@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/paulgrammer/childprocess/internal/executor"
+)
+
+// uploadTimeout bounds a single output upload.
+const uploadTimeout = 2 * time.Minute
+
+// OutputUploader archives a finished job's captured output outside the server,
+// e.g. in object storage.
+type OutputUploader interface {
+	// Upload stores the output under the job's ID and returns a URL for it
+	Upload(ctx context.Context, jobID, stdout, stderr string) (string, error)
+}
+
+// WithOutputUploader uploads the output of every job that ran, once it ends, with u.
+// Uploads run in the background; the job's OutputURL, or OutputUploadError if
+// the upload failed, is filled in once it is done.
+func WithOutputUploader(u OutputUploader) ManagerOption {
+	return func(m *Manager) {
+		m.uploader = u
+	}
+}
+
+// uploadOutput hands the run's output to the uploader without holding up the
+// worker. Jobs with RedactOutput are not uploaded.
+func (m *Manager) uploadOutput(job *Job, result *executor.ExecutionResult) {
+	if m.uploader == nil || result == nil || job.RedactOutput {
+		return
+	}
+	m.uploadWG.Add(1)
+	go func() {
+		defer m.uploadWG.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), uploadTimeout)
+		defer cancel()
+		url, err := m.uploader.Upload(ctx, job.ID, result.Stdout, result.Stderr)
+		if err != nil {
+			slog.Warn("failed to upload job output", "job_id", job.ID, "error", err)
+			OutputUploadsFailedTotal.Inc()
+		}
+		m.update(job.ID, func(j *Job) {
+			if err != nil {
+				j.OutputUploadError = err.Error()
+				return
+			}
+			j.OutputURL = url
+		})
+	}()
+}
@@ -0,0 +1,266 @@
+package jobs
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/paulgrammer/childprocess/internal/executor"
+)
+
+// Limits on the size of a CreateJobRequest.
+const (
+	MaxArgs               = 256
+	MaxArgLength          = 32 * 1024
+	MaxArgsBytes          = 1024 * 1024
+	MaxMetadataEntries    = 64
+	MaxMetadataKeyLength  = 128
+	MaxMetadataValueBytes = 4 * 1024
+	MaxTags               = 32
+	MaxTagLength          = 128
+	MaxIDLength           = 128
+)
+
+// ArgLimits caps a job's args, as given or once expanded from a template.
+// Zero fields take the package defaults, MaxArgs and MaxArgsBytes.
+type ArgLimits struct {
+	// Count is how many args a job may have
+	Count int
+	// TotalBytes caps the combined length of the args
+	TotalBytes int
+}
+
+func (l ArgLimits) withDefaults() ArgLimits {
+	if l.Count <= 0 {
+		l.Count = MaxArgs
+	}
+	if l.TotalBytes <= 0 {
+		l.TotalBytes = MaxArgsBytes
+	}
+	return l
+}
+
+// check returns what is wrong with args, or "" if nothing is.
+func (l ArgLimits) check(args []string) string {
+	l = l.withDefaults()
+	if len(args) > l.Count {
+		return fmt.Sprintf("must have at most %d entries", l.Count)
+	}
+	total := 0
+	for _, a := range args {
+		if len(a) > MaxArgLength {
+			return fmt.Sprintf("each entry must be at most %d bytes", MaxArgLength)
+		}
+		// exec would silently cut the arg at the first null byte
+		if strings.ContainsRune(a, 0) {
+			return "entries must not contain null bytes"
+		}
+		total += len(a)
+	}
+	if total > l.TotalBytes {
+		return fmt.Sprintf("must total at most %d bytes, got %d", l.TotalBytes, total)
+	}
+	return ""
+}
+
+// FieldErrors maps request fields to what is wrong with them.
+type FieldErrors map[string]string
+
+func (fe FieldErrors) Error() string {
+	fields := make([]string, 0, len(fe))
+	for f := range fe {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	parts := make([]string, 0, len(fields))
+	for _, f := range fields {
+		parts = append(parts, f+": "+fe[f])
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Validate checks the request before it is queued, with the default ArgLimits.
+// allowEmptyCommand should be true when the executor has a default command to
+// fall back on. A manager created with WithArgLimits checks args against its
+// own limits, so use ValidateWithLimits to agree with it.
+func (r CreateJobRequest) Validate(allowEmptyCommand bool) error {
+	return r.ValidateWithLimits(allowEmptyCommand, ArgLimits{})
+}
+
+// ValidateWithLimits is Validate with args, setup args included, capped by
+// limits instead of the defaults.
+func (r CreateJobRequest) ValidateWithLimits(allowEmptyCommand bool, limits ArgLimits) error {
+	fe := FieldErrors{}
+
+	if r.ID != "" && !validJobID(r.ID) {
+		fe["id"] = fmt.Sprintf("must be 1-%d letters, digits, '.', '_' or '-', starting with a letter or digit", MaxIDLength)
+	}
+
+	if r.Template != "" {
+		if r.Command != "" || len(r.Args) > 0 {
+			fe["template"] = "cannot be combined with command or args"
+		}
+	} else if strings.TrimSpace(r.Command) == "" && !allowEmptyCommand {
+		fe["command"] = "must not be empty"
+	} else if strings.ContainsRune(r.Command, 0) {
+		fe["command"] = "must not contain null bytes"
+	}
+	if len(r.Params) > 0 && r.Template == "" {
+		fe["params"] = "requires template"
+	} else if len(r.Params) > MaxArgs {
+		fe["params"] = fmt.Sprintf("must have at most %d entries", MaxArgs)
+	} else {
+		for _, v := range r.Params {
+			if len(v) > MaxArgLength {
+				fe["params"] = fmt.Sprintf("values must be at most %d bytes", MaxArgLength)
+				break
+			}
+			if strings.ContainsRune(v, 0) {
+				fe["params"] = "values must not contain null bytes"
+				break
+			}
+		}
+	}
+
+	if msg := limits.check(r.Args); msg != "" {
+		fe["args"] = msg
+	}
+	if r.Setup != nil {
+		r.Setup.validate(fe, limits)
+		// A scratch directory is made afresh for every run
+		if r.CreateWorkingDir && r.WorkingDir == "" {
+			fe["setup"] = "requires working_dir when create_working_dir is set"
+		}
+	}
+
+	if r.Nice < executor.MinNice || r.Nice > executor.MaxNice {
+		fe["nice"] = fmt.Sprintf("must be between %d and %d", executor.MinNice, executor.MaxNice)
+	}
+
+	if r.OutputEncoding != "" {
+		if err := executor.CheckOutputEncoding(r.OutputEncoding); err != nil {
+			fe["output_encoding"] = `must be "raw" or a known charset such as utf-8 or windows-1252`
+		}
+	}
+
+	if r.MaxQueueWaitSeconds < 0 {
+		fe["max_queue_wait_seconds"] = "must not be negative"
+	}
+	if r.TimeoutSeconds < 0 {
+		fe["timeout_seconds"] = "must not be negative"
+	}
+
+	if r.CleanupWorkingDir && !r.CreateWorkingDir {
+		fe["cleanup_working_dir"] = "requires create_working_dir"
+	}
+
+	if r.WorkingDir != "" {
+		for _, part := range strings.Split(filepath.ToSlash(r.WorkingDir), "/") {
+			if part == ".." {
+				fe["working_dir"] = "must not contain '..'"
+				break
+			}
+		}
+	}
+
+	for _, e := range r.WebhookEvents {
+		if !slices.Contains(JobStatuses, JobStatus(e)) {
+			fe["webhook_events"] = fmt.Sprintf("unknown event %q; must be one of %s", e, joinStatuses(JobStatuses))
+			break
+		}
+	}
+
+	if r.WebhookURL != "" {
+		u, err := url.Parse(r.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fe["webhook_url"] = "must be an absolute http or https URL"
+		}
+	}
+
+	if len(r.Metadata) > MaxMetadataEntries {
+		fe["metadata"] = fmt.Sprintf("must have at most %d entries", MaxMetadataEntries)
+	} else {
+		for k, v := range r.Metadata {
+			if k == "" || len(k) > MaxMetadataKeyLength {
+				fe["metadata"] = fmt.Sprintf("keys must be 1-%d bytes", MaxMetadataKeyLength)
+				break
+			}
+			if len(v) > MaxMetadataValueBytes {
+				fe["metadata"] = fmt.Sprintf("values must be at most %d bytes", MaxMetadataValueBytes)
+				break
+			}
+		}
+	}
+
+	if len(r.Tags) > MaxTags {
+		fe["tags"] = fmt.Sprintf("must have at most %d entries", MaxTags)
+	} else {
+		for _, tag := range r.Tags {
+			if tag == "" || len(tag) > MaxTagLength {
+				fe["tags"] = fmt.Sprintf("each tag must be 1-%d bytes", MaxTagLength)
+				break
+			}
+		}
+	}
+
+	if len(fe) > 0 {
+		return fe
+	}
+	return nil
+}
+
+func joinStatuses(statuses []JobStatus) string {
+	names := make([]string, len(statuses))
+	for i, s := range statuses {
+		names[i] = string(s)
+	}
+	return strings.Join(names, ", ")
+}
+
+// checkControlChars rejects a command or args carrying control characters other
+// than tab, such as the newlines and escape sequences that tools reading them
+// later may misinterpret. Submit applies it, after templates are expanded, when
+// the manager has WithRejectControlChars.
+func checkControlChars(command string, args []string) error {
+	fe := FieldErrors{}
+	if hasControlChar(command) {
+		fe["command"] = "must not contain control characters"
+	}
+	for _, a := range args {
+		if hasControlChar(a) {
+			fe["args"] = "entries must not contain control characters"
+			break
+		}
+	}
+	if len(fe) > 0 {
+		return fe
+	}
+	return nil
+}
+
+func hasControlChar(s string) bool {
+	return strings.ContainsFunc(s, func(r rune) bool {
+		return r != '\t' && unicode.IsControl(r)
+	})
+}
+
+// validJobID reports whether a caller-supplied ID is safe to use as a store key
+// and in file names such as the log archive's.
+func validJobID(id string) bool {
+	if len(id) > MaxIDLength {
+		return false
+	}
+	for i, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case (c == '.' || c == '_' || c == '-') && i > 0:
+		default:
+			return false
+		}
+	}
+	return id != ""
+}
@@ -0,0 +1,125 @@
+package jobs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCreateJobRequest_Validate(t *testing.T) {
+	valid := CreateJobRequest{Command: "echo", WorkingDir: "/tmp/work", WebhookURL: "https://example.com/hook"}
+	if err := valid.Validate(false); err != nil {
+		t.Fatalf("expected valid request, got %v", err)
+	}
+	if err := (CreateJobRequest{}).Validate(true); err != nil {
+		t.Fatalf("expected empty command to be allowed with a default, got %v", err)
+	}
+
+	bad := CreateJobRequest{
+		WorkingDir: "/srv/jobs/../../etc",
+		WebhookURL: "ftp://example.com",
+		Args:       make([]string, MaxArgs+1),
+		Metadata:   map[string]string{"k": strings.Repeat("v", MaxMetadataValueBytes+1)},
+		Tags:       []string{"ok", ""},
+
+		CleanupWorkingDir: true,
+	}
+	err := bad.Validate(false)
+	var fe FieldErrors
+	if !errors.As(err, &fe) {
+		t.Fatalf("expected FieldErrors, got %v", err)
+	}
+	for _, field := range []string{"command", "working_dir", "webhook_url", "args", "metadata", "tags", "cleanup_working_dir"} {
+		if fe[field] == "" {
+			t.Errorf("expected an error for %s, got %v", field, fe)
+		}
+	}
+}
+
+func TestCreateJobRequest_ValidateNice(t *testing.T) {
+	for _, nice := range []int{-20, 0, 19} {
+		if err := (CreateJobRequest{Command: "echo", Nice: nice}).Validate(false); err != nil {
+			t.Errorf("nice %d: expected valid, got %v", nice, err)
+		}
+	}
+	for _, nice := range []int{-21, 20} {
+		var fe FieldErrors
+		if err := (CreateJobRequest{Command: "echo", Nice: nice}).Validate(false); !errors.As(err, &fe) || fe["nice"] == "" {
+			t.Errorf("nice %d: expected a nice error, got %v", nice, err)
+		}
+	}
+}
+
+func TestArgLimits(t *testing.T) {
+	limits := ArgLimits{Count: 3, TotalBytes: 10}
+	if msg := limits.check([]string{"abc", "defg", "hij"}); msg != "" {
+		t.Fatalf("expected args at the limits to pass, got %q", msg)
+	}
+	for name, args := range map[string][]string{
+		"too many":   {"a", "b", "c", "d"},
+		"too long":   {"abcdef", "ghijk"},
+		"null byte":  {"a\x00b"},
+		"huge entry": {strings.Repeat("a", MaxArgLength+1)},
+	} {
+		if msg := limits.check(args); msg == "" {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	// Zero fields fall back to the defaults
+	if msg := (ArgLimits{}).check(make([]string, MaxArgs)); msg != "" {
+		t.Fatalf("expected MaxArgs args to pass the defaults, got %q", msg)
+	}
+	if msg := (ArgLimits{}).check(make([]string, MaxArgs+1)); msg == "" {
+		t.Fatal("expected more than MaxArgs args to fail the defaults")
+	}
+	req := CreateJobRequest{Command: "echo", Args: []string{"a", "b", "c", "d"}}
+	if err := req.Validate(false); err != nil {
+		t.Fatalf("expected 4 args to pass the defaults, got %v", err)
+	}
+	var fe FieldErrors
+	if err := req.ValidateWithLimits(false, limits); !errors.As(err, &fe) || fe["args"] == "" {
+		t.Fatalf("expected 4 args to fail a limit of 3, got %v", err)
+	}
+}
+
+func TestCreateJobRequest_ValidateOutputEncoding(t *testing.T) {
+	for _, enc := range []string{"", "utf-8", "latin1", "windows-1252", "raw"} {
+		if err := (CreateJobRequest{Command: "echo", OutputEncoding: enc}).Validate(false); err != nil {
+			t.Errorf("encoding %q: expected valid, got %v", enc, err)
+		}
+	}
+	var fe FieldErrors
+	if err := (CreateJobRequest{Command: "echo", OutputEncoding: "base64"}).Validate(false); !errors.As(err, &fe) || fe["output_encoding"] == "" {
+		t.Errorf("expected an output_encoding error, got %v", err)
+	}
+}
+
+func TestCreateJobRequest_ValidateID(t *testing.T) {
+	for _, id := range []string{"", "build-42", "ci.run_7", strings.Repeat("a", MaxIDLength)} {
+		if err := (CreateJobRequest{ID: id, Command: "echo"}).Validate(false); err != nil {
+			t.Errorf("id %q: expected valid, got %v", id, err)
+		}
+	}
+	for _, id := range []string{"has space", "../etc", "a/b", ".hidden", "-flag", "ümlaut", strings.Repeat("a", MaxIDLength+1)} {
+		var fe FieldErrors
+		if err := (CreateJobRequest{ID: id, Command: "echo"}).Validate(false); !errors.As(err, &fe) || fe["id"] == "" {
+			t.Errorf("id %q: expected an id error, got %v", id, err)
+		}
+	}
+}
+
+func TestCreateJobRequest_ValidateNullBytes(t *testing.T) {
+	if err := (CreateJobRequest{Command: "grep", Args: []string{"two words", "-e", "a b\tc"}}).Validate(false); err != nil {
+		t.Fatalf("expected args with spaces to be valid, got %v", err)
+	}
+	for _, req := range []CreateJobRequest{
+		{Command: "echo", Args: []string{"ok", "bad\x00arg"}},
+		{Command: "ec\x00ho"},
+		{Template: "t", Params: map[string]string{"p": "\x00"}},
+	} {
+		var fe FieldErrors
+		if err := req.Validate(false); !errors.As(err, &fe) || fe["args"]+fe["command"]+fe["params"] == "" {
+			t.Errorf("%+v: expected a null byte error, got %v", req, err)
+		}
+	}
+}
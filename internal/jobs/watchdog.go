@@ -0,0 +1,159 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrJobStuck is the cause of jobs the watchdog force-fails.
+var ErrJobStuck = errors.New("job stuck")
+
+// defaultStuckCheckInterval is how often the watchdog scans running jobs.
+const defaultStuckCheckInterval = time.Minute
+
+// StuckConfig tunes the watchdog that flags jobs left in_progress far longer
+// than they should run, typically because the child became a zombie or its
+// worker is wedged. With neither limit set the watchdog does not run.
+type StuckConfig struct {
+	// TimeoutMultiple flags a job with a timeout once it has been running this
+	// many times its timeout.
+	TimeoutMultiple float64
+	// Threshold flags any job running longer than this, including jobs without a
+	// timeout. Whichever limit is reached first applies.
+	Threshold time.Duration
+	// Interval between scans; a minute by default.
+	Interval time.Duration
+	// Fail marks stuck jobs failed with ErrJobStuck and cancels their run,
+	// instead of only reporting them.
+	Fail bool
+}
+
+// WithStuckDetection starts a watchdog that reports jobs running past the
+// limits of c in Stats and the jobs_stuck gauge.
+func WithStuckDetection(c StuckConfig) ManagerOption {
+	return func(m *Manager) {
+		m.stuck.config = c
+	}
+}
+
+// stuckWatch holds the watchdog's settings and the jobs flagged by its last scan.
+type stuckWatch struct {
+	config StuckConfig
+	stop   chan struct{}
+	done   chan struct{}
+
+	mu  sync.Mutex
+	ids []string
+}
+
+func (w *stuckWatch) enabled() bool {
+	return w.config.TimeoutMultiple > 0 || w.config.Threshold > 0
+}
+
+// limit is how long job may run before it counts as stuck; 0 means never.
+func (w *stuckWatch) limit(job *Job) time.Duration {
+	limit := w.config.Threshold
+	if w.config.TimeoutMultiple > 0 && job.EffectiveTimeoutSeconds > 0 {
+		byTimeout := time.Duration(w.config.TimeoutMultiple * float64(time.Duration(job.EffectiveTimeoutSeconds)*time.Second))
+		if limit == 0 || byTimeout < limit {
+			limit = byTimeout
+		}
+	}
+	return limit
+}
+
+// stuckIDs returns the jobs flagged by the last scan.
+func (w *stuckWatch) stuckIDs() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.ids...)
+}
+
+// startWatchdog scans running jobs every interval until Stop.
+func (m *Manager) startWatchdog() {
+	interval := m.stuck.config.Interval
+	if interval <= 0 {
+		interval = defaultStuckCheckInterval
+	}
+	m.stuck.stop = make(chan struct{})
+	m.stuck.done = make(chan struct{})
+	go func() {
+		defer close(m.stuck.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.checkStuck(time.Now())
+			case <-m.stuck.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (m *Manager) stopWatchdog() {
+	if m.stuck.stop == nil {
+		return
+	}
+	close(m.stuck.stop)
+	<-m.stuck.done
+}
+
+// checkStuck flags the in_progress jobs that have run past their limit at now,
+// failing them if configured to. Every job in progress is tracked in runs, so
+// that is scanned rather than the whole store.
+func (m *Manager) checkStuck(now time.Time) {
+	var stuck []string
+	for _, id := range m.runs.ids() {
+		job, ok := m.store.Get(id)
+		if !ok || job.Status != JobStatusInProgress || job.StartedAt == nil {
+			continue
+		}
+		limit := m.stuck.limit(job)
+		running := now.Sub(*job.StartedAt)
+		if limit == 0 || running < limit {
+			continue
+		}
+		slog.Warn("job stuck in progress", "job_id", id, "running", running.Round(time.Second).String(), "limit", limit.String(), "pid", job.PID)
+		if m.stuck.config.Fail && m.failStuck(id, fmt.Errorf("%w: in progress for %s, over the %s limit", ErrJobStuck, running.Round(time.Second), limit)) {
+			continue
+		}
+		stuck = append(stuck, id)
+	}
+	sort.Strings(stuck)
+	m.stuck.mu.Lock()
+	m.stuck.ids = stuck
+	m.stuck.mu.Unlock()
+	JobsStuck.Set(float64(len(stuck)))
+}
+
+// failStuck records the job as failed with cause and cancels its run, in case
+// the runner can still be woken. The run is released so waiters return even if
+// its worker never does. It reports whether the job was failed.
+func (m *Manager) failStuck(id string, cause error) bool {
+	job, ok := m.transition(id, func(j *Job) {
+		now := time.Now().UTC()
+		j.PID = 0
+		j.Status = JobStatusFailed
+		j.Error = cause.Error()
+		j.CompletedAt = &now
+	})
+	if !ok {
+		return false
+	}
+	JobsInProgress.Dec()
+	JobsFailedTotal.Inc()
+	JobsStuckFailedTotal.Inc()
+	m.notify(context.Background(), *job)
+	m.streamer.Broadcast(id, []byte("Job failed: "+cause.Error()+"\n"))
+	m.streamer.CloseWithStatus(id, job.Status, job.ExitCode)
+	m.runs.cancel(id, cause)
+	m.runs.finish(id)
+	return true
+}
@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestStuckWatch_Limit(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  StuckConfig
+		timeout int
+		want    time.Duration
+	}{
+		{"multiple of timeout", StuckConfig{TimeoutMultiple: 2}, 30, time.Minute},
+		{"no timeout, no threshold", StuckConfig{TimeoutMultiple: 2}, 0, 0},
+		{"threshold without timeout", StuckConfig{TimeoutMultiple: 2, Threshold: time.Hour}, 0, time.Hour},
+		{"threshold reached first", StuckConfig{TimeoutMultiple: 2, Threshold: 10 * time.Second}, 30, 10 * time.Second},
+		{"multiple reached first", StuckConfig{TimeoutMultiple: 1.5, Threshold: time.Hour}, 60, 90 * time.Second},
+		{"threshold only", StuckConfig{Threshold: time.Minute}, 600, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := stuckWatch{config: tt.config}
+			if got := w.limit(&Job{EffectiveTimeoutSeconds: tt.timeout}); got != tt.want {
+				t.Fatalf("limit = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// newStuckManager runs jobs on a runner that ignores cancellation and only
+// returns once the test ends.
+func newStuckManager(t *testing.T, c StuckConfig) (*Manager, *blockingRunner) {
+	t.Helper()
+	runner := newBlockingRunner()
+	m, err := NewManager(1, NewInMemoryStore(), nopSender{}, runner, NewLogStreamer(), WithStuckDetection(c))
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(m.Stop)
+	t.Cleanup(func() { close(runner.release) })
+	return m, runner
+}
+
+func TestManager_WatchdogFlagsStuckJob(t *testing.T) {
+	m, runner := newStuckManager(t, StuckConfig{Threshold: 30 * time.Millisecond, Interval: 5 * time.Millisecond})
+
+	id, err := m.Submit(context.Background(), CreateJobRequest{Command: "sleep"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	<-runner.started
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		s, err := m.Stats()
+		if err != nil {
+			t.Fatalf("stats: %v", err)
+		}
+		if slices.Equal(s.Stuck, []string{id}) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job was never flagged stuck, stats %+v", s)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := testutil.ToFloat64(JobsStuck); got != 1 {
+		t.Fatalf("expected jobs_stuck 1, got %v", got)
+	}
+	// Flagging alone leaves the job running
+	if j, _ := m.Get(id); j.Status != JobStatusInProgress {
+		t.Fatalf("expected the job to stay in progress, got %s", j.Status)
+	}
+}
+
+func TestManager_WatchdogFailsStuckJob(t *testing.T) {
+	m, runner := newStuckManager(t, StuckConfig{Threshold: 30 * time.Millisecond, Interval: 5 * time.Millisecond, Fail: true})
+	failedBefore := testutil.ToFloat64(JobsStuckFailedTotal)
+
+	id, err := m.Submit(context.Background(), CreateJobRequest{Command: "sleep"})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	<-runner.started
+
+	j := waitForStatus(t, m, id, JobStatusFailed)
+	if !strings.HasPrefix(j.Error, ErrJobStuck.Error()) || j.CompletedAt == nil {
+		t.Fatalf("expected a stuck failure, got %+v", j)
+	}
+	if got := testutil.ToFloat64(JobsStuckFailedTotal); got != failedBefore+1 {
+		t.Fatalf("expected jobs_stuck_failed_total to grow by 1, got %v", got-failedBefore)
+	}
+	// Waiters are released even though the runner has not returned
+	select {
+	case <-m.runs.wait(id):
+	case <-time.After(time.Second):
+		t.Fatal("expected the run to be released")
+	}
+	if err := m.Delete(context.Background(), id); err != nil {
+		t.Fatalf("expected the failed job to be deletable, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+// Package upload archives job output in object storage.
+package upload
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Config selects where S3Uploader puts job output.
+type S3Config struct {
+	Bucket string
+	// Prefix is prepended to every key, e.g. "jobs/"
+	Prefix string
+	// Region overrides the region from the environment or shared config
+	Region string
+	// Endpoint points at an S3-compatible service such as MinIO instead of AWS,
+	// using path-style addressing
+	Endpoint string
+}
+
+// S3Uploader stores each job's output as one JSON object,
+// {"stdout": "...", "stderr": "..."}, at {Prefix}{jobID}.json. Credentials come
+// from the standard AWS sources: environment, shared config or instance role.
+type S3Uploader struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Uploader loads the AWS configuration and returns an uploader for cfg.Bucket.
+func NewS3Uploader(ctx context.Context, cfg S3Config) (*S3Uploader, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3 uploader requires a bucket")
+	}
+	var opts []func(*awsconfig.LoadOptions) error
+	if cfg.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(cfg.Region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
+	}
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	return &S3Uploader{client: client, bucket: cfg.Bucket, prefix: cfg.Prefix}, nil
+}
+
+type output struct {
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+}
+
+// Upload puts the job's output in the bucket and returns its s3:// URL.
+func (u *S3Uploader) Upload(ctx context.Context, jobID, stdout, stderr string) (string, error) {
+	body, err := json.Marshal(output{Stdout: stdout, Stderr: stderr})
+	if err != nil {
+		return "", err
+	}
+	key := u.prefix + jobID + ".json"
+	_, err = u.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(u.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to put s3://%s/%s: %w", u.bucket, key, err)
+	}
+	return "s3://" + u.bucket + "/" + key, nil
+}
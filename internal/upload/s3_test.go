@@ -0,0 +1,46 @@
+package upload
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestS3Uploader_PutsJSONObject(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+
+	var method, path string
+	var got output
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Errorf("body is not JSON: %q", body)
+		}
+	}))
+	defer srv.Close()
+
+	u, err := NewS3Uploader(context.Background(), S3Config{Bucket: "archive", Prefix: "jobs/", Region: "us-east-1", Endpoint: srv.URL})
+	if err != nil {
+		t.Fatalf("new uploader: %v", err)
+	}
+	url, err := u.Upload(context.Background(), "job-1", "out\n", "err\n")
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if url != "s3://archive/jobs/job-1.json" {
+		t.Fatalf("url = %q", url)
+	}
+	if method != http.MethodPut || path != "/archive/jobs/job-1.json" {
+		t.Fatalf("got %s %s, want a path-style PUT of the object", method, path)
+	}
+	if got.Stdout != "out\n" || got.Stderr != "err\n" {
+		t.Fatalf("uploaded %+v", got)
+	}
+}
@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Encoder turns an event into a webhook request body. The bytes it returns
+// are exactly what is sent, before any gzip compression.
+type Encoder interface {
+	// ContentType is the Content-Type header sent with the body.
+	ContentType() string
+	Encode(event Event) ([]byte, error)
+}
+
+// JSONEncoder sends the event as a JSON object. It is the default.
+type JSONEncoder struct{}
+
+func (JSONEncoder) ContentType() string { return "application/json" }
+
+func (JSONEncoder) Encode(event Event) ([]byte, error) {
+	return json.Marshal(event)
+}
+
+// FormEncoder sends the event as application/x-www-form-urlencoded fields
+// named after its JSON keys. Nested objects and arrays use bracketed keys, as
+// in metadata[team]=infra or data[args][0]=-v, and null values are left out.
+type FormEncoder struct{}
+
+func (FormEncoder) ContentType() string { return "application/x-www-form-urlencoded" }
+
+func (FormEncoder) Encode(event Event) ([]byte, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var fields map[string]any
+	if err := dec.Decode(&fields); err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	flattenForm(form, "", fields)
+	return []byte(form.Encode()), nil
+}
+
+// flattenForm adds v to form under key, recursing into objects and arrays.
+func flattenForm(form url.Values, key string, v any) {
+	switch v := v.(type) {
+	case nil:
+	case map[string]any:
+		for k, item := range v {
+			flattenForm(form, formKey(key, k), item)
+		}
+	case []any:
+		for i, item := range v {
+			flattenForm(form, formKey(key, fmt.Sprint(i)), item)
+		}
+	default:
+		form.Add(key, fmt.Sprint(v))
+	}
+}
+
+func formKey(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "[" + key + "]"
+}
+
+// EncoderFor returns the built-in encoder named format: "json" or "form".
+func EncoderFor(format string) (Encoder, error) {
+	switch format {
+	case "", "json":
+		return JSONEncoder{}, nil
+	case "form":
+		return FormEncoder{}, nil
+	default:
+		return nil, fmt.Errorf("unknown webhook format %q", format)
+	}
+}
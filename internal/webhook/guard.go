@@ -0,0 +1,96 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedDestination is returned when a webhook URL, or a redirect it leads to,
+// is not an allowed destination. Blocked deliveries are not retried.
+var ErrBlockedDestination = errors.New("webhook destination not allowed")
+
+// nonPublicPrefixes are ranges netip counts as global unicast that are not
+// safe destinations: "this network" (0.0.0.0/8, which Linux dials as
+// localhost), carrier-grade NAT (RFC 6598), benchmarking and reserved IPv4,
+// and the NAT64 prefixes, which embed an IPv4 address that may be internal.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// WithPrivateNetworkGuard refuses to connect to loopback, private, link-local and other
+// non-public addresses. The check runs on the resolved IP of every connection, so it also
+// covers redirects and hostnames that resolve to internal addresses. Outbound proxies
+// from the environment are ignored while the guard is on.
+func WithPrivateNetworkGuard() SenderOption {
+	return func(s *httpsender) {
+		s.blockPrivate = true
+	}
+}
+
+// checkURL rejects anything other than absolute http and https URLs.
+func checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrBlockedDestination, u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%w: missing host", ErrBlockedDestination)
+	}
+	return nil
+}
+
+// isPublicIP reports whether ip is a globally routable unicast address.
+func isPublicIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return false
+	}
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// guardDial is a net.Dialer Control function that runs after DNS resolution, right
+// before connecting, and refuses non-public addresses.
+func guardDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("%w: unparseable address %q", ErrBlockedDestination, host)
+	}
+	if !isPublicIP(ip) {
+		return fmt.Errorf("%w: %s is not a public address", ErrBlockedDestination, ip)
+	}
+	return nil
+}
+
+// guardedTransport is http.DefaultTransport with guardDial applied to every connection.
+// Proxies are disabled: through a proxy the dialed address is the proxy's, not the
+// destination's, and the guard would check the wrong host.
+func guardedTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   guardDial,
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = dialer.DialContext
+	return t
+}
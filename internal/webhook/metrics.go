@@ -0,0 +1,25 @@
+package webhook
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	WebhookDeliveriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_deliveries_total",
+		Help: "Total number of webhook deliveries by outcome (success or failure), after retries",
+	}, []string{"status"})
+	WebhookDeliveryDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "webhook_delivery_duration_seconds",
+		Help:    "Time taken to deliver a webhook, including retries and backoff",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 14),
+	})
+	WebhookRetriesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "webhook_retries_total",
+		Help: "Total number of webhook delivery attempts beyond the first",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(WebhookDeliveriesTotal, WebhookDeliveryDuration, WebhookRetriesTotal)
+}
@@ -2,13 +2,24 @@ package webhook
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
-	"encoding/json"
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/paulgrammer/childprocess/internal/webhook")
+
 type Event struct {
 	JobID     string            `json:"job_id"`
 	Status    string            `json:"status"`
@@ -16,6 +27,10 @@ type Event struct {
 	Timestamp time.Time         `json:"timestamp"`
 	Metadata  map[string]string `json:"metadata,omitempty"`
 	Data      any               `json:"data,omitempty"`
+
+	// OutputTruncated is set when the output embedded in Data was cut to fit
+	// the server's webhook limits.
+	OutputTruncated bool `json:"output_truncated,omitempty"`
 }
 
 type Sender interface {
@@ -23,34 +38,229 @@ type Sender interface {
 }
 
 type httpsender struct {
-	client      *http.Client
-	maxRetries  int
-	baseBackoff time.Duration
+	client       *http.Client
+	maxRetries   int
+	baseBackoff  time.Duration
+	gzipMinBytes int
+	blockPrivate bool
+	maxRedirects int
+	retryable    Classifier
+	transport    TransportConfig
+	encoder      Encoder
+}
+
+// ErrRedirected is returned when the webhook endpoint answers with a redirect that
+// the sender is not allowed to follow. Redirected deliveries are not retried.
+var ErrRedirected = errors.New("webhook endpoint redirected")
+
+// ErrPermanentFailure is returned when the classifier decides a failed delivery
+// would fail again, so it was not retried.
+var ErrPermanentFailure = errors.New("webhook delivery failed permanently")
+
+// Classifier reports whether a failed delivery is worth retrying. resp is nil
+// when no response was received, in which case err says why; otherwise its body
+// has already been closed. Redirects and blocked destinations are never retried.
+type Classifier func(resp *http.Response, err error) bool
+
+// DefaultClassifier retries network errors, 408 Request Timeout, 429 Too Many
+// Requests and 5xx responses. Any other 4xx is a client error that would only
+// fail again.
+func DefaultClassifier(resp *http.Response, err error) bool {
+	if resp == nil {
+		return err != nil
+	}
+	switch {
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		return true
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return false
+	default:
+		return true
+	}
+}
+
+type SenderOption func(*httpsender)
+
+// WithClassifier replaces DefaultClassifier for deciding which failures are retried.
+func WithClassifier(c Classifier) SenderOption {
+	return func(s *httpsender) {
+		s.retryable = c
+	}
+}
+
+// WithFollowRedirects follows up to max redirects that keep the request a POST
+// (307 and 308). Every hop is checked like the original URL. By default redirects
+// are not followed and a 3xx response fails the delivery with ErrRedirected.
+func WithFollowRedirects(max int) SenderOption {
+	return func(s *httpsender) {
+		s.maxRedirects = max
+	}
+}
+
+// WithGzip compresses JSON bodies of at least minBytes and sets Content-Encoding: gzip.
+// A minBytes of 0 or less disables compression.
+func WithGzip(minBytes int) SenderOption {
+	return func(s *httpsender) {
+		s.gzipMinBytes = minBytes
+	}
+}
+
+// WithEncoder sets how events are encoded into request bodies and the
+// Content-Type they are sent with. JSONEncoder is the default.
+func WithEncoder(e Encoder) SenderOption {
+	return func(s *httpsender) {
+		s.encoder = e
+	}
+}
+
+// TransportConfig tunes the connections webhooks are delivered over. Zero
+// fields take the defaults noted on them.
+type TransportConfig struct {
+	// MaxIdleConnsPerHost is how many idle connections are kept for reuse per
+	// webhook host; 8 by default.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost caps connections to one host, busy or idle; deliveries
+	// beyond it wait for a free one. Unlimited by default.
+	MaxConnsPerHost int
+	// IdleConnTimeout closes connections idle this long; 90s by default.
+	IdleConnTimeout time.Duration
+	// DisableHTTP2 keeps deliveries on HTTP/1.1 even to servers offering HTTP/2.
+	DisableHTTP2 bool
+}
+
+const (
+	defaultMaxIdleConnsPerHost = 8
+	defaultIdleConnTimeout     = 90 * time.Second
+)
+
+// WithTransport tunes connection pooling and limits of the sender's transport.
+func WithTransport(c TransportConfig) SenderOption {
+	return func(s *httpsender) {
+		s.transport = c
+	}
 }
 
-func NewHTTPSender(timeout time.Duration, maxRetries int) Sender {
+// apply sets the configured limits on t.
+func (c TransportConfig) apply(t *http.Transport) {
+	t.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	if c.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	}
+	t.MaxConnsPerHost = max(c.MaxConnsPerHost, 0)
+	t.IdleConnTimeout = defaultIdleConnTimeout
+	if c.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = c.IdleConnTimeout
+	}
+	if c.DisableHTTP2 {
+		// A non-nil empty TLSNextProto is how net/http is told not to negotiate h2
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+}
+
+func NewHTTPSender(timeout time.Duration, maxRetries int, opts ...SenderOption) Sender {
 	if timeout <= 0 {
 		timeout = 10 * time.Second
 	}
 	if maxRetries < 0 {
 		maxRetries = 3
 	}
-	return &httpsender{
+	s := &httpsender{
 		client:      &http.Client{Timeout: timeout},
 		maxRetries:  maxRetries,
 		baseBackoff: 500 * time.Millisecond,
+		retryable:   DefaultClassifier,
+		encoder:     JSONEncoder{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if s.blockPrivate {
+		transport = guardedTransport()
+	}
+	s.transport.apply(transport)
+	s.client.Transport = transport
+	s.client.CheckRedirect = s.checkRedirect
+	return s
+}
+
+// checkRedirect refuses redirects beyond the configured limit and any that would
+// rewrite the POST into a GET, which would silently drop the event.
+func (s *httpsender) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > s.maxRedirects {
+		return http.ErrUseLastResponse
+	}
+	if req.Method != via[0].Method {
+		return http.ErrUseLastResponse
+	}
+	return checkURL(req.URL)
+}
+
+// encode encodes the event and compresses it when it reaches the gzip threshold.
+// It returns the body as sent on the wire and its Content-Encoding, if any.
+func (s *httpsender) encode(event Event) ([]byte, string, error) {
+	body, err := s.encoder.Encode(event)
+	if err != nil {
+		return nil, "", err
+	}
+	if s.gzipMinBytes <= 0 || len(body) < s.gzipMinBytes {
+		return body, "", nil
 	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, "", err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "gzip", nil
 }
 
-func (s *httpsender) Notify(ctx context.Context, url string, event Event) error {
-	body, _ := json.Marshal(event)
+func (s *httpsender) Notify(ctx context.Context, rawURL string, event Event) (err error) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "webhook.deliver", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("job.id", event.JobID),
+		attribute.String("job.status", event.Status),
+	))
+	defer func() {
+		WebhookDeliveryDuration.Observe(time.Since(start).Seconds())
+		if err != nil {
+			WebhookDeliveriesTotal.WithLabelValues("failure").Inc()
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			WebhookDeliveriesTotal.WithLabelValues("success").Inc()
+		}
+		span.End()
+	}()
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBlockedDestination, err)
+	}
+	if err := checkURL(u); err != nil {
+		return err
+	}
+	body, encoding, err := s.encode(event)
+	if err != nil {
+		return err
+	}
 	var lastErr error
 	for attempt := 0; attempt <= s.maxRetries; attempt++ {
-		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if attempt > 0 {
+			WebhookRetriesTotal.Inc()
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
 		if err != nil {
 			return err
 		}
-		req.Header.Set("content-type", "application/json")
+		req.Header.Set("content-type", s.encoder.ContentType())
+		if encoding != "" {
+			req.Header.Set("content-encoding", encoding)
+		}
+		// Receivers can continue the job's trace from the traceparent header
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 		resp, err := s.client.Do(req)
 		if err == nil && resp != nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
 			if resp.Body != nil {
@@ -61,11 +271,20 @@ func (s *httpsender) Notify(ctx context.Context, url string, event Event) error
 		if resp != nil && resp.Body != nil {
 			_ = resp.Body.Close()
 		}
+		if errors.Is(err, ErrBlockedDestination) {
+			return err
+		}
+		if err == nil && resp.StatusCode >= 300 && resp.StatusCode < 400 {
+			return fmt.Errorf("%w: %s to %q", ErrRedirected, resp.Status, resp.Header.Get("Location"))
+		}
 		if err == nil {
 			lastErr = errors.New(resp.Status)
 		} else {
 			lastErr = err
 		}
+		if !s.retryable(resp, err) {
+			return fmt.Errorf("%w: %w", ErrPermanentFailure, lastErr)
+		}
 		// exponential backoff with jitter
 		backoff := s.baseBackoff * (1 << attempt)
 		select {
@@ -1,12 +1,23 @@
 package webhook
 
 import (
+    "compress/gzip"
     "context"
+    "encoding/json"
+    "errors"
+    "io"
     "net/http"
     "net/http/httptest"
+    "net/netip"
+    "strings"
     "sync/atomic"
     "testing"
     "time"
+
+    "github.com/prometheus/client_golang/prometheus/testutil"
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/propagation"
+    "go.opentelemetry.io/otel/trace"
 )
 
 func TestHTTPSender_Success(t *testing.T) {
@@ -79,4 +90,403 @@ func TestHTTPSender_ContextCancel(t *testing.T) {
     }
 }
 
+func TestHTTPSender_GzipAboveThreshold(t *testing.T) {
+    var gotEncoding []string
+    var gotEvents []Event
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        var body io.Reader = r.Body
+        if r.Header.Get("Content-Encoding") == "gzip" {
+            zr, err := gzip.NewReader(r.Body)
+            if err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            body = zr
+        }
+        var ev Event
+        if err := json.NewDecoder(body).Decode(&ev); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        gotEncoding = append(gotEncoding, r.Header.Get("Content-Encoding"))
+        gotEvents = append(gotEvents, ev)
+        w.WriteHeader(http.StatusOK)
+    }))
+    defer srv.Close()
+
+    s := NewHTTPSender(2*time.Second, 0, WithGzip(512))
+    ctx := context.Background()
+    large := Event{JobID: "5", Status: "completed", Timestamp: time.Now(), Metadata: map[string]string{"blob": strings.Repeat("x", 2048)}}
+    if err := s.Notify(ctx, srv.URL, large); err != nil {
+        t.Fatalf("expected success, got error: %v", err)
+    }
+    if err := s.Notify(ctx, srv.URL, Event{JobID: "6", Status: "queued", Timestamp: time.Now()}); err != nil {
+        t.Fatalf("expected success, got error: %v", err)
+    }
+
+    if len(gotEvents) != 2 {
+        t.Fatalf("expected 2 deliveries, got %d", len(gotEvents))
+    }
+    if gotEncoding[0] != "gzip" || gotEvents[0].Metadata["blob"] != large.Metadata["blob"] {
+        t.Fatalf("expected large event to be gzipped and intact, encoding %q", gotEncoding[0])
+    }
+    if gotEncoding[1] != "" || gotEvents[1].JobID != "6" {
+        t.Fatalf("expected small event to be sent uncompressed, encoding %q", gotEncoding[1])
+    }
+}
+
+func TestHTTPSender_RejectsNonHTTPScheme(t *testing.T) {
+    s := NewHTTPSender(2*time.Second, 3)
+    for _, u := range []string{"file:///etc/passwd", "gopher://127.0.0.1:6379/_INFO", "http:///nohost"} {
+        err := s.Notify(context.Background(), u, Event{JobID: "7", Status: "queued", Timestamp: time.Now()})
+        if !errors.Is(err, ErrBlockedDestination) {
+            t.Fatalf("%s: expected ErrBlockedDestination, got %v", u, err)
+        }
+    }
+}
+
+func TestHTTPSender_GuardBlocksInternalAddresses(t *testing.T) {
+    var hits int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        atomic.AddInt32(&hits, 1)
+        w.WriteHeader(http.StatusOK)
+    }))
+    defer srv.Close()
+
+    s := NewHTTPSender(2*time.Second, 3, WithPrivateNetworkGuard())
+    urls := []string{
+        srv.URL,
+        "http://localhost:6379/",
+        "http://169.254.169.254/latest/meta-data/",
+        "http://10.0.0.1/",
+        "http://[::1]:8080/",
+        "http://100.64.0.1/",
+    }
+    for _, u := range urls {
+        err := s.Notify(context.Background(), u, Event{JobID: "8", Status: "queued", Timestamp: time.Now()})
+        if !errors.Is(err, ErrBlockedDestination) {
+            t.Fatalf("%s: expected ErrBlockedDestination, got %v", u, err)
+        }
+    }
+    if atomic.LoadInt32(&hits) != 0 {
+        t.Fatalf("expected no requests to reach the internal server, got %d", hits)
+    }
+}
+
+func TestHTTPSender_RedirectIsRechecked(t *testing.T) {
+    var hits int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        atomic.AddInt32(&hits, 1)
+        http.Redirect(w, r, "gopher://127.0.0.1:6379/_INFO", http.StatusTemporaryRedirect)
+    }))
+    defer srv.Close()
+
+    s := NewHTTPSender(2*time.Second, 3, WithFollowRedirects(5))
+    err := s.Notify(context.Background(), srv.URL, Event{JobID: "9", Status: "queued", Timestamp: time.Now()})
+    if !errors.Is(err, ErrBlockedDestination) {
+        t.Fatalf("expected ErrBlockedDestination, got %v", err)
+    }
+    if atomic.LoadInt32(&hits) != 1 {
+        t.Fatalf("expected blocked redirect not to be retried, got %d hits", hits)
+    }
+}
+
+func TestIsPublicIP(t *testing.T) {
+    cases := map[string]bool{
+        "8.8.8.8":          true,
+        "2606:4700::1111":  true,
+        "127.0.0.1":        false,
+        "10.1.2.3":         false,
+        "172.16.0.1":       false,
+        "192.168.1.1":      false,
+        "169.254.169.254":  false,
+        "100.64.0.1":       false,
+        "0.0.0.0":          false,
+        "0.1.2.3":          false,
+        "100.127.255.254":  false,
+        "198.18.0.1":       false,
+        "240.0.0.1":        false,
+        "64:ff9b::a00:1":   false,
+        "64:ff9b:1::1":     false,
+        "::1":              false,
+        "fe80::1":          false,
+        "fd00::1":          false,
+        "::ffff:127.0.0.1": false,
+        "224.0.0.1":        false,
+    }
+    for addr, want := range cases {
+        if got := isPublicIP(netip.MustParseAddr(addr)); got != want {
+            t.Errorf("isPublicIP(%s) = %v, want %v", addr, got, want)
+        }
+    }
+}
+
+func TestHTTPSender_RedirectsNotFollowedByDefault(t *testing.T) {
+    var targetHits, redirectHits int32
+    target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        atomic.AddInt32(&targetHits, 1)
+        w.WriteHeader(http.StatusOK)
+    }))
+    defer target.Close()
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        atomic.AddInt32(&redirectHits, 1)
+        http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+    }))
+    defer srv.Close()
+
+    s := NewHTTPSender(2*time.Second, 3)
+    err := s.Notify(context.Background(), srv.URL, Event{JobID: "10", Status: "queued", Timestamp: time.Now()})
+    if !errors.Is(err, ErrRedirected) {
+        t.Fatalf("expected ErrRedirected, got %v", err)
+    }
+    if atomic.LoadInt32(&targetHits) != 0 || atomic.LoadInt32(&redirectHits) != 1 {
+        t.Fatalf("expected a single unfollowed attempt, got %d redirect hits and %d target hits", redirectHits, targetHits)
+    }
+}
+
+func TestHTTPSender_FollowRedirectsKeepsPost(t *testing.T) {
+    var gotMethod string
+    target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        gotMethod = r.Method
+        w.WriteHeader(http.StatusOK)
+    }))
+    defer target.Close()
+    mux := http.NewServeMux()
+    mux.HandleFunc("/keep", func(w http.ResponseWriter, r *http.Request) {
+        http.Redirect(w, r, target.URL, http.StatusPermanentRedirect)
+    })
+    mux.HandleFunc("/rewrite", func(w http.ResponseWriter, r *http.Request) {
+        http.Redirect(w, r, target.URL, http.StatusFound)
+    })
+    srv := httptest.NewServer(mux)
+    defer srv.Close()
+
+    s := NewHTTPSender(2*time.Second, 0, WithFollowRedirects(3))
+    ev := Event{JobID: "11", Status: "queued", Timestamp: time.Now()}
+    if err := s.Notify(context.Background(), srv.URL+"/keep", ev); err != nil {
+        t.Fatalf("expected 308 to be followed, got %v", err)
+    }
+    if gotMethod != http.MethodPost {
+        t.Fatalf("expected the redirected request to stay a POST, got %s", gotMethod)
+    }
 
+    gotMethod = ""
+    if err := s.Notify(context.Background(), srv.URL+"/rewrite", ev); !errors.Is(err, ErrRedirected) {
+        t.Fatalf("expected a POST-to-GET redirect to fail with ErrRedirected, got %v", err)
+    }
+    if gotMethod != "" {
+        t.Fatalf("expected the target not to be reached, got %s", gotMethod)
+    }
+}
+
+func TestHTTPSender_Metrics(t *testing.T) {
+    var hits int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if atomic.AddInt32(&hits, 1) == 1 {
+            http.Error(w, "boom", http.StatusInternalServerError)
+            return
+        }
+        w.WriteHeader(http.StatusOK)
+    }))
+    defer srv.Close()
+
+    success := testutil.ToFloat64(WebhookDeliveriesTotal.WithLabelValues("success"))
+    failure := testutil.ToFloat64(WebhookDeliveriesTotal.WithLabelValues("failure"))
+    retries := testutil.ToFloat64(WebhookRetriesTotal)
+
+    s := NewHTTPSender(2*time.Second, 1).(*httpsender)
+    s.baseBackoff = time.Millisecond
+    ctx := context.Background()
+    if err := s.Notify(ctx, srv.URL, Event{JobID: "12", Status: "completed", Timestamp: time.Now()}); err != nil {
+        t.Fatalf("expected success after one retry, got %v", err)
+    }
+    if err := s.Notify(ctx, "ftp://example.com", Event{JobID: "13", Status: "completed", Timestamp: time.Now()}); err == nil {
+        t.Fatalf("expected failure for an invalid scheme")
+    }
+
+    if got := testutil.ToFloat64(WebhookDeliveriesTotal.WithLabelValues("success")) - success; got != 1 {
+        t.Fatalf("expected 1 successful delivery, got %v", got)
+    }
+    if got := testutil.ToFloat64(WebhookDeliveriesTotal.WithLabelValues("failure")) - failure; got != 1 {
+        t.Fatalf("expected 1 failed delivery, got %v", got)
+    }
+    if got := testutil.ToFloat64(WebhookRetriesTotal) - retries; got != 1 {
+        t.Fatalf("expected 1 retry, got %v", got)
+    }
+}
+
+func TestHTTPSender_ClientErrorsAreNotRetried(t *testing.T) {
+    for _, tc := range []struct {
+        status    int
+        wantHits  int32
+        permanent bool
+    }{
+        {http.StatusBadRequest, 1, true},
+        {http.StatusNotFound, 1, true},
+        {http.StatusTooManyRequests, 3, false},
+        {http.StatusServiceUnavailable, 3, false},
+    } {
+        var hits int32
+        srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            atomic.AddInt32(&hits, 1)
+            w.WriteHeader(tc.status)
+        }))
+
+        s := NewHTTPSender(2*time.Second, 2).(*httpsender)
+        s.baseBackoff = time.Millisecond
+        err := s.Notify(context.Background(), srv.URL, Event{JobID: "14", Status: "completed", Timestamp: time.Now()})
+        srv.Close()
+
+        if err == nil {
+            t.Fatalf("%d: expected an error", tc.status)
+        }
+        if got := atomic.LoadInt32(&hits); got != tc.wantHits {
+            t.Errorf("%d: expected %d attempts, got %d", tc.status, tc.wantHits, got)
+        }
+        if errors.Is(err, ErrPermanentFailure) != tc.permanent {
+            t.Errorf("%d: ErrPermanentFailure = %v, want %v (err %v)", tc.status, !tc.permanent, tc.permanent, err)
+        }
+    }
+}
+
+func TestHTTPSender_CustomClassifier(t *testing.T) {
+    var hits int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if atomic.AddInt32(&hits, 1) == 1 {
+            w.WriteHeader(http.StatusConflict)
+            return
+        }
+        w.WriteHeader(http.StatusOK)
+    }))
+    defer srv.Close()
+
+    // Treat 409 as transient, e.g. a receiver that is still provisioning
+    retryConflicts := func(resp *http.Response, err error) bool {
+        return (resp != nil && resp.StatusCode == http.StatusConflict) || DefaultClassifier(resp, err)
+    }
+    s := NewHTTPSender(2*time.Second, 2, WithClassifier(retryConflicts)).(*httpsender)
+    s.baseBackoff = time.Millisecond
+    if err := s.Notify(context.Background(), srv.URL, Event{JobID: "15", Status: "completed", Timestamp: time.Now()}); err != nil {
+        t.Fatalf("expected success after retrying the conflict, got %v", err)
+    }
+    if hits != 2 {
+        t.Fatalf("expected 2 attempts, got %d", hits)
+    }
+}
+
+func TestHTTPSender_PropagatesTraceContext(t *testing.T) {
+    otel.SetTextMapPropagator(propagation.TraceContext{})
+    var got atomic.Value
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        got.Store(r.Header.Get("traceparent"))
+        w.WriteHeader(http.StatusOK)
+    }))
+    defer srv.Close()
+
+    sc := trace.NewSpanContext(trace.SpanContextConfig{
+        TraceID:    trace.TraceID{0xab},
+        SpanID:     trace.SpanID{0xcd},
+        TraceFlags: trace.FlagsSampled,
+    })
+    s := NewHTTPSender(2*time.Second, 0)
+    if err := s.Notify(trace.ContextWithSpanContext(context.Background(), sc), srv.URL, Event{JobID: "1", Status: "completed"}); err != nil {
+        t.Fatalf("expected success, got error: %v", err)
+    }
+    if header, _ := got.Load().(string); !strings.Contains(header, sc.TraceID().String()) {
+        t.Fatalf("traceparent = %q, want trace %s", header, sc.TraceID())
+    }
+}
+
+func TestHTTPSender_TransportConfig(t *testing.T) {
+    s := NewHTTPSender(2*time.Second, 0, WithPrivateNetworkGuard(), WithTransport(TransportConfig{
+        MaxIdleConnsPerHost: 3,
+        MaxConnsPerHost:     5,
+        IdleConnTimeout:     time.Minute,
+    })).(*httpsender)
+    tr, ok := s.client.Transport.(*http.Transport)
+    if !ok {
+        t.Fatalf("expected an *http.Transport, got %T", s.client.Transport)
+    }
+    if tr.MaxIdleConnsPerHost != 3 || tr.MaxConnsPerHost != 5 || tr.IdleConnTimeout != time.Minute {
+        t.Fatalf("expected the configured limits, got idle/host %d, conns/host %d, idle timeout %s", tr.MaxIdleConnsPerHost, tr.MaxConnsPerHost, tr.IdleConnTimeout)
+    }
+    if tr.Proxy != nil {
+        t.Fatal("expected the private network guard's transport to be kept")
+    }
+
+    tr = NewHTTPSender(2*time.Second, 0).(*httpsender).client.Transport.(*http.Transport)
+    if tr.MaxIdleConnsPerHost != defaultMaxIdleConnsPerHost || tr.MaxConnsPerHost != 0 || tr.IdleConnTimeout != defaultIdleConnTimeout {
+        t.Fatalf("expected the defaults, got idle/host %d, conns/host %d, idle timeout %s", tr.MaxIdleConnsPerHost, tr.MaxConnsPerHost, tr.IdleConnTimeout)
+    }
+}
+
+func TestHTTPSender_DisableHTTP2(t *testing.T) {
+    var proto atomic.Value
+    srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        proto.Store(r.Proto)
+        w.WriteHeader(http.StatusOK)
+    }))
+    srv.EnableHTTP2 = true
+    srv.StartTLS()
+    defer srv.Close()
+
+    for _, tc := range []struct {
+        disable bool
+        want    string
+    }{{false, "HTTP/2.0"}, {true, "HTTP/1.1"}} {
+        s := NewHTTPSender(2*time.Second, 0, WithTransport(TransportConfig{DisableHTTP2: tc.disable})).(*httpsender)
+        s.client.Transport.(*http.Transport).TLSClientConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+        if err := s.Notify(context.Background(), srv.URL, Event{JobID: "1", Status: "completed"}); err != nil {
+            t.Fatalf("expected success, got error: %v", err)
+        }
+        if got, _ := proto.Load().(string); got != tc.want {
+            t.Fatalf("DisableHTTP2=%v: server saw %s, want %s", tc.disable, got, tc.want)
+        }
+    }
+}
+
+func TestHTTPSender_FormEncoder(t *testing.T) {
+    got := make(chan *http.Request, 1)
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if err := r.ParseForm(); err != nil {
+            t.Errorf("failed to parse form: %v", err)
+        }
+        got <- r
+    }))
+    defer srv.Close()
+
+    s := NewHTTPSender(2*time.Second, 0, WithEncoder(FormEncoder{}))
+    event := Event{
+        JobID:     "job-1",
+        Status:    "completed",
+        Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+        Metadata:  map[string]string{"team": "infra & ops"},
+        Data:      map[string]any{"args": []string{"-v", "a=b"}, "exit_code": 0, "stdout": nil},
+    }
+    if err := s.Notify(context.Background(), srv.URL, event); err != nil {
+        t.Fatalf("expected success, got %v", err)
+    }
+    r := <-got
+    if ct := r.Header.Get("Content-Type"); ct != "application/x-www-form-urlencoded" {
+        t.Fatalf("unexpected content type %q", ct)
+    }
+    want := map[string]string{
+        "job_id":            "job-1",
+        "status":            "completed",
+        "timestamp":         "2024-05-01T12:00:00Z",
+        "metadata[team]":    "infra & ops",
+        "data[args][0]":     "-v",
+        "data[args][1]":     "a=b",
+        "data[exit_code]":   "0",
+    }
+    for key, value := range want {
+        if got := r.PostForm.Get(key); got != value {
+            t.Errorf("%s: expected %q, got %q", key, value, got)
+        }
+    }
+    for _, key := range []string{"error", "data[stdout]", "output_truncated"} {
+        if r.PostForm.Has(key) {
+            t.Errorf("expected %s to be left out, got %q", key, r.PostForm.Get(key))
+        }
+    }
+}